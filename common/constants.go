@@ -47,6 +47,62 @@ var (
 	LatestFabricInstallerVersion string
 	MinecraftServerPath          string
 	VanillaServerUrl             map[string]string
+	ModrinthAPIURL               string
+	VersionManifestURL           string
+	VersionSyncInterval          int // 小時
+	ArtifactCachePath            string
+	TemplatePath                 string
+	BackupPath                   string
+	ServerPortStart              int
+	ServerPortEnd                int
+	ServerCompanionPorts         string
+	DiskScanInterval             int   // 分鐘
+	ServerDiskLimitMB            int64 // 每個 server 預設的磁碟上限，0 代表不限制
+	CgroupEnabled                bool
+	CgroupRoot                   string
+	CgroupMemoryMaxMB            int // 固定的 memory.max，0 代表 -Xmx 加上 CgroupMemoryOverheadMB
+	CgroupMemoryOverheadMB       int
+	CgroupCPUMax                 string
+	CgroupCPUWeight              int
+	CgroupPidsMax                int
+	ServerIsolation              string // none、shared 或 per-server
+	ServerRunUID                 int    // shared 模式使用的 UID / GID
+	ServerRunGID                 int
+	ServerUIDStart               int // per-server 模式分配 UID 的範圍
+	ServerUIDCount               int
+	ServerNamespaces             bool
+	IdleShutdownMinutes          int // 0 代表預設不自動關閉
+	IdleCheckInterval            int // 秒
+	SleepingMOTD                 string
+	WakeMessage                  string
+	ProxyEnabled                 bool
+	ProxyListen                  string
+	ProxyDomain                  string // 設定後 hostname 只能是這個網域底下的名稱
+	UsageSampleInterval          int    // 秒
+	UsageRingSize                int    // 每個 server 保留在記憶體的原始樣本數
+	UsageMinuteRetentionDays     int
+	UsageHourRetentionDays       int
+	MetricsEnabled               bool
+	MetricsToken                 string
+	MetricsAllowIPs              string // 逗號分隔的 IP 或 CIDR
+	TPSPollInterval              int    // 秒
+	TPSCommands                  bool   // 預設 false，只用 "Can't keep up!" 警告估算；true 時在 console 送指令，會寫進 log 並廣播給 op
+	TPSLowThreshold              int
+	TPSLowMinutes                int
+)
+
+// 上游位址，全部可以用環境變數改成內部 mirror
+var (
+	FabricMetaURL    string
+	QuiltMetaURL     string
+	PaperAPIURL      string
+	PurpurAPIURL     string
+	ForgeMavenURL    string
+	ForgeFilesURL    string
+	NeoForgeMavenURL string
+	GitHubAPIURL     string
+	MirrorDir        string // 設定後所有上游請求改從此目錄讀取
+	MirrorURL        string // 設定後所有上游請求轉送到此位址
 )
 
 var SMTPServer string
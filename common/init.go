@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -23,6 +24,9 @@ var (
 	BatchUpdateInterval int
 	BatchUpdateEnabled  = false
 	RelayTimeout        int
+	PopulateMirror      = flag.String("populate-mirror", "", "download upstream metadata and artifacts into this directory, then exit")
+	MirrorVersions      = flag.String("mirror-versions", "", "comma separated minecraft versions to include when populating the mirror")
+	MirrorMods          = flag.String("mirror-mods", "", "comma separated Modrinth project IDs or slugs to include when populating the mirror")
 )
 
 func LoadEnv() {
@@ -80,14 +84,74 @@ func LoadEnv() {
 	GlobalApiRateLimitDuration = int64(GetEnvOrDefault("GLOBAL_API_RATE_LIMIT_DURATION", 60))
 	DCWebHookUrl = GetEnvOrDefaultString("DC_WEBHOOK_URL", "")
 	LatestFabricLoaderVersion = GetEnvOrDefaultString("LATEST_FABRIC_LOADER_VERSION", "")
-	LatestFabricInstallerVersion = GetEnvOrDefaultString("LATEST_FABRIC_INSTALLER_VERSION", "1.1.0")
+	LatestFabricInstallerVersion = GetEnvOrDefaultString("LATEST_FABRIC_INSTALLER_VERSION", "")
 	MinecraftServerPath = GetEnvOrDefaultString("MINECRAFT_SERVER_PATH", "./minecraft_servers")
+	ModrinthAPIURL = GetEnvOrDefaultString("MODRINTH_API_URL", "https://api.modrinth.com/v2")
+	VersionManifestURL = GetEnvOrDefaultString("VERSION_MANIFEST_URL", "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json")
+	VersionSyncInterval = GetEnvOrDefault("VERSION_SYNC_INTERVAL", 6)
+	ArtifactCachePath = GetEnvOrDefaultString("ARTIFACT_CACHE_PATH", "./cache/artifacts")
+	TemplatePath = GetEnvOrDefaultString("TEMPLATE_PATH", "./minecraft_templates")
+	BackupPath = GetEnvOrDefaultString("BACKUP_PATH", "./minecraft_backups")
+	ServerPortStart = GetEnvOrDefault("SERVER_PORT_START", 30000)
+	ServerPortEnd = GetEnvOrDefault("SERVER_PORT_END", 30050)
+	ServerCompanionPorts = GetEnvOrDefaultString("SERVER_COMPANION_PORTS", "")
+	DiskScanInterval = GetEnvOrDefault("DISK_SCAN_INTERVAL", 10)
+	ServerDiskLimitMB = int64(GetEnvOrDefault("SERVER_DISK_LIMIT_MB", 0))
+	CgroupEnabled = GetEnvOrDefaultBool("CGROUP_ENABLED", true)
+	CgroupRoot = GetEnvOrDefaultString("CGROUP_ROOT", "/sys/fs/cgroup/mc-servers")
+	CgroupMemoryMaxMB = GetEnvOrDefault("CGROUP_MEMORY_MAX_MB", 0)
+	CgroupMemoryOverheadMB = GetEnvOrDefault("CGROUP_MEMORY_OVERHEAD_MB", 1024)
+	CgroupCPUMax = GetEnvOrDefaultString("CGROUP_CPU_MAX", "max")
+	CgroupCPUWeight = GetEnvOrDefault("CGROUP_CPU_WEIGHT", 100)
+	CgroupPidsMax = GetEnvOrDefault("CGROUP_PIDS_MAX", 2048)
+	ServerIsolation = strings.ToLower(GetEnvOrDefaultString("SERVER_ISOLATION", "none"))
+	ServerRunUID = GetEnvOrDefault("SERVER_RUN_UID", 65534)
+	ServerRunGID = GetEnvOrDefault("SERVER_RUN_GID", 65534)
+	ServerUIDStart = GetEnvOrDefault("SERVER_UID_START", 200000)
+	ServerUIDCount = GetEnvOrDefault("SERVER_UID_COUNT", 10000)
+	ServerNamespaces = GetEnvOrDefaultBool("SERVER_NAMESPACES", false)
+	IdleShutdownMinutes = GetEnvOrDefault("IDLE_SHUTDOWN_MINUTES", 0)
+	IdleCheckInterval = GetEnvOrDefault("IDLE_CHECK_INTERVAL", 60)
+	SleepingMOTD = GetEnvOrDefaultString("SLEEPING_MOTD", "Sleeping - join to wake the server up")
+	WakeMessage = GetEnvOrDefaultString("WAKE_MESSAGE", "The server is starting, please reconnect in a minute.")
+	ProxyEnabled = GetEnvOrDefaultBool("PROXY_ENABLED", false)
+	ProxyListen = GetEnvOrDefaultString("PROXY_LISTEN", ":25565")
+	ProxyDomain = strings.ToLower(strings.Trim(GetEnvOrDefaultString("PROXY_DOMAIN", ""), "."))
+	UsageSampleInterval = GetEnvOrDefault("USAGE_SAMPLE_INTERVAL", 5)
+	UsageRingSize = GetEnvOrDefault("USAGE_RING_SIZE", 720)
+	UsageMinuteRetentionDays = GetEnvOrDefault("USAGE_MINUTE_RETENTION_DAYS", 7)
+	UsageHourRetentionDays = GetEnvOrDefault("USAGE_HOUR_RETENTION_DAYS", 90)
+	MetricsEnabled = GetEnvOrDefaultBool("METRICS_ENABLED", true)
+	MetricsToken = GetEnvOrDefaultString("METRICS_TOKEN", "")
+	MetricsAllowIPs = GetEnvOrDefaultString("METRICS_ALLOW_IPS", "")
+	TPSPollInterval = GetEnvOrDefault("TPS_POLL_INTERVAL", 30)
+	TPSCommands = GetEnvOrDefaultBool("TPS_COMMANDS", false)
+	TPSLowThreshold = GetEnvOrDefault("TPS_LOW_THRESHOLD", 15)
+	TPSLowMinutes = GetEnvOrDefault("TPS_LOW_MINUTES", 5)
+
+	FabricMetaURL = trimURL(GetEnvOrDefaultString("FABRIC_META_URL", "https://meta.fabricmc.net"))
+	QuiltMetaURL = trimURL(GetEnvOrDefaultString("QUILT_META_URL", "https://meta.quiltmc.org"))
+	PaperAPIURL = trimURL(GetEnvOrDefaultString("PAPER_API_URL", "https://api.papermc.io"))
+	PurpurAPIURL = trimURL(GetEnvOrDefaultString("PURPUR_API_URL", "https://api.purpurmc.org"))
+	ForgeMavenURL = trimURL(GetEnvOrDefaultString("FORGE_MAVEN_URL", "https://maven.minecraftforge.net"))
+	ForgeFilesURL = trimURL(GetEnvOrDefaultString("FORGE_FILES_URL", "https://files.minecraftforge.net"))
+	NeoForgeMavenURL = trimURL(GetEnvOrDefaultString("NEOFORGE_MAVEN_URL", "https://maven.neoforged.net"))
+	GitHubAPIURL = trimURL(GetEnvOrDefaultString("GITHUB_API_URL", "https://api.github.com"))
+	MirrorDir = GetEnvOrDefaultString("MIRROR_DIR", "")
+	MirrorURL = GetEnvOrDefaultString("MIRROR_URL", "")
 
 	NumPlayer = GetEnvOrDefault("NUM", 5)
 	FoolChance = GetEnvOrDefault("CHANCE", 1000)
 
 	SetUpSMTP()
 	LoadVanillaServerUrls()
+	if err := SetupUpstreamTransport(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func trimURL(u string) string {
+	return strings.TrimSuffix(u, "/")
 }
 
 func SetUpSMTP() {
@@ -0,0 +1,210 @@
+// common/metrics.go
+
+package common
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 簡單的 Prometheus text format 實作，只需要 counter 與 histogram，不引入 client_golang
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	metricsMu sync.Mutex
+	registry  []metric
+)
+
+func register(m metric) {
+	metricsMu.Lock()
+	registry = append(registry, m)
+	metricsMu.Unlock()
+}
+
+// 後端本身的指標
+var (
+	HTTPRequests = NewCounterVec("mcbackend_http_requests_total",
+		"HTTP requests by route and status.", "method", "route", "status")
+	HTTPDuration = NewHistogramVec("mcbackend_http_request_duration_seconds",
+		"HTTP request latency by route.", []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}, "method", "route")
+	RateLimitRejections = NewCounterVec("mcbackend_rate_limit_rejections_total",
+		"Requests rejected by the IP rate limiter.", "route")
+	BannedRequests = NewCounterVec("mcbackend_banned_requests_total",
+		"Requests blocked because the IP is banned.")
+	IPBans = NewCounterVec("mcbackend_ip_bans_total",
+		"IPs banned for too many failed logins.")
+	LoginFailures = NewCounterVec("mcbackend_login_failures_total",
+		"Failed login and verification attempts.", "reason")
+)
+
+type CounterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	v      float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]*counterValue)}
+	register(c)
+	return c
+}
+
+// Inc labelValues 的數量必須與建立時的 label 相同
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	cv, ok := c.values[key]
+	if !ok {
+		cv = &counterValue{labels: append([]string(nil), labelValues...)}
+		c.values[key] = cv
+	}
+	cv.v += v
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	WriteMetricHeader(w, c.name, c.help, "counter")
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+		return
+	}
+	for _, key := range sortedKeys(c.values) {
+		cv := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, FormatLabels(c.labels, cv.labels), FormatMetricValue(cv.v))
+	}
+}
+
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	values     map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64 // 每個 bucket 各自的數量，輸出時才累加
+	count  uint64
+	sum    float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramValue)}
+	register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{labels: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.count++
+	hv.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	WriteMetricHeader(w, h.name, h.help, "histogram")
+	names := append(append([]string(nil), h.labels...), "le")
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += hv.counts[i]
+			values := append(append([]string(nil), hv.labels...), FormatMetricValue(le))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, FormatLabels(names, values), cumulative)
+		}
+		values := append(append([]string(nil), hv.labels...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, FormatLabels(names, values), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, FormatLabels(h.labels, hv.labels), FormatMetricValue(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, FormatLabels(h.labels, hv.labels), hv.count)
+	}
+}
+
+// WriteMetrics 輸出所有 counter / histogram
+func WriteMetrics(w io.Writer) {
+	metricsMu.Lock()
+	list := append([]metric(nil), registry...)
+	metricsMu.Unlock()
+	for _, m := range list {
+		m.write(w)
+	}
+}
+
+func WriteMetricHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// FormatLabels 產生 {a="1",b="2"}，沒有 label 時回傳空字串
+func FormatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, labelEscaper.Replace(value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func FormatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -17,6 +17,16 @@ func CheckPortAvailable(port int) bool {
 	return true
 }
 
+// CheckUDPPortAvailable 試著綁定 UDP port (voice chat 等 plugin 使用)
+func CheckUDPPortAvailable(port int) bool {
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
 // 找一個指定範圍內第一個 free port（你可以改成靜態分配邏輯）
 func PickStaticPort(start, end int) (int, error) {
 	for p := start; p <= end; p++ {
@@ -0,0 +1,193 @@
+// common/upstream.go
+
+package common
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 所有對外的 metadata / 檔案下載都透過 NewHTTPClient，
+// 設定 MIRROR_DIR 或 MIRROR_URL 後改由本機目錄或內部 mirror 提供
+
+// mirrorBodyName 每個 URL 的回應存成 <host>/<path>/_body，避免 /a 與 /a/b 同時是檔案與目錄
+const mirrorBodyName = "_body"
+
+var ErrMirrorMiss = errors.New("not found in mirror")
+
+var (
+	upstreamMu        sync.RWMutex
+	upstreamTransport http.RoundTripper = http.DefaultTransport
+)
+
+// MirrorKey URL 在 mirror 內的相對路徑 (以 / 分隔)
+func MirrorKey(u *url.URL) string {
+	p := path.Clean("/" + u.Path)
+	name := mirrorBodyName
+	if u.RawQuery != "" {
+		sum := sha1.Sum([]byte(u.RawQuery))
+		name += "_" + hex.EncodeToString(sum[:8])
+	}
+	return strings.TrimPrefix(path.Join(u.Host, p, name), "/")
+}
+
+// dirMirrorTransport 從本機目錄讀取，找不到回 404
+type dirMirrorTransport struct {
+	dir string
+}
+
+func (t *dirMirrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return nil, fmt.Errorf("mirror mode only supports GET: %s %s", req.Method, req.URL)
+	}
+	f, err := os.Open(filepath.Join(t.dir, filepath.FromSlash(MirrorKey(req.URL))))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &http.Response{
+				Status:     "404 Not Found",
+				StatusCode: http.StatusNotFound,
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     make(http.Header),
+				Body:       io.NopCloser(strings.NewReader(ErrMirrorMiss.Error())),
+				Request:    req,
+			}, nil
+		}
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          f,
+		ContentLength: info.Size(),
+		Request:       req,
+	}, nil
+}
+
+// urlMirrorTransport 轉送到內部 mirror，路徑與本機目錄相同
+type urlMirrorTransport struct {
+	base *url.URL
+	next http.RoundTripper
+}
+
+func (t *urlMirrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := *t.base
+	target.Path = path.Join(t.base.Path, MirrorKey(req.URL))
+	target.RawQuery = ""
+	out := req.Clone(req.Context())
+	out.URL = &target
+	out.Host = target.Host
+	return t.next.RoundTrip(out)
+}
+
+// recordingTransport 正常連線上游，並把成功的 GET 回應寫進 mirror 目錄
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	dest := filepath.Join(t.dir, filepath.FromSlash(MirrorKey(req.URL)))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, err
+	}
+	tmp := dest + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("record %s error: %w", req.URL, err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return nil, err
+	}
+
+	body, err := os.ReadFile(dest)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// MirrorEnabled 是否設定了 MIRROR_DIR 或 MIRROR_URL
+func MirrorEnabled() bool {
+	return MirrorDir != "" || MirrorURL != ""
+}
+
+// SetupUpstreamTransport 依 MIRROR_DIR / MIRROR_URL 決定上游連線方式
+func SetupUpstreamTransport() error {
+	var t http.RoundTripper = http.DefaultTransport
+	switch {
+	case MirrorDir != "":
+		t = &dirMirrorTransport{dir: MirrorDir}
+		SysLog("mirror mode: serving upstream requests from " + MirrorDir)
+	case MirrorURL != "":
+		base, err := url.Parse(strings.TrimSuffix(MirrorURL, "/"))
+		if err != nil {
+			return fmt.Errorf("invalid MIRROR_URL: %w", err)
+		}
+		t = &urlMirrorTransport{base: base, next: http.DefaultTransport}
+		SysLog("mirror mode: forwarding upstream requests to " + MirrorURL)
+	}
+	upstreamMu.Lock()
+	upstreamTransport = t
+	upstreamMu.Unlock()
+	return nil
+}
+
+// EnableMirrorRecording 填充 mirror 用，所有上游回應會同時寫到 dir
+func EnableMirrorRecording(dir string) {
+	upstreamMu.Lock()
+	upstreamTransport = &recordingTransport{dir: dir, next: http.DefaultTransport}
+	upstreamMu.Unlock()
+}
+
+type sharedTransport struct{}
+
+func (sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	upstreamMu.RLock()
+	t := upstreamTransport
+	upstreamMu.RUnlock()
+	return t.RoundTrip(req)
+}
+
+// NewHTTPClient 對上游連線用的 client，會套用 mirror 設定；timeout 為 0 代表不限
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: sharedTransport{}}
+}
@@ -36,7 +36,7 @@ func GetTimeString() string {
 }
 
 func DownloadFile(dest, url string) error {
-	resp, err := http.Get(url)
+	resp, err := NewHTTPClient(0).Get(url)
 	if err != nil {
 		return fmt.Errorf("http get %s error: %w", url, err)
 	}
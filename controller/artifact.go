@@ -0,0 +1,71 @@
+// controller/artifact.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrefetchArtifactRequest struct {
+	ServerType string `json:"server_type" binding:"required"`
+	Version    string `json:"version" binding:"required"`
+	Loader     string `json:"loader"`
+	Installer  string `json:"installer"`
+	Build      string `json:"build"`
+}
+
+func ListArtifacts(c *gin.Context) {
+	list, err := service.ListArtifacts()
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListArtifacts error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list artifacts"})
+		return
+	}
+	c.JSON(200, gin.H{"artifacts": list})
+}
+
+func PrefetchArtifact(c *gin.Context) {
+	var req PrefetchArtifactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	opts := service.InstallOptions{Loader: req.Loader, Installer: req.Installer, Build: req.Build}
+	art, err := service.PrefetchArtifact(req.ServerType, req.Version, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrUnsupportedServerType) || errors.Is(err, service.ErrInvalidVersion) ||
+			errors.Is(err, service.ErrVersionNotFound) || errors.Is(err, service.ErrBuildNotFound) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "PrefetchArtifact error: "+err.Error())
+		c.JSON(502, gin.H{"error": "Failed to prefetch artifact"})
+		return
+	}
+	c.JSON(200, gin.H{"artifact": art})
+}
+
+func EvictArtifact(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid artifact id"})
+		return
+	}
+	if err := service.EvictArtifact(uint(id)); err != nil {
+		if errors.Is(err, service.ErrArtifactNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "EvictArtifact error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to evict artifact"})
+		return
+	}
+	c.Status(200)
+}
@@ -37,6 +37,7 @@ func AppLogin(c *gin.Context) {
 
 	if err != nil {
 		if err.Error() == "record not found" {
+			common.LoginFailures.Inc("user_not_found")
 			c.JSON(401, gin.H{"error": "User not found"})
 			_ = model.RecordAttempt(clientIP, false)
 		} else {
@@ -50,6 +51,7 @@ func AppLogin(c *gin.Context) {
 	_ = model.RecordAttempt(clientIP, false)
 
 	if !v {
+		common.LoginFailures.Inc("password")
 		c.JSON(401, gin.H{"error": "Invalid password"})
 		return
 	}
@@ -0,0 +1,109 @@
+// controller/configHistory.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func configHistoryError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrRevisionNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConfigNotTracked), errors.Is(err, service.ErrRevisionFileMismatch):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPropertiesWhileRunning):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		common.LogError(c.Request.Context(), action+" error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// ListConfigRevisions ?file=server.properties 只列出單一檔案
+func ListConfigRevisions(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	list, err := service.ListConfigRevisions(serverInfo.ServerID, serverInfo.SystemPath, c.Query("file"), limit)
+	if err != nil {
+		configHistoryError(c, err, "list revisions")
+		return
+	}
+	c.JSON(200, gin.H{"revisions": list})
+}
+
+func GetConfigRevision(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("revision_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid revision id"})
+		return
+	}
+	rev, err := service.GetConfigRevision(serverInfo.ServerID, uint(id))
+	if err != nil {
+		configHistoryError(c, err, "get revision")
+		return
+	}
+	c.JSON(200, gin.H{"revision": rev})
+}
+
+// DiffConfigRevisions ?from=&to= 比較同一個檔案的兩個 revision
+func DiffConfigRevisions(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	from, err1 := strconv.ParseUint(c.Query("from"), 10, 32)
+	to, err2 := strconv.ParseUint(c.Query("to"), 10, 32)
+	if err1 != nil || err2 != nil {
+		c.JSON(400, gin.H{"error": "Invalid revision id"})
+		return
+	}
+	diff, err := service.DiffConfigRevisions(serverInfo.ServerID, uint(from), uint(to))
+	if err != nil {
+		configHistoryError(c, err, "diff revisions")
+		return
+	}
+	c.JSON(200, gin.H{"from": from, "to": to, "diff": diff})
+}
+
+type RollbackConfigRequest struct {
+	RevisionID uint `json:"revision_id" binding:"required"`
+}
+
+func (sc *ServerController) RollbackConfig(c *gin.Context) {
+	var req RollbackConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	rev, err := sc.svc.RollbackConfig(serverInfo.ServerID, serverInfo.SystemPath, member.UserID, req.RevisionID)
+	if err != nil {
+		configHistoryError(c, err, "roll back config")
+		return
+	}
+	// server 執行中會使用記憶體內的設定，重啟後才會生效
+	c.JSON(200, gin.H{"revision": rev, "restart_required": sc.svc.IsRunning(serverInfo.ServerID)})
+}
@@ -0,0 +1,82 @@
+// controller/diskUsage.go
+
+package controller
+
+import (
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDiskUsage 最新的掃描結果與 ?hours= (預設 7 天) 內的歷史
+func GetDiskUsage(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "168"))
+	if err != nil || hours <= 0 {
+		c.JSON(400, gin.H{"error": "Invalid hours"})
+		return
+	}
+
+	usage, err := service.ServerDiskUsage(serverInfo, false)
+	if err != nil {
+		common.LogError(c.Request.Context(), "ServerDiskUsage error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get disk usage"})
+		return
+	}
+	history, err := service.DiskUsageHistory(serverInfo.ServerID, time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		common.LogError(c.Request.Context(), "DiskUsageHistory error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get disk usage"})
+		return
+	}
+	c.JSON(200, gin.H{"usage": usage, "limit_mb": service.ServerDiskLimitMB(serverInfo), "history": history})
+}
+
+// ScanDiskUsage 立即重新掃描 (已計算過的備份不會重新走訪)
+func ScanDiskUsage(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	usage, err := service.ServerDiskUsage(serverInfo, true)
+	if err != nil {
+		common.LogError(c.Request.Context(), "ServerDiskUsage error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to scan disk usage"})
+		return
+	}
+	c.JSON(200, gin.H{"usage": usage, "limit_mb": service.ServerDiskLimitMB(serverInfo)})
+}
+
+type DiskLimitRequest struct {
+	LimitMB int64 `json:"limit_mb" binding:"min=0"` // 0 代表使用預設值
+}
+
+func SetServerDiskLimit(c *gin.Context) {
+	var req DiskLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	rec, err := model.GetServerRecord(c.Param("server_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Server not found"})
+		return
+	}
+	if err := model.SetServerDiskLimit(rec.ServerID, req.LimitMB); err != nil {
+		common.LogError(c.Request.Context(), "SetServerDiskLimit error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to set disk limit"})
+		return
+	}
+	c.Status(200)
+}
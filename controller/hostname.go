@@ -0,0 +1,79 @@
+// controller/hostname.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func hostnameError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrHostname):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrHostNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrHostnameTaken):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		common.LogError(c.Request.Context(), action+" error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// ListHostnames 玩家透過代理連線時使用的位址
+func (sc *ServerController) ListHostnames(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	hosts, err := sc.svc.ListHostnames(serverInfo.ServerID)
+	if err != nil {
+		hostnameError(c, err, "list hostnames")
+		return
+	}
+	c.JSON(200, gin.H{"hostnames": hosts, "proxy_enabled": common.ProxyEnabled})
+}
+
+type HostnameRequest struct {
+	Hostname string `json:"hostname" binding:"required"`
+}
+
+func (sc *ServerController) AddHostname(c *gin.Context) {
+	var req HostnameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	host, err := sc.svc.AddHostname(serverInfo.ServerID, req.Hostname)
+	if err != nil {
+		hostnameError(c, err, "add hostname")
+		return
+	}
+	c.JSON(200, gin.H{"hostname": host})
+}
+
+func (sc *ServerController) RemoveHostname(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := sc.svc.RemoveHostname(serverInfo.ServerID, c.Param("hostname")); err != nil {
+		hostnameError(c, err, "remove hostname")
+		return
+	}
+	c.Status(200)
+}
@@ -0,0 +1,45 @@
+// controller/idle.go
+
+package controller
+
+import (
+	"go-backend/common"
+	"go-backend/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetIdle 閒置關閉的設定、線上人數與是否正在休眠
+func (sc *ServerController) GetIdle(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+	c.JSON(200, gin.H{"idle": sc.svc.IdleInfo(serverInfo)})
+}
+
+type IdlePolicyRequest struct {
+	IdleMinutes int `json:"idle_minutes" binding:"min=-1"` // 0 使用預設值，-1 不自動關閉
+}
+
+func (sc *ServerController) SetIdlePolicy(c *gin.Context) {
+	var req IdlePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := model.SetServerIdleMinutes(serverInfo.ServerID, req.IdleMinutes); err != nil {
+		common.LogError(c.Request.Context(), "SetServerIdleMinutes error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to set idle policy"})
+		return
+	}
+	serverInfo.IdleMinutes = req.IdleMinutes
+	c.JSON(200, gin.H{"idle": sc.svc.IdleInfo(serverInfo)})
+}
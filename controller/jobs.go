@@ -0,0 +1,146 @@
+// controller/jobs.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxJobList = 100
+
+// jobForRole 讀取 job 並確認目前的使用者在 job 的 server 至少有 need 角色，
+// 還沒有 server 的 job (建立) 只有送出的人看得到，失敗時已寫好回應
+func (sc *ServerController) jobForRole(c *gin.Context, need string) (*model.Job, bool) {
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("job_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid job id"})
+		return nil, false
+	}
+
+	job, err := sc.svc.Job(uint(id))
+	if err != nil {
+		if !errors.Is(err, service.ErrJobNotFound) {
+			common.LogError(c.Request.Context(), "GetJob error: "+err.Error())
+		}
+		c.JSON(404, gin.H{"error": "Job not found"})
+		return nil, false
+	}
+	if job.ServerID == "" {
+		if job.OwnerID != uintID {
+			c.JSON(404, gin.H{"error": "Job not found"})
+			return nil, false
+		}
+		return job, true
+	}
+
+	member, err := model.GetServerMember(job.ServerID, uintID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			common.LogError(c.Request.Context(), "GetServerMember error: "+err.Error())
+		}
+		c.JSON(404, gin.H{"error": "Job not found"})
+		return nil, false
+	}
+	if !model.ServerRoleAtLeast(member.Role, need) {
+		c.JSON(403, gin.H{"error": "Permission denied", "role": member.Role})
+		return nil, false
+	}
+	return job, true
+}
+
+func (sc *ServerController) ListJobs(c *gin.Context) {
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	limit := maxJobList
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l < maxJobList {
+		limit = l
+	}
+
+	jobs, err := sc.svc.ListJobs(uintID, c.Query("server_id"), limit)
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListJobs error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list jobs"})
+		return
+	}
+	c.JSON(200, gin.H{"jobs": jobs})
+}
+
+func (sc *ServerController) GetJob(c *gin.Context) {
+	job, ok := sc.jobForRole(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+	c.JSON(200, gin.H{"job": job})
+}
+
+func (sc *ServerController) CancelJob(c *gin.Context) {
+	job, ok := sc.jobForRole(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := sc.svc.CancelJob(job.ID); err != nil {
+		if errors.Is(err, service.ErrJobFinished) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(404, gin.H{"error": "Job not found"})
+		return
+	}
+	c.Status(202)
+}
+
+// StreamJob 以 SSE 推送進度，job 結束時送出最後狀態並關閉連線
+func (sc *ServerController) StreamJob(c *gin.Context) {
+	job, ok := sc.jobForRole(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	updates, unsubscribe := sc.svc.SubscribeJob(job.ID)
+	defer unsubscribe()
+
+	c.SSEvent("job", job)
+	if updates == nil {
+		return
+	}
+
+	last := *job
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case j, ok := <-updates:
+			if !ok {
+				// channel 關閉代表 job 結束，從資料庫讀最後狀態
+				if final, err := sc.svc.Job(job.ID); err == nil {
+					c.SSEvent("job", final)
+				} else {
+					c.SSEvent("job", last)
+				}
+				return false
+			}
+			last = j
+			c.SSEvent("job", j)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
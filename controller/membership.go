@@ -0,0 +1,220 @@
+// controller/membership.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/middleware"
+	"go-backend/model"
+	"go-backend/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentServer 取得 middleware.ServerAccess 解析好的 server，
+// 路由沒有掛 ServerAccess 或角色不足時一律拒絕
+func currentServer(c *gin.Context, need string) (*model.UserMinecraftServer, *model.ServerMember, bool) {
+	serverInfo, member := middleware.CurrentServer(c)
+	if serverInfo == nil {
+		common.LogError(c.Request.Context(), "server not resolved for "+c.FullPath())
+		c.JSON(403, gin.H{"error": "Permission denied"})
+		return nil, nil, false
+	}
+	if !model.ServerRoleAtLeast(member.Role, need) {
+		c.JSON(403, gin.H{"error": "Permission denied", "role": member.Role})
+		return nil, nil, false
+	}
+	return serverInfo, member, true
+}
+
+func membershipError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrMemberForbidden):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidServerRole), errors.Is(err, service.ErrInviteSelf),
+		errors.Is(err, service.ErrTransferSelf):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrMemberUserNotFound), errors.Is(err, service.ErrInviteNotFound),
+		errors.Is(err, service.ErrNotMember), errors.Is(err, service.ErrNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAlreadyMember), errors.Is(err, model.ErrInviteExpired):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		common.LogError(c.Request.Context(), action+" error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func ListMembers(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	members, err := model.ListServerMembers(serverInfo.ServerID)
+	if err != nil {
+		membershipError(c, err, "list members")
+		return
+	}
+	invites, err := model.ListServerInvites(serverInfo.ServerID)
+	if err != nil {
+		membershipError(c, err, "list members")
+		return
+	}
+	c.JSON(200, gin.H{"members": members, "invites": invites})
+}
+
+type InviteMemberRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+func InviteMember(c *gin.Context) {
+	var req InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	_, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	inv, err := service.InviteMember(member, req.Username, req.Role)
+	if err != nil {
+		membershipError(c, err, "invite member")
+		return
+	}
+	c.JSON(200, gin.H{"invite": inv})
+}
+
+func MyInvites(c *gin.Context) {
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	invites, err := model.ListInvitesFor(uintID)
+	if err != nil {
+		membershipError(c, err, "list invites")
+		return
+	}
+	c.JSON(200, gin.H{"invites": invites})
+}
+
+func inviteID(c *gin.Context) (uint, uint, bool) {
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("invite_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid invite id"})
+		return 0, 0, false
+	}
+	return uintID, uint(id), true
+}
+
+func AcceptInvite(c *gin.Context) {
+	uintID, id, ok := inviteID(c)
+	if !ok {
+		return
+	}
+
+	inv, err := service.AcceptInvite(uintID, id)
+	if err != nil {
+		membershipError(c, err, "accept invite")
+		return
+	}
+	c.JSON(200, gin.H{"message": "Invite accepted.", "server_id": inv.ServerID, "role": inv.Role})
+}
+
+// DeclineInvite 被邀請者拒絕，或 server admin 撤回邀請
+func DeclineInvite(c *gin.Context) {
+	uintID, id, ok := inviteID(c)
+	if !ok {
+		return
+	}
+
+	if err := service.DeclineInvite(uintID, id); err != nil {
+		membershipError(c, err, "decline invite")
+		return
+	}
+	c.Status(200)
+}
+
+type MemberRoleRequest struct {
+	UserID uint   `json:"user_id" binding:"required"`
+	Role   string `json:"role" binding:"required"`
+}
+
+func SetMemberRole(c *gin.Context) {
+	var req MemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	_, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := service.SetMemberRole(member, req.UserID, req.Role); err != nil {
+		membershipError(c, err, "update member")
+		return
+	}
+	c.Status(200)
+}
+
+type MemberRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// RemoveMember admin 移除成員，或成員自己離開
+func RemoveMember(c *gin.Context) {
+	var req MemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	_, member, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	if err := service.RemoveMember(member, req.UserID); err != nil {
+		membershipError(c, err, "remove member")
+		return
+	}
+	c.Status(200)
+}
+
+func TransferOwnership(c *gin.Context) {
+	var req MemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	_, member, ok := currentServer(c, model.ServerRoleOwner)
+	if !ok {
+		return
+	}
+
+	if err := service.TransferOwnership(member, req.UserID); err != nil {
+		membershipError(c, err, "transfer ownership")
+		return
+	}
+	c.JSON(200, gin.H{"message": "Ownership transferred."})
+}
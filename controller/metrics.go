@@ -0,0 +1,22 @@
+// controller/metrics.go
+
+package controller
+
+import (
+	"bytes"
+	"go-backend/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics Prometheus text format，由 middleware.MetricsAccess 限制存取
+func (sc *ServerController) Metrics(c *gin.Context) {
+	var buf bytes.Buffer
+	common.WriteMetrics(&buf)
+	if err := sc.svc.WriteMetrics(&buf); err != nil {
+		common.LogError(c.Request.Context(), "WriteMetrics error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to collect metrics"})
+		return
+	}
+	c.Data(200, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
@@ -8,11 +8,14 @@ import (
 	"go-backend/common"
 	"go-backend/model"
 	"go-backend/service"
+	"os"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
+// CreateServer 立即回傳 job，server_id 在 job 完成後的 result 裡
 func CreateServer(c *gin.Context) {
 	var req service.CreateServerRequest
 
@@ -22,28 +25,39 @@ func CreateServer(c *gin.Context) {
 		return
 	}
 
-	_, uid_str, uid_uint, err := getPayloadAndId(c)
-
-	serverID, err := service.CreateServer(uid_str, req.ServerType, req.ServerVer, req.FabricLoader, req.FabricInstaller)
+	_, _, uid_uint, err := getPayloadAndId(c)
 	if err != nil {
-		common.LogError(c.Request.Context(), "CreateMinecraftServer error: "+err.Error())
-		c.JSON(500, gin.H{"error": "Failed to create server"})
+		c.JSON(401, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	modelErr := model.AddServerToUser(uid_uint, serverID, req.DisplayName, common.MinecraftServerPath+"/"+serverID)
-	if modelErr != nil {
-		common.LogError(c.Request.Context(), "AddServerToUser error: "+modelErr.Error())
-		service.ErrorFileClear(common.MinecraftServerPath + "/" + serverID)
-		c.JSON(500, gin.H{"error": "Failed to add server to user"})
+	job, err := service.CreateServerJob(uid_uint, req)
+	if err != nil {
+		if quotaError(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrUnsupportedServerType) || errors.Is(err, service.ErrInvalidVersion) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrTemplateNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "CreateMinecraftServer error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to create server"})
 		return
 	}
 
-	c.JSON(200, gin.H{"server_id": serverID})
+	c.JSON(202, gin.H{"job_id": job.ID, "job": job})
 }
 
 func GetAllVanillaVersions(c *gin.Context) {
-	versions, err := service.GetAllVanillaVersions()
+	versions, err := service.GetAllVanillaVersions(c.Query("type"))
+	if errors.Is(err, service.ErrInvalidVersionType) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if len(versions) == 0 || err != nil {
 		c.JSON(404, gin.H{"error": ""})
 		return
@@ -51,6 +65,20 @@ func GetAllVanillaVersions(c *gin.Context) {
 	c.JSON(200, gin.H{"versions": versions})
 }
 
+func GetVanillaVersion(c *gin.Context) {
+	version, err := service.LookupVanillaVersion(c.Param("version"))
+	if err != nil {
+		if errors.Is(err, service.ErrVersionNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "LookupVanillaVersion error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get version"})
+		return
+	}
+	c.JSON(200, gin.H{"version": version})
+}
+
 func GetAllFabricVersions(c *gin.Context) {
 	versions, err := service.GetAllFabricVersions()
 	if len(versions) == 0 || err != nil {
@@ -61,6 +89,58 @@ func GetAllFabricVersions(c *gin.Context) {
 	c.JSON(200, gin.H{"versions": versions})
 }
 
+// GetFabricLoaders 沒帶 game 時列出全部 loader，latest 為建立 server 時預設使用的版本
+func GetFabricLoaders(c *gin.Context) {
+	game := c.Param("game")
+	loaders, err := service.ListFabricLoaders(game)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVersion) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "ListFabricLoaders error: "+err.Error())
+		c.JSON(502, gin.H{"error": "Failed to get fabric loaders"})
+		return
+	}
+	if len(loaders) == 0 {
+		c.JSON(404, gin.H{"error": "No fabric loader for this version"})
+		return
+	}
+	latest, _ := service.LatestFabricLoader(game)
+	c.JSON(200, gin.H{"loaders": loaders, "latest": latest})
+}
+
+func GetFabricInstallers(c *gin.Context) {
+	installers, err := service.ListFabricInstallers()
+	if err != nil || len(installers) == 0 {
+		if err != nil {
+			common.LogError(c.Request.Context(), "ListFabricInstallers error: "+err.Error())
+		}
+		c.JSON(502, gin.H{"error": "Failed to get fabric installers"})
+		return
+	}
+	latest, _ := service.LatestFabricInstaller()
+	c.JSON(200, gin.H{"installers": installers, "latest": latest})
+}
+
+func GetServerTypes(c *gin.Context) {
+	c.JSON(200, gin.H{"types": service.ServerTypeNames()})
+}
+
+func GetServerTypeVersions(c *gin.Context) {
+	versions, err := service.GetServerTypeVersions(c.Param("type"))
+	if err != nil {
+		if errors.Is(err, service.ErrUnsupportedServerType) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "GetServerTypeVersions error: "+err.Error())
+		c.JSON(502, gin.H{"error": "Failed to get versions"})
+		return
+	}
+	c.JSON(200, gin.H{"versions": versions})
+}
+
 func MyServers(c *gin.Context) {
 	_, _, uid, err := getPayloadAndId(c)
 
@@ -74,25 +154,27 @@ func MyServers(c *gin.Context) {
 	c.JSON(200, servers)
 }
 
-func DeleteServerById(c *gin.Context) {
-	serverID := c.Param("server_id")
-	if serverID == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
+// DeleteServerById 只有 owner 可以刪除，執行中的 server 要先停止
+func (sc *ServerController) DeleteServerById(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleOwner)
+	if !ok {
 		return
 	}
-
-	_, _, id_uint, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Internal Server Error: " + err.Error()})
+	serverID := serverInfo.ServerID
+	if sc.svc.IsRunning(serverID) {
+		c.JSON(409, gin.H{"error": "Stop the server before deleting it"})
 		return
 	}
 
-	err = model.RemoveServerByServerID(id_uint, serverID)
+	err := model.RemoveServerByServerID(serverInfo.OwnerID, serverID)
 	if err != nil {
 		common.LogDebug(c.Request.Context(), "RemoveServerByServerID error: "+err.Error())
 		c.JSON(500, gin.H{"error": "Failed to delete server"})
 		return
 	}
+	service.ForgetIdleServer(serverID)
+	service.ForgetProxyHosts(serverID)
+	service.ForgetUsageHistory(serverID)
 
 	c.JSON(200, gin.H{"message": "Server deleted successfully"})
 }
@@ -122,32 +204,19 @@ func getPayloadAndId(c *gin.Context) (map[string]interface{}, string, uint, erro
 // --------------------Server Controller--------------------
 
 type ServerController struct {
-	svc *service.ServerService
+	svc      *service.ServerService
+	modrinth *service.ModrinthClient
 }
 
+// SaveRollBackRequest server_id 只有舊的 /recover 路由需要，新的路由放在路徑
 type SaveRollBackRequest struct {
 	FileName string `json:"file_name" binding:"required"`
-	ServerID string `json:"server_id" binding:"required"`
+	ServerID string `json:"server_id"`
 }
 
 func (sc *ServerController) ListServerBackup(c *gin.Context) {
-	serverID := c.Param("server_id")
-	if serverID == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
-		return
-	}
-
-	_, _, uintID, err := getPayloadAndId(c)
-
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	serverInfo, err := model.GetServerByID(uintID, serverID)
-	if err != nil {
-		common.LogDebug(c.Request.Context(), "Log, GetServerByID error: "+err.Error())
-		c.JSON(500, gin.H{"error": "Server start Failed."})
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
 		return
 	}
 
@@ -168,25 +237,12 @@ type Usages struct {
 }
 
 func (sc *ServerController) ServerUsage(c *gin.Context) {
-	serverID := c.Param("server_id")
-	if serverID == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
-		return
-	}
-
-	_, _, uintId, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
 		return
 	}
 
-	if err := model.IsOwner(uintId, serverID); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
-		return
-
-	}
-
-	usage, err := sc.svc.GetServerUsage(serverID)
+	usage, err := sc.svc.GetServerUsage(serverInfo.ServerID)
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
 			c.JSON(404, gin.H{"error": "Server not found"})
@@ -208,32 +264,25 @@ func (sc *ServerController) ServerUsage(c *gin.Context) {
 func (sc *ServerController) SaveRollBack(c *gin.Context) {
 	var req SaveRollBackRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	// 舊路由的 middleware 已經讀過 body，要用 ShouldBindBodyWith
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
 		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
 		c.JSON(400, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	_, _, uintID, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	if err := model.IsOwner(uintID, req.ServerID); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
-	}
-
-	serverInfo, err := model.GetServerByID(uintID, req.ServerID)
-	if err != nil {
-		common.LogDebug(c.Request.Context(), "Log, GetServerByID error: "+err.Error())
-		c.JSON(500, gin.H{"error": "Server start Failed."})
+	serverInfo, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
 		return
 	}
 
-	err = sc.svc.RollBackSave(req.ServerID, req.FileName, serverInfo.SystemPath)
+	job, err := sc.svc.RollBackSaveJob(member.UserID, serverInfo.ServerID, req.FileName, serverInfo.SystemPath)
 
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(404, gin.H{"error": "Backup not found"})
+			return
+		}
 		if !errors.Is(err, service.ErrServerRunning) {
 			common.LogError(c.Request.Context(), "RollBackSave error: "+err.Error())
 			c.JSON(500, gin.H{"error": "Failed to save server to user"})
@@ -243,30 +292,16 @@ func (sc *ServerController) SaveRollBack(c *gin.Context) {
 		return
 	}
 
-	c.Status(200)
+	c.JSON(202, gin.H{"job_id": job.ID, "job": job})
 }
 
 func NewServerController(svc *service.ServerService) *ServerController {
-	return &ServerController{svc: svc}
+	return &ServerController{svc: svc, modrinth: service.NewModrinthClient()}
 }
 
 func (sc *ServerController) GetServerLog(c *gin.Context) {
-	serverID := c.Param("server_id")
-	if serverID == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
-		return
-	}
-
-	_, _, uintID, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	serverInfo, err := model.GetServerByID(uintID, serverID)
-	if err != nil {
-		common.LogDebug(c.Request.Context(), "Log, GetServerByID error: "+err.Error())
-		c.JSON(500, gin.H{"error": "Failed to retrieve server log"})
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
 		return
 	}
 
@@ -280,13 +315,12 @@ func (sc *ServerController) GetServerLog(c *gin.Context) {
 }
 
 func (sc *ServerController) GetStatus(c *gin.Context) {
-	// Get the server status
-	serverID := c.Param("server_id")
-	if serverID == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
 		return
 	}
-	status, err := sc.svc.Status(serverID)
+
+	status, err := sc.svc.Status(serverInfo.ServerID)
 	if err != nil {
 		common.LogDebug(c.Request.Context(), "Log, GetStatus error: "+err.Error())
 		c.JSON(500, gin.H{"error": "Failed to get server status, or server not found."})
@@ -297,30 +331,29 @@ func (sc *ServerController) GetStatus(c *gin.Context) {
 }
 
 func (sc *ServerController) Start(c *gin.Context) {
-
-	sid := c.Param("server_id")
-	if sid == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
-		return
-	}
-
-	_, oid, uintID, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
+	serverInfo, _, ok := currentServer(c, model.ServerRoleOperator)
+	if !ok {
 		return
 	}
 
-	serverInfo, err := model.GetServerByID(uintID, sid)
-	if err != nil {
-		common.LogDebug(c.Request.Context(), "Log, GetServerByID error: "+err.Error())
-		c.JSON(500, gin.H{"error": "Server start Failed."})
-		return
-	}
-
-	srv, err := sc.svc.Start(sid, oid, serverInfo.SystemPath, "2G", "1G", []string{})
+	// 以 server 的 owner 計算同時開啟的數量，不是操作的成員
+	oid := strconv.FormatUint(uint64(serverInfo.OwnerID), 10)
+	srv, err := sc.svc.Start(serverInfo.ServerID, oid, serverInfo.SystemPath, "2G", "1G", []string{})
 	if err != nil {
+		var modErr *service.ModCheckError
+		if errors.As(err, &modErr) {
+			c.JSON(409, gin.H{"error": "Mod dependency check failed", "problems": modErr.Problems})
+			return
+		}
+		if quotaError(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrServerBusy) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
 		common.LogDebug(c.Request.Context(), "Log, StartServer error: "+err.Error())
-		if !errors.Is(err, service.ErrAlreadyRunning) && !errors.Is(err, service.ErrNotFound) && !errors.Is(err, service.ErrMaxReached) {
+		if !errors.Is(err, service.ErrAlreadyRunning) && !errors.Is(err, service.ErrNotFound) {
 			common.LogError(c.Request.Context(), "Log, StartServer error: "+err.Error())
 		}
 		c.JSON(500, gin.H{"error": "Failed to start server"})
@@ -330,29 +363,15 @@ func (sc *ServerController) Start(c *gin.Context) {
 }
 
 func (sc *ServerController) Stop(c *gin.Context) {
-	sid := c.Param("server_id")
-	if sid == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
-		return
-	}
-
-	_, _, uintID, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	serverInfo, err := model.GetServerByID(uintID, sid)
-	if err != nil {
-		common.LogDebug(c.Request.Context(), "Log, GetServerByID error: "+err.Error())
-		c.JSON(500, gin.H{"error": "Server Stop Failed."})
+	serverInfo, _, ok := currentServer(c, model.ServerRoleOperator)
+	if !ok {
 		return
 	}
 
-	err = sc.svc.Stop(serverInfo.ServerID)
+	err := sc.svc.Stop(serverInfo.ServerID)
 	if err != nil {
 		common.LogDebug(c.Request.Context(), "Log, StopServer error: "+err.Error())
-		if !errors.Is(err, service.ErrAlreadyRunning) && !errors.Is(err, service.ErrNotFound) && !errors.Is(err, service.ErrMaxReached) {
+		if !errors.Is(err, service.ErrAlreadyRunning) && !errors.Is(err, service.ErrNotFound) {
 			common.LogError(c.Request.Context(), "Log, StopServer error: "+err.Error())
 		}
 		c.JSON(500, gin.H{"error": "Failed Stop Server"})
@@ -363,22 +382,8 @@ func (sc *ServerController) Stop(c *gin.Context) {
 }
 
 func (sc *ServerController) GetServerProperties(c *gin.Context) {
-	sid := c.Param("server_id")
-	if sid == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
-		return
-	}
-
-	_, _, uintID, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	serverInfo, err := model.GetServerByID(uintID, sid)
-	if err != nil {
-		common.LogDebug(c.Request.Context(), "Log, GetServerByID error: "+err.Error())
-		c.JSON(500, gin.H{"error": "Server Stop Failed."})
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
 		return
 	}
 
@@ -403,25 +408,12 @@ func (sc *ServerController) SendCommand(c *gin.Context) {
 		return
 	}
 
-	sid := c.Param("server_id")
-	if sid == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
+	serverInfo, _, ok := currentServer(c, model.ServerRoleOperator)
+	if !ok {
 		return
 	}
 
-	_, _, uintID, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	serverInfo, err := model.GetServerByID(uintID, sid)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to get server information."})
-		return
-	}
-
-	err = sc.svc.SendCommand(serverInfo.ServerID, req.Command)
+	err := sc.svc.SendCommand(serverInfo.ServerID, req.Command)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to send command to server."})
 		return
@@ -431,27 +423,17 @@ func (sc *ServerController) SendCommand(c *gin.Context) {
 }
 
 func (sc *ServerController) Backup(c *gin.Context) {
-	sid := c.Param("server_id")
-	if sid == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
-		return
-	}
-
-	_, _, uintID, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	serverInfo, err := model.GetServerByID(uintID, sid)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to get server information."})
+	serverInfo, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
 		return
 	}
 
-	err = sc.svc.Backup(serverInfo.ServerID, serverInfo.SystemPath)
+	job, err := sc.svc.BackupJob(member.UserID, serverInfo)
 
 	if err != nil {
+		if quotaError(c, err) {
+			return
+		}
 		if !errors.Is(err, service.ErrServerRunning) {
 			common.LogError(c.Request.Context(), "Backup error: "+err.Error())
 		}
@@ -460,8 +442,7 @@ func (sc *ServerController) Backup(c *gin.Context) {
 		return
 	}
 
-	c.Status(200)
-
+	c.JSON(202, gin.H{"job_id": job.ID, "job": job})
 }
 
 type UploadPropertyRequest struct {
@@ -477,25 +458,16 @@ func (sc *ServerController) UploadProperty(c *gin.Context) {
 		return
 	}
 
-	sid := c.Param("server_id")
-	if sid == "" {
-		c.JSON(400, gin.H{"error": "Server ID is required"})
+	serverInfo, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
 		return
 	}
 
-	_, _, uintID, err := getPayloadAndId(c)
-	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
+	err := sc.svc.UploadProperties(serverInfo, member.UserID, req.Texts)
+	if errors.Is(err, service.ErrPropertiesWhileRunning) {
+		c.JSON(409, gin.H{"error": err.Error()})
 		return
 	}
-
-	serverInfo, err := model.GetServerByID(uintID, sid)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Upload Error: " + err.Error()})
-		return
-	}
-
-	err = service.ReplaceProperty(serverInfo.SystemPath, req.Texts)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Upload Error: " + err.Error()})
 		return
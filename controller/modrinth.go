@@ -0,0 +1,123 @@
+// controller/modrinth.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModrinthSearchRequest struct {
+	Query  string `json:"query"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ModrinthVersionsRequest struct {
+	ProjectID string `json:"project_id" binding:"required"`
+}
+
+type ModrinthInstallRequest struct {
+	ProjectID string `json:"project_id" binding:"required"`
+	VersionID string `json:"version_id"`
+}
+
+// modServerInfo 取得 server 與它在 Modrinth 對應的 loader / minecraft 版本，need 為需要的成員角色
+func modServerInfo(c *gin.Context, need string) (*model.UserMinecraftServer, string, string, bool) {
+	serverInfo, _, ok := currentServer(c, need)
+	if !ok {
+		return nil, "", "", false
+	}
+
+	info := service.ServerVersionOf(serverInfo)
+	loader, err := service.ModLoaderFor(info.ServerType)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return nil, "", "", false
+	}
+	return serverInfo, loader, info.MCVersion, true
+}
+
+func (sc *ServerController) ModrinthSearch(c *gin.Context) {
+	var req ModrinthSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	_, loader, mcVer, ok := modServerInfo(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	result, err := sc.modrinth.Search(req.Query, mcVer, loader, req.Limit, req.Offset)
+	if err != nil {
+		common.LogError(c.Request.Context(), "Modrinth search error: "+err.Error())
+		c.JSON(502, gin.H{"error": "Failed to search mods"})
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+func (sc *ServerController) ModrinthVersions(c *gin.Context) {
+	var req ModrinthVersionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	_, loader, mcVer, ok := modServerInfo(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	versions, err := sc.modrinth.ProjectVersions(req.ProjectID, mcVer, loader)
+	if err != nil {
+		if errors.Is(err, service.ErrModrinthNotFound) {
+			c.JSON(404, gin.H{"error": "Project not found"})
+			return
+		}
+		common.LogError(c.Request.Context(), "Modrinth versions error: "+err.Error())
+		c.JSON(502, gin.H{"error": "Failed to get versions"})
+		return
+	}
+
+	c.JSON(200, gin.H{"versions": versions})
+}
+
+func (sc *ServerController) ModrinthInstall(c *gin.Context) {
+	var req ModrinthInstallRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, loader, mcVer, ok := modServerInfo(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	installed, err := sc.modrinth.Install(serverInfo.ServerID, serverInfo.SystemPath, req.ProjectID, req.VersionID, mcVer, loader)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrModrinthNotFound):
+			c.JSON(404, gin.H{"error": "Project or version not found", "installed": installed})
+		case errors.Is(err, service.ErrNoCompatibleVersion):
+			c.JSON(409, gin.H{"error": err.Error(), "installed": installed})
+		default:
+			common.LogError(c.Request.Context(), "Modrinth install error: "+err.Error())
+			c.JSON(500, gin.H{"error": "Failed to install mod", "installed": installed})
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Installed.", "installed": installed})
+}
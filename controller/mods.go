@@ -0,0 +1,186 @@
+// controller/mods.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxModUploadSize = 256 << 20 // 256MB
+
+type ModFileRequest struct {
+	FileName string `json:"file_name" binding:"required"`
+}
+
+type ToggleModRequest struct {
+	FileName string `json:"file_name" binding:"required"`
+	Enabled  bool   `json:"enabled"`
+}
+
+func (sc *ServerController) ListMods(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	mods, err := service.ListMods(serverInfo.SystemPath)
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListMods error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list mods"})
+		return
+	}
+
+	// 附上安裝來源，之後可以拿來提示更新
+	sources, err := model.GetModSources(serverInfo.ServerID)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "GetModSources error: "+err.Error())
+	}
+	bySource := make(map[string]model.ServerModSource, len(sources))
+	for _, src := range sources {
+		bySource[src.FileName] = src
+	}
+	type modWithSource struct {
+		service.ModInfo
+		Source *model.ServerModSource `json:"source,omitempty"`
+	}
+	list := make([]modWithSource, 0, len(mods))
+	for _, m := range mods {
+		item := modWithSource{ModInfo: m}
+		if src, ok := bySource[strings.TrimSuffix(m.FileName, ".disabled")]; ok {
+			item.Source = &src
+		}
+		list = append(list, item)
+	}
+
+	c.JSON(200, gin.H{"mods": list})
+}
+
+func (sc *ServerController) UploadMod(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	if fh.Size > maxModUploadSize {
+		c.JSON(413, gin.H{"error": "Mod file too large"})
+		return
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	defer f.Close()
+
+	info, err := service.SaveModJar(serverInfo.SystemPath, fh.Filename, f)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidModFile):
+			c.JSON(400, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrModExists):
+			c.JSON(409, gin.H{"error": "Mod already exists"})
+		default:
+			common.LogError(c.Request.Context(), "SaveModJar error: "+err.Error())
+			c.JSON(500, gin.H{"error": "Failed to upload mod"})
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Uploaded.", "mod": info})
+}
+
+func (sc *ServerController) RemoveMod(c *gin.Context) {
+	var req ModFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := service.RemoveMod(serverInfo.SystemPath, req.FileName); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidModFile):
+			c.JSON(400, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrModNotFound):
+			c.JSON(404, gin.H{"error": "Mod not found"})
+		default:
+			common.LogError(c.Request.Context(), "RemoveMod error: "+err.Error())
+			c.JSON(500, gin.H{"error": "Failed to remove mod"})
+		}
+		return
+	}
+
+	_ = model.RemoveModSource(serverInfo.ServerID, strings.TrimSuffix(req.FileName, ".disabled"))
+
+	c.JSON(200, gin.H{"message": "Mod removed."})
+}
+
+func (sc *ServerController) ToggleMod(c *gin.Context) {
+	var req ToggleModRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	name, err := service.SetModEnabled(serverInfo.SystemPath, req.FileName, req.Enabled)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidModFile):
+			c.JSON(400, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrModNotFound):
+			c.JSON(404, gin.H{"error": "Mod not found"})
+		default:
+			common.LogError(c.Request.Context(), "SetModEnabled error: "+err.Error())
+			c.JSON(500, gin.H{"error": "Failed to toggle mod"})
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Mod updated.", "file_name": name, "enabled": req.Enabled})
+}
+
+func (sc *ServerController) CheckMods(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	mcVer := service.ServerVersionOf(serverInfo).MCVersion
+	loaderVer := service.ReadFabricLoaderVersion(serverInfo.SystemPath)
+	problems, err := service.CheckModDependencies(serverInfo.SystemPath, mcVer, loaderVer)
+	if err != nil {
+		common.LogError(c.Request.Context(), "CheckModDependencies error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to check mods"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"minecraft_version": mcVer,
+		"loader_version":    loaderVer,
+		"ok":                len(problems) == 0,
+		"problems":          problems,
+	})
+}
@@ -0,0 +1,28 @@
+// controller/performance.go
+
+package controller
+
+import (
+	"go-backend/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPerformance 最近一次的 TPS / MSPT，沒有資料時為 null
+func (sc *ServerController) GetPerformance(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+	perf, ok := sc.svc.TickPerf(serverInfo.ServerID)
+	if !ok {
+		c.JSON(200, gin.H{"performance": nil})
+		return
+	}
+	c.JSON(200, gin.H{"performance": perf})
+}
+
+// admin method
+func (sc *ServerController) LaggingServers(c *gin.Context) {
+	c.JSON(200, gin.H{"servers": sc.svc.LaggingServers()})
+}
@@ -0,0 +1,82 @@
+// controller/ports.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func portError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrPortName), errors.Is(err, service.ErrPortProtocol), errors.Is(err, service.ErrPortReserved):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPortNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPortDuplicate):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNoFreePort):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		common.LogError(c.Request.Context(), action+" error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// ListPorts 還沒啟動過的 server 沒有 port，第一次啟動時分配
+func (sc *ServerController) ListPorts(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	ports, err := sc.svc.ListPorts(serverInfo.ServerID)
+	if err != nil {
+		portError(c, err, "list ports")
+		return
+	}
+	c.JSON(200, gin.H{"ports": ports})
+}
+
+type AddPortRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Protocol string `json:"protocol"`
+}
+
+func (sc *ServerController) AddPort(c *gin.Context) {
+	var req AddPortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	port, err := sc.svc.AddPort(serverInfo.ServerID, req.Name, req.Protocol)
+	if err != nil {
+		portError(c, err, "add port")
+		return
+	}
+	c.JSON(200, gin.H{"port": port, "restart_required": sc.svc.IsRunning(serverInfo.ServerID)})
+}
+
+func (sc *ServerController) RemovePort(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := sc.svc.RemovePort(serverInfo.ServerID, c.Param("name")); err != nil {
+		portError(c, err, "remove port")
+		return
+	}
+	c.Status(200)
+}
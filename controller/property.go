@@ -0,0 +1,102 @@
+// controller/property.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPropertySchema 回傳 server.properties 的 schema，?version= 只列出該版本有的設定
+func GetPropertySchema(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"schema_version": service.PropertySchemaVersion,
+		"properties":     service.PropertySchema(c.Query("version")),
+	})
+}
+
+func (sc *ServerController) GetTypedProperties(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	view, err := service.GetTypedProperties(serverInfo.SystemPath, service.ServerVersionOf(serverInfo).MCVersion)
+	if err != nil {
+		common.LogError(c.Request.Context(), "GetTypedProperties error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server properties."})
+		return
+	}
+	pending, err := sc.svc.PendingProperties(serverInfo.ServerID)
+	if err != nil {
+		common.LogError(c.Request.Context(), "PendingProperties error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server properties."})
+		return
+	}
+	for _, p := range pending {
+		view.Pending[p.Key] = p.Value
+	}
+	c.JSON(200, view)
+}
+
+// PatchProperties body 為 {"key": value}，value 為 null 時還原成預設值
+func (sc *ServerController) PatchProperties(c *gin.Context) {
+	var req map[string]any
+	if err := c.ShouldBindJSON(&req); err != nil || len(req) == 0 {
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	res, err := sc.svc.PatchProperties(serverInfo, member.UserID, req)
+	if err != nil {
+		var verr *service.PropertyValidationError
+		if errors.As(err, &verr) {
+			c.JSON(400, gin.H{"error": "Invalid properties", "errors": verr.Errors})
+			return
+		}
+		common.LogError(c.Request.Context(), "PatchProperties error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update server properties."})
+		return
+	}
+	c.JSON(200, res)
+}
+
+func (sc *ServerController) GetPendingProperties(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	pending, err := sc.svc.PendingProperties(serverInfo.ServerID)
+	if err != nil {
+		common.LogError(c.Request.Context(), "PendingProperties error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get pending properties."})
+		return
+	}
+	c.JSON(200, gin.H{"pending": pending})
+}
+
+// DiscardPendingProperties ?key=motd&key=pvp 只捨棄指定的 key，沒有 key 時捨棄全部
+func (sc *ServerController) DiscardPendingProperties(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	n, err := sc.svc.DiscardPendingProperties(serverInfo.ServerID, c.QueryArray("key"))
+	if err != nil {
+		common.LogError(c.Request.Context(), "DiscardPendingProperties error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to discard pending properties."})
+		return
+	}
+	c.JSON(200, gin.H{"discarded": n})
+}
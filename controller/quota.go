@@ -0,0 +1,222 @@
+// controller/quota.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quotaError 超過方案上限時回 403 並帶上是哪一項，其他錯誤回傳 false 交給呼叫端處理
+func quotaError(c *gin.Context, err error) bool {
+	var qe *service.QuotaError
+	if !errors.As(err, &qe) {
+		return false
+	}
+	c.JSON(403, gin.H{"error": qe.Error(), "quota": qe})
+	return true
+}
+
+// GetQuota 目前使用者 (以擁有的 server 計算) 的用量與上限
+func (sc *ServerController) GetQuota(c *gin.Context) {
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	usage, err := sc.svc.QuotaUsage(uintID)
+	if err != nil {
+		common.LogError(c.Request.Context(), "QuotaUsage error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get quota"})
+		return
+	}
+	c.JSON(200, gin.H{"usage": usage})
+}
+
+type QuotaPlanRequest struct {
+	Name        string `json:"name" binding:"required"`
+	MaxServers  int    `json:"max_servers" binding:"min=0"`
+	MaxRunning  int    `json:"max_running" binding:"min=0"`
+	MaxMemoryMB int64  `json:"max_memory_mb" binding:"min=0"`
+	MaxDiskMB   int64  `json:"max_disk_mb" binding:"min=0"`
+	MaxBackups  int    `json:"max_backups" binding:"min=0"`
+	MaxBackupMB int64  `json:"max_backup_mb" binding:"min=0"`
+}
+
+func (r QuotaPlanRequest) apply(p *model.QuotaPlan) {
+	p.Name = r.Name
+	p.MaxServers = r.MaxServers
+	p.MaxRunning = r.MaxRunning
+	p.MaxMemoryMB = r.MaxMemoryMB
+	p.MaxDiskMB = r.MaxDiskMB
+	p.MaxBackups = r.MaxBackups
+	p.MaxBackupMB = r.MaxBackupMB
+}
+
+func ListQuotaPlans(c *gin.Context) {
+	plans, err := model.ListQuotaPlans()
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListQuotaPlans error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list quota plans"})
+		return
+	}
+	roles, err := model.ListRoleQuotas()
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListRoleQuotas error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list quota plans"})
+		return
+	}
+	users, err := model.ListUserQuotas()
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListUserQuotas error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list quota plans"})
+		return
+	}
+	c.JSON(200, gin.H{"plans": plans, "roles": roles, "users": users})
+}
+
+func CreateQuotaPlan(c *gin.Context) {
+	var req QuotaPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	plan := &model.QuotaPlan{}
+	req.apply(plan)
+	if err := model.CreateQuotaPlan(plan); err != nil {
+		common.LogError(c.Request.Context(), "CreateQuotaPlan error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to create quota plan"})
+		return
+	}
+	c.JSON(200, gin.H{"plan": plan})
+}
+
+func quotaPlanID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid plan id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func UpdateQuotaPlan(c *gin.Context) {
+	var req QuotaPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	id, ok := quotaPlanID(c)
+	if !ok {
+		return
+	}
+
+	plan, err := model.GetQuotaPlan(id)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Quota plan not found"})
+		return
+	}
+	// 預設方案不能改名，否則沒有指定方案的使用者會找不到
+	if plan.Name == model.DefaultQuotaPlanName {
+		req.Name = model.DefaultQuotaPlanName
+	}
+	req.apply(plan)
+	if err := model.SaveQuotaPlan(plan); err != nil {
+		common.LogError(c.Request.Context(), "SaveQuotaPlan error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update quota plan"})
+		return
+	}
+	c.JSON(200, gin.H{"plan": plan})
+}
+
+func DeleteQuotaPlan(c *gin.Context) {
+	id, ok := quotaPlanID(c)
+	if !ok {
+		return
+	}
+
+	plan, err := model.GetQuotaPlan(id)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Quota plan not found"})
+		return
+	}
+	if plan.Name == model.DefaultQuotaPlanName {
+		c.JSON(400, gin.H{"error": "The default plan cannot be deleted"})
+		return
+	}
+	if err := model.DeleteQuotaPlan(id); err != nil {
+		if errors.Is(err, model.ErrQuotaPlanInUse) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "DeleteQuotaPlan error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to delete quota plan"})
+		return
+	}
+	c.Status(200)
+}
+
+type AssignQuotaRequest struct {
+	PlanID uint `json:"plan_id"` // 0 代表取消指定
+}
+
+func bindAssignQuota(c *gin.Context) (uint, bool) {
+	var req AssignQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return 0, false
+	}
+	if req.PlanID != 0 {
+		if _, err := model.GetQuotaPlan(req.PlanID); err != nil {
+			c.JSON(404, gin.H{"error": "Quota plan not found"})
+			return 0, false
+		}
+	}
+	return req.PlanID, true
+}
+
+func SetRoleQuota(c *gin.Context) {
+	role, err := strconv.Atoi(c.Param("role"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid role"})
+		return
+	}
+	planID, ok := bindAssignQuota(c)
+	if !ok {
+		return
+	}
+	if err := model.SetRoleQuota(role, planID); err != nil {
+		common.LogError(c.Request.Context(), "SetRoleQuota error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to assign quota plan"})
+		return
+	}
+	c.Status(200)
+}
+
+func SetUserQuota(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid user id"})
+		return
+	}
+	planID, ok := bindAssignQuota(c)
+	if !ok {
+		return
+	}
+	if err := model.SetUserQuota(uint(userID), planID); err != nil {
+		common.LogError(c.Request.Context(), "SetUserQuota error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to assign quota plan"})
+		return
+	}
+	c.Status(200)
+}
@@ -0,0 +1,121 @@
+// controller/template.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SaveTemplate(c *gin.Context) {
+	var req service.SaveTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	job, err := service.SaveTemplateJob(member.UserID, serverInfo, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidProperty) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "SaveTemplate error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to save template"})
+		return
+	}
+
+	c.JSON(202, gin.H{"job_id": job.ID, "job": job})
+}
+
+func ListTemplates(c *gin.Context) {
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	list, err := service.ListTemplates(uintID)
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListTemplates error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list templates"})
+		return
+	}
+	c.JSON(200, gin.H{"templates": list})
+}
+
+func DeleteTemplate(c *gin.Context) {
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid template id"})
+		return
+	}
+
+	if err := service.DeleteTemplate(uintID, uint(id)); err != nil {
+		if errors.Is(err, service.ErrTemplateNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "DeleteTemplate error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	c.Status(200)
+}
+
+type DuplicateServerRequest struct {
+	DisplayName string `json:"display_name"`
+}
+
+// Duplicate 複製整個 server (含 world) 到新的 server ID
+func (sc *ServerController) Duplicate(c *gin.Context) {
+	var req DuplicateServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	displayName := req.DisplayName
+	if displayName == "" {
+		displayName = serverInfo.DisplayName + " (copy)"
+	}
+
+	job, err := sc.svc.DuplicateJob(member.UserID, serverInfo, displayName)
+	if err != nil {
+		if quotaError(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrServerRunning) {
+			c.JSON(409, gin.H{"error": "Cannot duplicate while server is running"})
+			return
+		}
+		common.LogError(c.Request.Context(), "Duplicate error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to duplicate server"})
+		return
+	}
+
+	c.JSON(202, gin.H{"job_id": job.ID, "job": job})
+}
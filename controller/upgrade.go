@@ -0,0 +1,46 @@
+// controller/upgrade.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (sc *ServerController) Upgrade(c *gin.Context) {
+	var req service.UpgradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	serverInfo, member, ok := currentServer(c, model.ServerRoleAdmin)
+	if !ok {
+		return
+	}
+
+	job, err := sc.svc.UpgradeJob(member.UserID, serverInfo.ServerID, serverInfo.SystemPath, req)
+	if err != nil {
+		var warnErr *service.UpgradeWarningError
+		switch {
+		case errors.As(err, &warnErr):
+			c.JSON(409, gin.H{"error": err.Error(), "warnings": warnErr.Warnings})
+		case errors.Is(err, service.ErrUpgradeWhileRunning), errors.Is(err, service.ErrDowngrade), errors.Is(err, service.ErrSameVersion):
+			c.JSON(409, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidVersion), errors.Is(err, service.ErrBuildNotFound):
+			c.JSON(400, gin.H{"error": err.Error()})
+		default:
+			common.LogError(c.Request.Context(), "Upgrade error: "+err.Error())
+			r_id := c.Request.Context().Value(common.RequestIdKey)
+			c.JSON(500, gin.H{"error": "Failed to upgrade server. Request id: " + r_id.(string)})
+		}
+		return
+	}
+
+	c.JSON(202, gin.H{"job_id": job.ID, "job": job})
+}
@@ -0,0 +1,58 @@
+// controller/usageHistory.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"go-backend/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseTimeQuery 接受 RFC3339 或 unix 秒數，沒有帶時回傳 def
+func parseTimeQuery(c *gin.Context, key string, def time.Time) (time.Time, error) {
+	v := c.Query(key)
+	if v == "" {
+		return def, nil
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// UsageHistory ?from=&to= (預設最近 1 小時) 與 ?resolution=raw|1m|1h|auto
+func UsageHistory(c *gin.Context) {
+	serverInfo, _, ok := currentServer(c, model.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	to, err := parseTimeQuery(c, "to", now)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to"})
+		return
+	}
+	from, err := parseTimeQuery(c, "from", to.Add(-time.Hour))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from"})
+		return
+	}
+
+	points, resolution, err := service.UsageHistory(serverInfo.ServerID, from, to, c.DefaultQuery("resolution", service.UsageAuto))
+	if err != nil {
+		if errors.Is(err, service.ErrUsageRange) || errors.Is(err, service.ErrUsageResolution) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "UsageHistory error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get usage history"})
+		return
+	}
+	c.JSON(200, gin.H{"from": from, "to": to, "resolution": resolution, "points": points})
+}
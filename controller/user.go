@@ -58,6 +58,7 @@ func Login(c *gin.Context) {
 
 	if err != nil {
 		if err.Error() == "record not found" {
+			common.LoginFailures.Inc("user_not_found")
 			c.JSON(401, gin.H{"error": "User not found"})
 		} else {
 			c.JSON(500, gin.H{"error": "Internal server error"})
@@ -68,6 +69,7 @@ func Login(c *gin.Context) {
 	v := common.ValidatePasswordAndHash(req.Password+user.Salt, user.Password)
 
 	if !v {
+		common.LoginFailures.Inc("password")
 		_ = model.RecordAttempt(clientIP, false)
 		c.JSON(401, gin.H{"error": "Invalid password"})
 		return
@@ -237,6 +239,7 @@ func VerifyLogin(c *gin.Context) {
 	}
 
 	if code != req.Code || time.Since(sendAt) > 5*time.Minute {
+		common.LoginFailures.Inc("verification_code")
 		_ = model.RecordAttempt(clientIP, false)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired verification code"})
 		return
@@ -3,6 +3,7 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"go-backend/common"
 	"go-backend/middleware"
@@ -13,6 +14,7 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -25,6 +27,11 @@ import (
 var shutdownChan = make(chan struct{}, 1)
 
 func main() {
+	// server 的 sandbox 也是這個執行檔，必須在讀取參數與 .env 之前處理
+	service.RunSandboxIfRequested()
+
+	flag.Parse()
+
 	// .env config load
 	err := godotenv.Load(".env")
 	if err != nil {
@@ -48,6 +55,24 @@ func main() {
 	if err != nil {
 		common.FatalLog("failed to init DB: " + err.Error())
 	}
+
+	// --populate-mirror 只填充 mirror 目錄，完成後直接結束
+	if *common.PopulateMirror != "" {
+		var versions []string
+		if *common.MirrorVersions != "" {
+			versions = strings.Split(*common.MirrorVersions, ",")
+		}
+		var mods []string
+		if *common.MirrorMods != "" {
+			mods = strings.Split(*common.MirrorMods, ",")
+		}
+		if err := service.PopulateMirror(*common.PopulateMirror, versions, mods); err != nil {
+			common.FatalLog("failed to populate mirror: " + err.Error())
+		}
+		common.SysLog("mirror populated: " + *common.PopulateMirror)
+		return
+	}
+
 	errUpLog := model.InitUpdateLogTable()
 	if errUpLog != nil {
 		common.FatalLog("failed to init update log table: " + errUpLog.Error())
@@ -65,6 +90,16 @@ func main() {
 
 	}
 
+	if err := service.BackfillServerVersions(); err != nil {
+		common.SysError("failed to backfill server versions: " + err.Error())
+	}
+	if err := model.BackfillServerOwners(); err != nil {
+		common.SysError("failed to backfill server owners: " + err.Error())
+	}
+	if err := model.EnsureDefaultQuotaPlan(); err != nil {
+		common.SysError("failed to create default quota plan: " + err.Error())
+	}
+
 	// check root user
 	err = model.CheckRootUser()
 	if err != nil {
@@ -89,6 +124,7 @@ func main() {
 
 	server.Use(middleware.RequestId())
 	middleware.SetUpLogger(server)
+	server.Use(middleware.Metrics())
 
 	// init session store
 	store := cookie.NewStore([]byte(common.SessionSecret))
@@ -110,6 +146,7 @@ func main() {
 		port = strconv.Itoa(*common.Port)
 	}
 
+	service.StartVersionCatalogSync(time.Duration(common.VersionSyncInterval) * time.Hour)
 	service.StartUpdateChecker(3*24*time.Hour, func() {
 		err := restartApplication()
 		if err != nil {
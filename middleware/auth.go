@@ -28,6 +28,7 @@ func GloabalIPFilter() gin.HandlerFunc {
 		}
 
 		if isBanned {
+			common.BannedRequests.Inc()
 			common.LogDebug(c.Request.Context(), "Blocked IP: "+ip)
 			c.AbortWithStatus(403)
 			return
@@ -42,7 +43,9 @@ func GloabalIPFilter() gin.HandlerFunc {
 		}
 		if fails >= FailLimit {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many login attempts."})
-			_ = model.BanIP(ip, "Too many login or verify attempts")
+			if err := model.BanIP(ip, "Too many login or verify attempts"); err == nil {
+				common.IPBans.Inc()
+			}
 			return
 		}
 
@@ -125,3 +128,23 @@ func ValidateJWT() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminJWT 以 JWT cookie 內的 user_id 檢查管理員權限，需放在 ValidateJWT 之後
+func AdminJWT() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, ok := jwtUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		role, err := model.GetRole(uid)
+		if err != nil || role < common.RoleAdminUser {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+
+		c.Set("user", uid)
+		c.Next()
+	}
+}
@@ -2,6 +2,7 @@
 package middleware
 
 import (
+	"go-backend/common"
 	"net/http"
 	"sync"
 	"time"
@@ -80,6 +81,7 @@ func IpRateLimiter(maxRequestNum int, duration int64) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		ip := "" + c.ClientIP()
 		if !rl.Request(ip, maxRequestNum, duration) {
+			common.RateLimitRejections.Inc(c.FullPath())
 			c.AbortWithStatus(http.StatusTooManyRequests)
 		}
 		c.Next()
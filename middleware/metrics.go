@@ -0,0 +1,84 @@
+// middleware/metrics.go
+
+package middleware
+
+import (
+	"crypto/subtle"
+	"go-backend/common"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 依路由 (不含參數值) 與狀態碼統計請求數量與延遲
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		common.HTTPRequests.Inc(method, route, strconv.Itoa(c.Writer.Status()))
+		common.HTTPDuration.Observe(time.Since(start).Seconds(), method, route)
+	}
+}
+
+// MetricsAccess Bearer token 或 METRICS_ALLOW_IPS 其中之一符合即可，兩者都沒設定時只允許本機
+func MetricsAccess() gin.HandlerFunc {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(common.MetricsAllowIPs, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if strings.Contains(item, ":") {
+				item += "/128"
+			} else {
+				item += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			common.SysError("invalid METRICS_ALLOW_IPS entry: " + item)
+			continue
+		}
+		nets = append(nets, n)
+	}
+
+	return func(c *gin.Context) {
+		if !common.MetricsEnabled {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if token := common.MetricsToken; token != "" {
+			auth := c.GetHeader("Authorization")
+			if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		// 使用連線的來源位址，X-Forwarded-For 等 header 可以任意偽造
+		ip := net.ParseIP(c.RemoteIP())
+		if ip != nil {
+			if len(nets) == 0 && common.MetricsToken == "" && ip.IsLoopback() {
+				c.Next()
+				return
+			}
+			for _, n := range nets {
+				if n.Contains(ip) {
+					c.Next()
+					return
+				}
+			}
+		}
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
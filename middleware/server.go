@@ -0,0 +1,100 @@
+// middleware/server.go
+
+package middleware
+
+import (
+	"go-backend/common"
+	"go-backend/model"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	ctxServerKey       = "server"
+	ctxServerMemberKey = "server_member"
+)
+
+// jwtUserID 從 JWT cookie 取出 user_id
+func jwtUserID(c *gin.Context) (uint, bool) {
+	token, err := c.Cookie(common.JwtCookieName)
+	if err != nil || token == "" {
+		return 0, false
+	}
+	payload, err := common.GetJWTPayload(token)
+	if err != nil {
+		return 0, false
+	}
+	rawUID, _ := payload["user_id"].(string)
+	uid, err := strconv.ParseUint(rawUID, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(uid), true
+}
+
+// ServerAccess 解析路徑的 :server_id 與目前使用者，確認成員角色至少為 need，
+// 通過後把 server 與成員資料放進 context，handler 用 CurrentServer 取得
+func ServerAccess(need string) gin.HandlerFunc {
+	return serverAccess(need, func(c *gin.Context) string {
+		return c.Param("server_id")
+	})
+}
+
+// ServerAccessFromBody 給舊的路由使用，server_id 放在 JSON body
+// body 會被快取，handler 要用 ShouldBindBodyWith 讀取
+func ServerAccessFromBody(need string) gin.HandlerFunc {
+	return serverAccess(need, func(c *gin.Context) string {
+		var body struct {
+			ServerID string `json:"server_id"`
+		}
+		_ = c.ShouldBindBodyWith(&body, binding.JSON)
+		return body.ServerID
+	})
+}
+
+func serverAccess(need string, serverID func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sid := serverID(c)
+		if sid == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Server ID is required"})
+			return
+		}
+
+		uid, ok := jwtUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		// 不是成員時回 404，避免洩漏 server 是否存在
+		srv, member, err := model.GetServerForMember(uid, sid)
+		if err != nil {
+			common.LogDebug(c.Request.Context(), "GetServerForMember error: "+err.Error())
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+			return
+		}
+		if !model.ServerRoleAtLeast(member.Role, need) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied", "role": member.Role})
+			return
+		}
+
+		c.Set(ctxServerKey, srv)
+		c.Set(ctxServerMemberKey, member)
+		c.Next()
+	}
+}
+
+// CurrentServer 取得 ServerAccess 放進 context 的 server，沒有經過 ServerAccess 時回傳 nil
+func CurrentServer(c *gin.Context) (*model.UserMinecraftServer, *model.ServerMember) {
+	srv, _ := c.Get(ctxServerKey)
+	member, _ := c.Get(ctxServerMemberKey)
+	s, _ := srv.(*model.UserMinecraftServer)
+	m, _ := member.(*model.ServerMember)
+	if s == nil || m == nil {
+		return nil, nil
+	}
+	return s, m
+}
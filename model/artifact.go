@@ -0,0 +1,58 @@
+// model/artifact.go
+
+package model
+
+import (
+	"time"
+)
+
+// Artifact 本機快取的 server jar / installer
+type Artifact struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ServerType string    `gorm:"size:20;uniqueIndex:idx_artifact_key" json:"server_type"`
+	Version    string    `gorm:"size:40;uniqueIndex:idx_artifact_key" json:"version"`
+	Loader     string    `gorm:"size:80;uniqueIndex:idx_artifact_key" json:"loader"`
+	Name       string    `gorm:"size:100;uniqueIndex:idx_artifact_key" json:"name"`
+	HashAlgo   string    `gorm:"size:10" json:"hash_algo"`
+	Hash       string    `gorm:"size:128" json:"hash"`
+	Path       string    `gorm:"size:255" json:"-"`
+	Size       int64     `json:"size"`
+	SourceURL  string    `gorm:"size:255" json:"source_url"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+func GetArtifact(serverType, version, loader, name string) (*Artifact, error) {
+	var a Artifact
+	err := DB.Where("server_type = ? AND version = ? AND loader = ? AND name = ?", serverType, version, loader, name).First(&a).Error
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func GetArtifactByID(id uint) (*Artifact, error) {
+	var a Artifact
+	if err := DB.First(&a, id).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func SaveArtifact(a *Artifact) error {
+	return DB.Save(a).Error
+}
+
+func TouchArtifact(id uint) error {
+	return DB.Model(&Artifact{}).Where("id = ?", id).Update("last_used_at", time.Now()).Error
+}
+
+func ListArtifacts() ([]Artifact, error) {
+	var list []Artifact
+	err := DB.Order("server_type, version desc").Find(&list).Error
+	return list, err
+}
+
+func DeleteArtifact(id uint) error {
+	return DB.Delete(&Artifact{}, id).Error
+}
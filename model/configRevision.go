@@ -0,0 +1,81 @@
+// model/configRevision.go
+
+package model
+
+import (
+	"time"
+)
+
+// ConfigRevision 設定檔 (server.properties、ops.json 等) 每次變更的完整內容與 diff
+type ConfigRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ServerID  string    `gorm:"size:64;index:idx_config_rev_file" json:"server_id"`
+	File      string    `gorm:"size:64;index:idx_config_rev_file" json:"file"`
+	AuthorID  uint      `json:"author_id"` // 0 代表不是透過 API 修改 (遊戲內指令或手動修改)
+	Message   string    `gorm:"size:200" json:"message"`
+	Hash      string    `gorm:"size:64" json:"hash"`
+	Size      int64     `json:"size"`
+	Content   string    `gorm:"type:text" json:"content,omitempty"`
+	Diff      string    `gorm:"type:text" json:"diff,omitempty"` // 與上一個 revision 的 unified diff
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ConfigRevisionInfo 列表用，不含內容，附上作者名稱
+type ConfigRevisionInfo struct {
+	ID        uint      `json:"id"`
+	ServerID  string    `json:"server_id"`
+	File      string    `json:"file"`
+	AuthorID  uint      `json:"author_id"`
+	Username  string    `json:"username"`
+	Message   string    `json:"message"`
+	Hash      string    `json:"hash"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func CreateConfigRevision(r *ConfigRevision) error {
+	return DB.Create(r).Error
+}
+
+func GetConfigRevision(serverID string, id uint) (*ConfigRevision, error) {
+	var r ConfigRevision
+	if err := DB.Where("server_id = ? AND id = ?", serverID, id).First(&r).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// LatestConfigRevision 取得檔案最新的 revision，沒有時回傳 gorm.ErrRecordNotFound
+func LatestConfigRevision(serverID, file string) (*ConfigRevision, error) {
+	var r ConfigRevision
+	if err := DB.Where("server_id = ? AND file = ?", serverID, file).Order("id desc").First(&r).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListConfigRevisions file 為空時列出所有檔案
+func ListConfigRevisions(serverID, file string, limit int) ([]ConfigRevisionInfo, error) {
+	var list []ConfigRevisionInfo
+	q := DB.Table("config_revisions").
+		Select("config_revisions.id, config_revisions.server_id, config_revisions.file, config_revisions.author_id, "+
+			"users.username, config_revisions.message, config_revisions.hash, config_revisions.size, config_revisions.created_at").
+		Joins("LEFT JOIN users ON users.id = config_revisions.author_id").
+		Where("config_revisions.server_id = ?", serverID)
+	if file != "" {
+		q = q.Where("config_revisions.file = ?", file)
+	}
+	err := q.Order("config_revisions.id desc").Limit(limit).Scan(&list).Error
+	return list, err
+}
+
+// PruneConfigRevisions 每個檔案只保留最新的 keep 筆
+func PruneConfigRevisions(serverID, file string, keep int) error {
+	var ids []uint
+	err := DB.Model(&ConfigRevision{}).Where("server_id = ? AND file = ?", serverID, file).
+		Order("id desc").Offset(keep).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return DB.Where("id IN ?", ids).Delete(&ConfigRevision{}).Error
+}
@@ -0,0 +1,48 @@
+// model/diskUsage.go
+
+package model
+
+import (
+	"time"
+)
+
+// DiskUsage server 資料夾每次掃描的結果 (bytes)，保留歷史紀錄觀察成長
+type DiskUsage struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	ServerID  string    `gorm:"size:64;index:idx_disk_usage_server" json:"server_id"`
+	Total     int64     `json:"total"`
+	World     int64     `json:"world"`
+	Backups   int64     `json:"backups"`
+	Mods      int64     `json:"mods"`
+	Logs      int64     `json:"logs"`
+	Other     int64     `json:"other"`
+	Files     int64     `json:"files"`
+	ScannedAt time.Time `gorm:"index:idx_disk_usage_server" json:"scanned_at"`
+}
+
+func CreateDiskUsage(u *DiskUsage) error {
+	return DB.Create(u).Error
+}
+
+// LatestDiskUsages 每個 server 最新的一筆，啟動時用來填入快取
+func LatestDiskUsages() ([]DiskUsage, error) {
+	var list []DiskUsage
+	err := DB.Where("id IN (?)", DB.Model(&DiskUsage{}).Select("MAX(id)").Group("server_id")).Find(&list).Error
+	return list, err
+}
+
+func ListDiskUsage(serverID string, since time.Time) ([]DiskUsage, error) {
+	var list []DiskUsage
+	err := DB.Where("server_id = ? AND scanned_at >= ?", serverID, since).Order("scanned_at").Find(&list).Error
+	return list, err
+}
+
+// PruneDiskUsage 刪除 before 之前的紀錄
+func PruneDiskUsage(before time.Time) error {
+	return DB.Where("scanned_at < ?", before).Delete(&DiskUsage{}).Error
+}
+
+// SetServerDiskLimit limitMB 為 0 時使用預設值
+func SetServerDiskLimit(serverID string, limitMB int64) error {
+	return DB.Model(&UserMinecraftServer{}).Where("server_id = ?", serverID).Update("disk_limit_mb", limitMB).Error
+}
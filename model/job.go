@@ -0,0 +1,81 @@
+// model/job.go
+
+package model
+
+import (
+	"time"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+// Job 長時間操作 (建立、備份、還原、升級) 的紀錄
+type Job struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Kind       string     `gorm:"size:20;index" json:"kind"`
+	ServerID   string     `gorm:"size:64;index" json:"server_id"`
+	OwnerID    uint       `gorm:"index" json:"owner_id"`
+	Status     string     `gorm:"size:16;index" json:"status"`
+	Step       string     `gorm:"size:255" json:"step"`
+	Done       int64      `json:"done"`
+	Total      int64      `json:"total"`
+	Unit       string     `gorm:"size:10" json:"unit"` // bytes, files
+	Error      string     `gorm:"size:1000" json:"error,omitempty"`
+	Result     string     `gorm:"type:text" json:"result,omitempty"` // JSON
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func (j *Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+func CreateJob(job *Job) error {
+	return DB.Create(job).Error
+}
+
+func SaveJob(job *Job) error {
+	return DB.Save(job).Error
+}
+
+func GetJob(id uint) (*Job, error) {
+	var job Job
+	if err := DB.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs 新到舊，包含使用者是成員的 server 的 job，以及自己送出、還沒有 server 的 job (建立)
+// serverID 為空代表全部
+func ListJobs(userID uint, serverID string, limit int) ([]Job, error) {
+	var jobs []Job
+	memberOf := DB.Model(&ServerMember{}).Select("server_id").Where("user_id = ?", userID)
+	q := DB.Where("((server_id = '' AND owner_id = ?) OR server_id IN (?))", userID, memberOf)
+	if serverID != "" {
+		q = q.Where("server_id = ?", serverID)
+	}
+	err := q.Order("id desc").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// FailInterruptedJobs 程式重啟時，上次沒跑完的 job 標記為失敗
+func FailInterruptedJobs() error {
+	now := time.Now()
+	return DB.Model(&Job{}).Where("status IN ?", []string{JobQueued, JobRunning}).Updates(map[string]interface{}{
+		"status":      JobFailed,
+		"error":       "interrupted by backend restart",
+		"finished_at": &now,
+	}).Error
+}
+
+// PruneJobs 刪除 before 之前結束的 job
+func PruneJobs(before time.Time) error {
+	return DB.Where("finished_at < ?", before).Delete(&Job{}).Error
+}
@@ -62,6 +62,13 @@ func IsIPBanned(ip string) (bool, error) {
 	return count > 0, nil
 }
 
+// CountBannedIPs 目前封鎖中的 IP 數量
+func CountBannedIPs() (int64, error) {
+	var count int64
+	err := DB.Model(&BlockedIP{}).Count(&count).Error
+	return count, err
+}
+
 // BanIP 封鎖指定 IP，並記錄原因
 func BanIP(ip, reason string) error {
 	blocked := BlockedIP{
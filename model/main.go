@@ -88,6 +88,23 @@ func migrateDB() error {
 		&LoginAttempt{},
 		&Book{},
 		&UpdateLog{},
+		&ServerModSource{},
+		&MinecraftVersion{},
+		&Artifact{},
+		&Job{},
+		&ServerTemplate{},
+		&ServerMember{},
+		&ServerInvite{},
+		&ConfigRevision{},
+		&PendingProperty{},
+		&ServerPort{},
+		&QuotaPlan{},
+		&RoleQuota{},
+		&UserQuota{},
+		&DiskUsage{},
+		&ServerUser{},
+		&ServerHost{},
+		&UsageAggregate{},
 	)
 
 	if err != nil {
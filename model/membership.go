@@ -0,0 +1,189 @@
+// model/membership.go
+
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// server 成員角色，權限由高到低
+const (
+	ServerRoleOwner    = "owner"    // 全部權限
+	ServerRoleAdmin    = "admin"    // 除了刪除與轉移擁有權以外全部
+	ServerRoleOperator = "operator" // 啟動、停止、console
+	ServerRoleViewer   = "viewer"   // 狀態與 log
+)
+
+var serverRoleRank = map[string]int{
+	ServerRoleViewer:   1,
+	ServerRoleOperator: 2,
+	ServerRoleAdmin:    3,
+	ServerRoleOwner:    4,
+}
+
+var ErrInviteExpired = errors.New("invite expired")
+
+// ServerRoleAtLeast role 是否具有 need 以上的權限
+func ServerRoleAtLeast(role, need string) bool {
+	r, ok := serverRoleRank[role]
+	return ok && r >= serverRoleRank[need]
+}
+
+func ValidServerRole(role string) bool {
+	_, ok := serverRoleRank[role]
+	return ok
+}
+
+type ServerMember struct {
+	ServerID  string    `gorm:"primaryKey;size:32;not null" json:"server_id"`
+	UserID    uint      `gorm:"primaryKey;not null;index" json:"user_id"`
+	Role      string    `gorm:"size:16;not null" json:"role"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ServerMemberInfo 成員列表用，附上使用者名稱
+type ServerMemberInfo struct {
+	ServerMember
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+// ServerInvite 邀請使用者加入 server，被邀請者接受後才會成為成員
+type ServerInvite struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ServerID  string    `gorm:"size:32;index;not null" json:"server_id"`
+	InviterID uint      `gorm:"not null" json:"inviter_id"`
+	InviteeID uint      `gorm:"index;not null" json:"invitee_id"`
+	Role      string    `gorm:"size:16;not null" json:"role"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func GetServerMember(serverID string, userID uint) (*ServerMember, error) {
+	var m ServerMember
+	if err := DB.Where("server_id = ? AND user_id = ?", serverID, userID).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetServerForMember 取得使用者可存取的 server 與他的成員資料
+func GetServerForMember(userID uint, serverID string) (*UserMinecraftServer, *ServerMember, error) {
+	m, err := GetServerMember(serverID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv, err := GetServerRecord(serverID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return srv, m, nil
+}
+
+func ListServerMembers(serverID string) ([]ServerMemberInfo, error) {
+	var list []ServerMemberInfo
+	err := DB.Table("server_members").
+		Select("server_members.*, users.username, users.display_name").
+		Joins("LEFT JOIN users ON users.id = server_members.user_id").
+		Where("server_members.server_id = ?", serverID).
+		Order("server_members.created_at").
+		Scan(&list).Error
+	return list, err
+}
+
+func SetServerMemberRole(serverID string, userID uint, role string) error {
+	return DB.Model(&ServerMember{}).Where("server_id = ? AND user_id = ?", serverID, userID).Update("role", role).Error
+}
+
+func RemoveServerMember(serverID string, userID uint) error {
+	return DB.Where("server_id = ? AND user_id = ?", serverID, userID).Delete(&ServerMember{}).Error
+}
+
+func CreateServerInvite(inv *ServerInvite) error {
+	return DB.Create(inv).Error
+}
+
+func GetServerInvite(id uint) (*ServerInvite, error) {
+	var inv ServerInvite
+	if err := DB.First(&inv, id).Error; err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// ListInvitesFor 使用者收到且還沒過期的邀請
+func ListInvitesFor(userID uint) ([]ServerInvite, error) {
+	var list []ServerInvite
+	err := DB.Where("invitee_id = ? AND expires_at > ?", userID, time.Now()).Order("id desc").Find(&list).Error
+	return list, err
+}
+
+func ListServerInvites(serverID string) ([]ServerInvite, error) {
+	var list []ServerInvite
+	err := DB.Where("server_id = ? AND expires_at > ?", serverID, time.Now()).Order("id desc").Find(&list).Error
+	return list, err
+}
+
+func DeleteServerInvite(id uint) error {
+	return DB.Delete(&ServerInvite{}, id).Error
+}
+
+// AcceptServerInvite 接受邀請：建立或更新成員角色並刪除邀請 (原本是 owner 的不會被降級)
+func AcceptServerInvite(inv *ServerInvite) error {
+	if time.Now().After(inv.ExpiresAt) {
+		return ErrInviteExpired
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var m ServerMember
+		err := tx.Where("server_id = ? AND user_id = ?", inv.ServerID, inv.InviteeID).First(&m).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			m = ServerMember{ServerID: inv.ServerID, UserID: inv.InviteeID, Role: inv.Role}
+			if err := tx.Create(&m).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case m.Role != ServerRoleOwner:
+			if err := tx.Model(&m).Update("role", inv.Role).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&ServerInvite{}, inv.ID).Error
+	})
+}
+
+// TransferServerOwnership 新 owner 必須已經是成員，原 owner 降為 admin
+func TransferServerOwnership(serverID string, fromID, toID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var m ServerMember
+		if err := tx.Where("server_id = ? AND user_id = ?", serverID, toID).First(&m).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&UserMinecraftServer{}).Where("server_id = ? AND owner_id = ?", serverID, fromID).
+			Update("owner_id", toID)
+		if res.Error != nil {
+			return res.Error
+		}
+		// server 已被刪除或 fromID 不是 owner 時不能只改成員角色
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Model(&ServerMember{}).Where("server_id = ? AND user_id = ?", serverID, fromID).
+			Update("role", ServerRoleAdmin).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ServerMember{}).Where("server_id = ? AND user_id = ?", serverID, toID).
+			Update("role", ServerRoleOwner).Error
+	})
+}
+
+// BackfillServerOwners 舊資料沒有成員紀錄時，補上 owner
+func BackfillServerOwners() error {
+	return DB.Exec(`INSERT INTO server_members (server_id, user_id, role, created_at)
+		SELECT s.server_id, s.owner_id, ?, s.created_at FROM user_minecraft_servers s
+		WHERE NOT EXISTS (SELECT 1 FROM server_members m WHERE m.server_id = s.server_id)`, ServerRoleOwner).Error
+}
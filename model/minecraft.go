@@ -4,29 +4,76 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type UserMinecraftServer struct {
-	OwnerID     uint      `gorm:"primaryKey;not null" json:"owner_id"`
-	DisplayName string    `gorm:"size:100;not null" json:"display_name"`
-	ServerID    string    `gorm:"primaryKey;size:32;not null" json:"server_id"`
-	SystemPath  string    `gorm:"size:255;not null" json:"system_path"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	OwnerID     uint   `gorm:"primaryKey;not null" json:"owner_id"`
+	DisplayName string `gorm:"size:100;not null" json:"display_name"`
+	ServerID    string `gorm:"primaryKey;size:32;not null" json:"server_id"`
+	SystemPath  string `gorm:"size:255;not null" json:"system_path"`
+	// 目前的類型與版本，升級後會和 server ID 內的版本不同
+	ServerType    string    `gorm:"size:20" json:"server_type"`
+	MCVersion     string    `gorm:"column:mc_version;size:40" json:"mc_version"`
+	LoaderVersion string    `gorm:"size:60" json:"loader_version"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	DiskLimitMB   int64     `gorm:"default:0" json:"disk_limit_mb"` // 0 代表使用 SERVER_DISK_LIMIT_MB
+	IdleMinutes   int       `gorm:"default:0" json:"idle_minutes"`  // 0 代表使用 IDLE_SHUTDOWN_MINUTES，-1 代表不自動關閉
+	// 查詢成員的 server 列表時帶出的角色，不存進這張表
+	Role string `gorm:"->;-:migration" json:"role,omitempty"`
 }
 
-func AddServerToUser(userID uint, serverID, displayName string, systemPath string) error {
+func AddServerToUser(userID uint, serverID, displayName string, systemPath string, serverType, mcVersion, loaderVersion string) error {
 	userServer := UserMinecraftServer{
-		OwnerID:     userID,
-		ServerID:    serverID,
-		DisplayName: displayName,
-		SystemPath:  systemPath,
+		OwnerID:       userID,
+		ServerID:      serverID,
+		DisplayName:   displayName,
+		SystemPath:    systemPath,
+		ServerType:    serverType,
+		MCVersion:     mcVersion,
+		LoaderVersion: loaderVersion,
 	}
-	return DB.Create(&userServer).Error
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&userServer).Error; err != nil {
+			return err
+		}
+		return tx.Create(&ServerMember{ServerID: serverID, UserID: userID, Role: ServerRoleOwner}).Error
+	})
+}
+
+// GetServerRecord 不檢查擁有者，只給內部流程使用
+func GetServerRecord(serverID string) (*UserMinecraftServer, error) {
+	var server UserMinecraftServer
+	err := DB.Where("server_id = ?", serverID).First(&server).Error
+	if err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// GetServersMissingVersion 找出舊資料 (還沒有 server_type / mc_version 欄位) 用來補資料
+func GetServersMissingVersion() ([]UserMinecraftServer, error) {
+	var servers []UserMinecraftServer
+	err := DB.Where("server_type = ? OR server_type IS NULL OR mc_version = ? OR mc_version IS NULL", "", "").Find(&servers).Error
+	return servers, err
 }
 
+func UpdateServerVersion(serverID, serverType, mcVersion, loaderVersion string) error {
+	return DB.Model(&UserMinecraftServer{}).Where("server_id = ?", serverID).Updates(map[string]interface{}{
+		"server_type":    serverType,
+		"mc_version":     mcVersion,
+		"loader_version": loaderVersion,
+	}).Error
+}
+
+// GetUserServers 使用者是成員 (含 owner) 的所有 server
 func GetUserServers(userID uint) ([]UserMinecraftServer, error) {
 	var servers []UserMinecraftServer
-	err := DB.Where("owner_id = ?", userID).Find(&servers).Error
+	err := DB.Select("user_minecraft_servers.*, server_members.role AS role").
+		Joins("JOIN server_members ON server_members.server_id = user_minecraft_servers.server_id").
+		Where("server_members.user_id = ?", userID).
+		Find(&servers).Error
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +89,37 @@ func IsOwner(userID uint, serverID string) error {
 }
 
 func RemoveServerByServerID(userID uint, serverID string) error {
-	return DB.Where("owner_id = ? AND server_id = ?", userID, serverID).Delete(&UserMinecraftServer{}).Error
+	return DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("owner_id = ? AND server_id = ?", userID, serverID).Delete(&UserMinecraftServer{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		if err := tx.Where("server_id = ?", serverID).Delete(&ServerMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("server_id = ?", serverID).Delete(&ServerInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("server_id = ?", serverID).Delete(&ConfigRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("server_id = ?", serverID).Delete(&PendingProperty{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("server_id = ?", serverID).Delete(&ServerPort{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("server_id = ?", serverID).Delete(&DiskUsage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("server_id = ?", serverID).Delete(&ServerUser{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("server_id = ?", serverID).Delete(&ServerHost{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("server_id = ?", serverID).Delete(&UsageAggregate{}).Error
+	})
 }
 
 func GetServerByID(userID uint, serverID string) (*UserMinecraftServer, error) {
@@ -53,3 +130,15 @@ func GetServerByID(userID uint, serverID string) (*UserMinecraftServer, error) {
 	}
 	return &server, nil
 }
+
+// GetAllServers 給背景工作 (例如磁碟掃描) 使用
+func GetAllServers() ([]UserMinecraftServer, error) {
+	var servers []UserMinecraftServer
+	err := DB.Find(&servers).Error
+	return servers, err
+}
+
+// SetServerIdleMinutes minutes 為 0 時使用預設值，-1 代表不自動關閉
+func SetServerIdleMinutes(serverID string, minutes int) error {
+	return DB.Model(&UserMinecraftServer{}).Where("server_id = ?", serverID).Update("idle_minutes", minutes).Error
+}
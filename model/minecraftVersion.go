@@ -0,0 +1,56 @@
+// model/minecraftVersion.go
+
+package model
+
+import (
+	"time"
+)
+
+// MinecraftVersion 從 Mojang version manifest 同步下來的版本資料
+type MinecraftVersion struct {
+	ID           string    `gorm:"primaryKey;size:40" json:"id"`
+	Type         string    `gorm:"size:20;index" json:"type"` // release, snapshot, old_beta, old_alpha
+	ReleaseTime  time.Time `gorm:"index" json:"release_time"`
+	ManifestSHA1 string    `gorm:"size:40" json:"-"` // version json 的 sha1，用來判斷是否需要重新同步
+	ServerURL    string    `gorm:"size:255" json:"server_url"`
+	ServerSHA1   string    `gorm:"size:40" json:"server_sha1"`
+	ServerSize   int64     `json:"server_size"`
+	JavaMajor    int       `json:"java_major"`
+	SyncedAt     time.Time `json:"synced_at"`
+}
+
+func SaveMinecraftVersion(v *MinecraftVersion) error {
+	return DB.Save(v).Error
+}
+
+func GetMinecraftVersion(id string) (*MinecraftVersion, error) {
+	var v MinecraftVersion
+	if err := DB.Where("id = ?", id).First(&v).Error; err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ListMinecraftVersions 依發佈時間新到舊，types 為空代表全部
+func ListMinecraftVersions(types ...string) ([]MinecraftVersion, error) {
+	var versions []MinecraftVersion
+	q := DB.Order("release_time desc")
+	if len(types) > 0 {
+		q = q.Where("type IN ?", types)
+	}
+	err := q.Find(&versions).Error
+	return versions, err
+}
+
+// GetMinecraftVersionHashes id -> manifest sha1，增量同步用
+func GetMinecraftVersionHashes() (map[string]string, error) {
+	var rows []MinecraftVersion
+	if err := DB.Select("id", "manifest_sha1").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string, len(rows))
+	for _, r := range rows {
+		hashes[r.ID] = r.ManifestSHA1
+	}
+	return hashes, nil
+}
@@ -0,0 +1,43 @@
+// model/mods.go
+
+package model
+
+import (
+	"time"
+)
+
+// ServerModSource 記錄 mods 資料夾內的 jar 是從哪個 project / version 安裝的
+// FileName 存的是啟用時的檔名 (不含 .disabled)
+type ServerModSource struct {
+	ServerID      string    `gorm:"primaryKey;size:32;not null" json:"server_id"`
+	FileName      string    `gorm:"primaryKey;size:255;not null" json:"file_name"`
+	Source        string    `gorm:"size:20;not null" json:"source"`
+	ProjectID     string    `gorm:"size:32;index" json:"project_id"`
+	VersionID     string    `gorm:"size:32" json:"version_id"`
+	VersionNumber string    `gorm:"size:100" json:"version_number"`
+	SHA512        string    `gorm:"size:128" json:"sha512"`
+	InstalledAt   time.Time `gorm:"autoCreateTime" json:"installed_at"`
+}
+
+func SaveModSource(src *ServerModSource) error {
+	return DB.Save(src).Error
+}
+
+func GetModSources(serverID string) ([]ServerModSource, error) {
+	var list []ServerModSource
+	err := DB.Where("server_id = ?", serverID).Find(&list).Error
+	return list, err
+}
+
+func GetModSourceByProject(serverID, projectID string) (*ServerModSource, error) {
+	var src ServerModSource
+	err := DB.Where("server_id = ? AND project_id = ?", serverID, projectID).First(&src).Error
+	if err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
+
+func RemoveModSource(serverID, fileName string) error {
+	return DB.Where("server_id = ? AND file_name = ?", serverID, fileName).Delete(&ServerModSource{}).Error
+}
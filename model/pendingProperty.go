@@ -0,0 +1,62 @@
+// model/pendingProperty.go
+
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PendingProperty server 執行中修改的 server.properties 值，下次停止或啟動時寫入
+type PendingProperty struct {
+	ServerID  string    `gorm:"primaryKey;size:64" json:"server_id"`
+	Key       string    `gorm:"primaryKey;size:100" json:"key"`
+	Value     string    `gorm:"type:text" json:"value"`
+	AuthorID  uint      `json:"author_id"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// StagePendingProperties 同一個 key 再次修改時覆蓋舊的值
+func StagePendingProperties(serverID string, authorID uint, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	rows := make([]PendingProperty, 0, len(values))
+	for k, v := range values {
+		rows = append(rows, PendingProperty{ServerID: serverID, Key: k, Value: v, AuthorID: authorID})
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "server_id"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "author_id", "updated_at"}),
+	}).Create(&rows).Error
+}
+
+func ListPendingProperties(serverID string) ([]PendingProperty, error) {
+	var list []PendingProperty
+	err := DB.Where("server_id = ?", serverID).Order("updated_at").Find(&list).Error
+	return list, err
+}
+
+// DeletePendingProperties keys 為空時刪除全部
+func DeletePendingProperties(serverID string, keys ...string) (int64, error) {
+	q := DB.Where("server_id = ?", serverID)
+	if len(keys) > 0 {
+		q = q.Where("key IN ?", keys)
+	}
+	res := q.Delete(&PendingProperty{})
+	return res.RowsAffected, res.Error
+}
+
+// TakePendingProperties 取出並刪除，給套用時使用，避免同一筆被套用兩次
+func TakePendingProperties(serverID string) ([]PendingProperty, error) {
+	var list []PendingProperty
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("server_id = ?", serverID).Order("updated_at").Find(&list).Error; err != nil {
+			return err
+		}
+		return tx.Where("server_id = ?", serverID).Delete(&PendingProperty{}).Error
+	})
+	return list, err
+}
@@ -0,0 +1,161 @@
+// model/quota.go
+
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultQuotaPlanName 沒有指定方案的使用者使用這個方案
+const DefaultQuotaPlanName = "default"
+
+var ErrQuotaPlanInUse = errors.New("quota plan is still assigned")
+
+// QuotaPlan 資源上限，0 代表不限制
+type QuotaPlan struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:50;uniqueIndex" json:"name"`
+	MaxServers  int       `json:"max_servers"`   // 建立的 server 數量
+	MaxRunning  int       `json:"max_running"`   // 同時執行的 server 數量
+	MaxMemoryMB int64     `json:"max_memory_mb"` // 執行中 server 的記憶體總和 (-Xmx)
+	MaxDiskMB   int64     `json:"max_disk_mb"`   // 所有 server 資料夾 (含備份) 的大小
+	MaxBackups  int       `json:"max_backups"`   // 所有 server 的備份數量
+	MaxBackupMB int64     `json:"max_backup_mb"` // 所有備份的大小
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// RoleQuota 依使用者角色 (common.Role*) 指定方案
+type RoleQuota struct {
+	Role   int  `gorm:"primaryKey;autoIncrement:false" json:"role"`
+	PlanID uint `json:"plan_id"`
+}
+
+// UserQuota 指定單一使用者的方案，優先於角色
+type UserQuota struct {
+	UserID uint `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	PlanID uint `json:"plan_id"`
+}
+
+// EnsureDefaultQuotaPlan 第一次啟動時建立預設方案，沿用原本同時最多 3 個 server 的限制
+func EnsureDefaultQuotaPlan() error {
+	var count int64
+	if err := DB.Model(&QuotaPlan{}).Where("name = ?", DefaultQuotaPlanName).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return DB.Create(&QuotaPlan{Name: DefaultQuotaPlanName, MaxRunning: 3}).Error
+}
+
+func ListQuotaPlans() ([]QuotaPlan, error) {
+	var list []QuotaPlan
+	err := DB.Order("id").Find(&list).Error
+	return list, err
+}
+
+func GetQuotaPlan(id uint) (*QuotaPlan, error) {
+	var p QuotaPlan
+	if err := DB.First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func CreateQuotaPlan(p *QuotaPlan) error {
+	return DB.Create(p).Error
+}
+
+func SaveQuotaPlan(p *QuotaPlan) error {
+	return DB.Save(p).Error
+}
+
+// DeleteQuotaPlan 還有角色或使用者在使用時不能刪除
+func DeleteQuotaPlan(id uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var roles, users int64
+		if err := tx.Model(&RoleQuota{}).Where("plan_id = ?", id).Count(&roles).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&UserQuota{}).Where("plan_id = ?", id).Count(&users).Error; err != nil {
+			return err
+		}
+		if roles+users > 0 {
+			return ErrQuotaPlanInUse
+		}
+		return tx.Delete(&QuotaPlan{}, id).Error
+	})
+}
+
+func ListRoleQuotas() ([]RoleQuota, error) {
+	var list []RoleQuota
+	err := DB.Order("role").Find(&list).Error
+	return list, err
+}
+
+func ListUserQuotas() ([]UserQuota, error) {
+	var list []UserQuota
+	err := DB.Order("user_id").Find(&list).Error
+	return list, err
+}
+
+// SetRoleQuota planID 為 0 時改回預設方案
+func SetRoleQuota(role int, planID uint) error {
+	if planID == 0 {
+		return DB.Where("role = ?", role).Delete(&RoleQuota{}).Error
+	}
+	return DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&RoleQuota{Role: role, PlanID: planID}).Error
+}
+
+// SetUserQuota planID 為 0 時改回依角色決定
+func SetUserQuota(userID, planID uint) error {
+	if planID == 0 {
+		return DB.Where("user_id = ?", userID).Delete(&UserQuota{}).Error
+	}
+	return DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&UserQuota{UserID: userID, PlanID: planID}).Error
+}
+
+// ResolveQuotaPlan 依序使用：使用者的方案、角色的方案、預設方案
+func ResolveQuotaPlan(userID uint) (*QuotaPlan, error) {
+	var uq UserQuota
+	if err := DB.Where("user_id = ?", userID).Limit(1).Find(&uq).Error; err != nil {
+		return nil, err
+	}
+	if uq.PlanID != 0 {
+		if p, err := GetQuotaPlan(uq.PlanID); err == nil {
+			return p, nil
+		}
+	}
+
+	role, err := GetRole(userID)
+	if err != nil {
+		return nil, err
+	}
+	var rq RoleQuota
+	if err := DB.Where("role = ?", role).Limit(1).Find(&rq).Error; err != nil {
+		return nil, err
+	}
+	if rq.PlanID != 0 {
+		if p, err := GetQuotaPlan(rq.PlanID); err == nil {
+			return p, nil
+		}
+	}
+
+	var p QuotaPlan
+	if err := DB.Where("name = ?", DefaultQuotaPlanName).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetServersByOwner 使用者擁有 (不含被分享) 的 server
+func GetServersByOwner(ownerID uint) ([]UserMinecraftServer, error) {
+	var servers []UserMinecraftServer
+	err := DB.Where("owner_id = ?", ownerID).Find(&servers).Error
+	return servers, err
+}
@@ -0,0 +1,54 @@
+// model/serverHost.go
+
+package model
+
+import (
+	"time"
+)
+
+// ServerHost 反向代理用的 hostname，一個 server 可以有多個
+type ServerHost struct {
+	Host      string    `gorm:"primaryKey;size:253" json:"host"`
+	ServerID  string    `gorm:"index;size:64" json:"server_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func ListServerHosts(serverID string) ([]ServerHost, error) {
+	var list []ServerHost
+	err := DB.Where("server_id = ?", serverID).Order("host").Find(&list).Error
+	return list, err
+}
+
+// AllServerHosts hostname -> server ID
+func AllServerHosts() (map[string]string, error) {
+	var list []ServerHost
+	if err := DB.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	hosts := make(map[string]string, len(list))
+	for _, h := range list {
+		hosts[h.Host] = h.ServerID
+	}
+	return hosts, nil
+}
+
+// GetServerHost 沒有紀錄時回傳 nil
+func GetServerHost(host string) (*ServerHost, error) {
+	var list []ServerHost
+	if err := DB.Where("host = ?", host).Limit(1).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return &list[0], nil
+}
+
+func CreateServerHost(h *ServerHost) error {
+	return DB.Create(h).Error
+}
+
+func DeleteServerHost(serverID, host string) (int64, error) {
+	res := DB.Where("server_id = ? AND host = ?", serverID, host).Delete(&ServerHost{})
+	return res.RowsAffected, res.Error
+}
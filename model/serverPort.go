@@ -0,0 +1,50 @@
+// model/serverPort.go
+
+package model
+
+import (
+	"time"
+)
+
+const (
+	PortGame  = "game"
+	PortRcon  = "rcon"
+	PortQuery = "query"
+)
+
+// ServerPort 每個 server 固定使用的 port，game 以外是 rcon、query 或 plugin (例如 voice chat) 的 port
+type ServerPort struct {
+	ServerID  string    `gorm:"primaryKey;size:64" json:"server_id"`
+	Name      string    `gorm:"primaryKey;size:32" json:"name"`
+	Port      int       `gorm:"uniqueIndex" json:"port"`
+	Protocol  string    `gorm:"size:8" json:"protocol"` // tcp 或 udp
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func ListServerPorts(serverID string) ([]ServerPort, error) {
+	var list []ServerPort
+	err := DB.Where("server_id = ?", serverID).Order("port").Find(&list).Error
+	return list, err
+}
+
+// UsedPorts 所有 server 已經分配的 port
+func UsedPorts() (map[int]string, error) {
+	var list []ServerPort
+	if err := DB.Select("server_id", "port").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	used := make(map[int]string, len(list))
+	for _, p := range list {
+		used[p.Port] = p.ServerID
+	}
+	return used, nil
+}
+
+func SaveServerPort(p *ServerPort) error {
+	return DB.Save(p).Error
+}
+
+func DeleteServerPort(serverID, name string) (int64, error) {
+	res := DB.Where("server_id = ? AND name = ?", serverID, name).Delete(&ServerPort{})
+	return res.RowsAffected, res.Error
+}
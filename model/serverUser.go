@@ -0,0 +1,48 @@
+// model/serverUser.go
+
+package model
+
+import (
+	"time"
+)
+
+// ServerUser 隔離模式為 per-server 時，每個 server 固定使用的 UID (GID 相同)
+type ServerUser struct {
+	ServerID  string    `gorm:"primaryKey;size:64" json:"server_id"`
+	UID       int       `gorm:"uniqueIndex" json:"uid"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// GetServerUser 沒有紀錄時回傳 nil
+func GetServerUser(serverID string) (*ServerUser, error) {
+	var list []ServerUser
+	if err := DB.Where("server_id = ?", serverID).Limit(1).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return &list[0], nil
+}
+
+// UsedServerUIDs 所有 server 已經分配的 UID
+func UsedServerUIDs() (map[int]string, error) {
+	var list []ServerUser
+	if err := DB.Select("server_id", "uid").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	used := make(map[int]string, len(list))
+	for _, u := range list {
+		used[u.UID] = u.ServerID
+	}
+	return used, nil
+}
+
+func SaveServerUser(u *ServerUser) error {
+	return DB.Save(u).Error
+}
+
+// DeleteServerUser 建立失敗的 server 釋放已分配的 UID
+func DeleteServerUser(serverID string) error {
+	return DB.Where("server_id = ?", serverID).Delete(&ServerUser{}).Error
+}
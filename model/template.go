@@ -0,0 +1,49 @@
+// model/template.go
+
+package model
+
+import (
+	"time"
+)
+
+// ServerTemplate 從既有 server 存下來的 jar、mods 與設定檔 (不含 world)
+type ServerTemplate struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OwnerID        uint      `gorm:"index" json:"owner_id"`
+	Name           string    `gorm:"size:100" json:"name"`
+	Description    string    `gorm:"size:500" json:"description"`
+	ServerType     string    `gorm:"size:20" json:"server_type"`
+	MCVersion      string    `gorm:"column:mc_version;size:40" json:"mc_version"`
+	LoaderVersion  string    `gorm:"size:60" json:"loader_version"`
+	SourceServerID string    `gorm:"size:64" json:"source_server_id"`
+	Properties     string    `gorm:"type:text" json:"-"` // JSON，建立 server 時覆寫到 server.properties
+	Path           string    `gorm:"size:255" json:"-"`
+	Size           int64     `json:"size"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func CreateTemplate(t *ServerTemplate) error {
+	return DB.Create(t).Error
+}
+
+func SaveTemplate(t *ServerTemplate) error {
+	return DB.Save(t).Error
+}
+
+func GetTemplate(ownerID, id uint) (*ServerTemplate, error) {
+	var t ServerTemplate
+	if err := DB.Where("owner_id = ? AND id = ?", ownerID, id).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func ListTemplates(ownerID uint) ([]ServerTemplate, error) {
+	var list []ServerTemplate
+	err := DB.Where("owner_id = ?", ownerID).Order("id desc").Find(&list).Error
+	return list, err
+}
+
+func DeleteTemplate(ownerID, id uint) error {
+	return DB.Where("owner_id = ? AND id = ?", ownerID, id).Delete(&ServerTemplate{}).Error
+}
@@ -0,0 +1,59 @@
+// model/usageAggregate.go
+
+package model
+
+import (
+	"time"
+)
+
+const (
+	UsageMinute = "1m"
+	UsageHour   = "1h"
+)
+
+// UsageAggregate 一段時間 (1 分鐘或 1 小時) 內資源用量的最小 / 平均 / 最大值，
+// 沒有取得玩家人數或 TPS / MSPT 時對應欄位為 -1
+type UsageAggregate struct {
+	ID          uint      `gorm:"primaryKey" json:"-"`
+	ServerID    string    `gorm:"size:64;index:idx_usage_bucket,priority:1" json:"server_id"`
+	Resolution  string    `gorm:"size:4;index:idx_usage_bucket,priority:2" json:"resolution"`
+	BucketStart time.Time `gorm:"index:idx_usage_bucket,priority:3" json:"bucket_start"`
+	Samples     int       `json:"samples"`
+	CPUMin      float64   `json:"cpu_min"`
+	CPUAvg      float64   `json:"cpu_avg"`
+	CPUMax      float64   `json:"cpu_max"`
+	RSSMin      float64   `json:"rss_min"`
+	RSSAvg      float64   `json:"rss_avg"`
+	RSSMax      float64   `json:"rss_max"`
+	ThreadsMin  float64   `json:"threads_min"`
+	ThreadsAvg  float64   `json:"threads_avg"`
+	ThreadsMax  float64   `json:"threads_max"`
+	PlayersMin  float64   `json:"players_min"`
+	PlayersAvg  float64   `json:"players_avg"`
+	PlayersMax  float64   `json:"players_max"`
+	TPSMin      float64   `gorm:"column:tps_min;default:-1" json:"tps_min"`
+	TPSAvg      float64   `gorm:"column:tps_avg;default:-1" json:"tps_avg"`
+	TPSMax      float64   `gorm:"column:tps_max;default:-1" json:"tps_max"`
+	MSPTMin     float64   `gorm:"column:mspt_min;default:-1" json:"mspt_min"`
+	MSPTAvg     float64   `gorm:"column:mspt_avg;default:-1" json:"mspt_avg"`
+	MSPTMax     float64   `gorm:"column:mspt_max;default:-1" json:"mspt_max"`
+}
+
+func CreateUsageAggregates(list []UsageAggregate) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return DB.Create(&list).Error
+}
+
+func ListUsageAggregates(serverID, resolution string, from, to time.Time) ([]UsageAggregate, error) {
+	var list []UsageAggregate
+	err := DB.Where("server_id = ? AND resolution = ? AND bucket_start >= ? AND bucket_start < ?", serverID, resolution, from, to).
+		Order("bucket_start").Find(&list).Error
+	return list, err
+}
+
+// PruneUsageAggregates 刪除 resolution 在 before 之前的紀錄
+func PruneUsageAggregates(resolution string, before time.Time) error {
+	return DB.Where("resolution = ? AND bucket_start < ?", resolution, before).Delete(&UsageAggregate{}).Error
+}
@@ -6,7 +6,9 @@ import (
 	"go-backend/common"
 	"go-backend/controller"
 	"go-backend/middleware"
+	"go-backend/model"
 	"go-backend/service"
+	"time"
 
 	// "go-backend/middleware"
 
@@ -15,11 +17,23 @@ import (
 )
 
 func SetAPIRouter(router *gin.Engine) {
-	pl := common.GetPortList(30000, 30050)
+	ports := service.NewPortAllocator(common.ServerPortStart, common.ServerPortEnd, common.ServerCompanionPorts)
 
-	mgr := service.NewServerManager(pl)
+	mgr := service.NewServerManager(ports)
 	svc := service.NewServerService(mgr)
+	svc.StartDiskScanner(time.Duration(common.DiskScanInterval) * time.Minute)
+	svc.StartIdleWatcher(time.Duration(common.IdleCheckInterval) * time.Second)
+	svc.StartUsageRecorder(time.Duration(common.UsageSampleInterval) * time.Second)
+	if common.TPSPollInterval > 0 {
+		svc.StartTickMonitor(time.Duration(common.TPSPollInterval) * time.Second)
+	}
+	if common.ProxyEnabled {
+		if err := svc.StartProxy(common.ProxyListen); err != nil {
+			common.SysError("failed to start minecraft proxy: " + err.Error())
+		}
+	}
 	c := controller.NewServerController(svc)
+	router.GET("/metrics", middleware.MetricsAccess(), c.Metrics)
 	router.Use(middleware.CORS())
 	mcapi := router.Group("/mc-api")
 	mcapi.Use(gzip.Gzip(gzip.DefaultCompression),
@@ -29,22 +43,107 @@ func SetAPIRouter(router *gin.Engine) {
 	)
 	{
 		mcapi.GET("/finfo", controller.GetAllFabricVersions)
+		mcapi.GET("/finfo/loaders", controller.GetFabricLoaders)
+		mcapi.GET("/finfo/loaders/:game", controller.GetFabricLoaders)
+		mcapi.GET("/finfo/installers", controller.GetFabricInstallers)
 		mcapi.GET("/vinfo", controller.GetAllVanillaVersions)
+		mcapi.GET("/vinfo/:version", controller.GetVanillaVersion)
+		mcapi.GET("/tinfo", controller.GetServerTypes)
+		mcapi.GET("/tinfo/:type", controller.GetServerTypeVersions)
+		mcapi.GET("/property-schema", controller.GetPropertySchema)
 	}
 	amcapi := mcapi.Group("/a")
 	amcapi.Use(middleware.ValidateJWT())
 	{
 		amcapi.POST("/create", controller.CreateServer)
-		amcapi.POST("/backup/:server_id", c.Backup)
-		amcapi.POST("/status/:server_id", c.GetStatus)
-		amcapi.POST("/stop/:server_id", c.Stop)
-		amcapi.POST("/start/:server_id", c.Start)
-		amcapi.POST("/ls-backup/:server_id", c.ListServerBackup)
-		amcapi.POST("/property/:server_id", c.GetServerProperties)
-		amcapi.POST("/UploadProperty/:server_id", c.UploadProperty)
-		amcapi.POST("/cmd/:server_id", c.SendCommand)
-		amcapi.GET("/usage/:server_id", c.ServerUsage)
-		amcapi.POST("/recover", c.SaveRollBack)
+		amcapi.GET("/jobs", c.ListJobs)
+		amcapi.GET("/job/:job_id", c.GetJob)
+		amcapi.POST("/job-cancel/:job_id", c.CancelJob)
+		amcapi.GET("/job-stream/:job_id", c.StreamJob)
+		amcapi.GET("/templates", controller.ListTemplates)
+		amcapi.DELETE("/template/:id", controller.DeleteTemplate)
+		amcapi.GET("/invites", controller.MyInvites)
+		amcapi.GET("/quota", c.GetQuota)
+		amcapi.POST("/invite-accept/:invite_id", controller.AcceptInvite)
+		amcapi.POST("/invite-decline/:invite_id", controller.DeclineInvite)
+		// 舊的路由，server_id 在 body
+		amcapi.POST("/recover", middleware.ServerAccessFromBody(model.ServerRoleAdmin), c.SaveRollBack)
+	}
+	// 以下路由由 ServerAccess 解析 :server_id 並檢查成員角色
+	viewer := amcapi.Group("", middleware.ServerAccess(model.ServerRoleViewer))
+	{
+		viewer.POST("/status/:server_id", c.GetStatus)
+		viewer.POST("/ls-backup/:server_id", c.ListServerBackup)
+		viewer.GET("/ports/:server_id", c.ListPorts)
+		viewer.GET("/disk/:server_id", controller.GetDiskUsage)
+		viewer.GET("/idle/:server_id", c.GetIdle)
+		viewer.GET("/hostnames/:server_id", c.ListHostnames)
+		viewer.GET("/usage/:server_id", c.ServerUsage)
+		viewer.GET("/usage-history/:server_id", controller.UsageHistory)
+		viewer.GET("/performance/:server_id", c.GetPerformance)
+		viewer.POST("/ls-mods/:server_id", c.ListMods)
+		viewer.POST("/mod-check/:server_id", c.CheckMods)
+		viewer.POST("/modrinth-search/:server_id", c.ModrinthSearch)
+		viewer.POST("/modrinth-versions/:server_id", c.ModrinthVersions)
+		viewer.GET("/members/:server_id", controller.ListMembers)
+		viewer.POST("/member-remove/:server_id", controller.RemoveMember)
+	}
+	operator := amcapi.Group("", middleware.ServerAccess(model.ServerRoleOperator))
+	{
+		operator.POST("/start/:server_id", c.Start)
+		operator.POST("/stop/:server_id", c.Stop)
+		operator.POST("/cmd/:server_id", c.SendCommand)
+	}
+	// server.properties 與設定檔歷史包含 rcon.password、ops 與封鎖名單，只給 admin 以上
+	serverAdmin := amcapi.Group("", middleware.ServerAccess(model.ServerRoleAdmin))
+	{
+		serverAdmin.POST("/property/:server_id", c.GetServerProperties)
+		serverAdmin.GET("/properties/:server_id", c.GetTypedProperties)
+		serverAdmin.GET("/properties-pending/:server_id", c.GetPendingProperties)
+		serverAdmin.GET("/config-revisions/:server_id", controller.ListConfigRevisions)
+		serverAdmin.GET("/config-revision/:server_id/:revision_id", controller.GetConfigRevision)
+		serverAdmin.GET("/config-diff/:server_id", controller.DiffConfigRevisions)
+		serverAdmin.POST("/backup/:server_id", c.Backup)
+		serverAdmin.POST("/recover/:server_id", c.SaveRollBack)
+		serverAdmin.POST("/UploadProperty/:server_id", c.UploadProperty)
+		serverAdmin.PATCH("/properties/:server_id", c.PatchProperties)
+		serverAdmin.DELETE("/properties-pending/:server_id", c.DiscardPendingProperties)
+		serverAdmin.POST("/config-rollback/:server_id", c.RollbackConfig)
+		serverAdmin.POST("/port/:server_id", c.AddPort)
+		serverAdmin.DELETE("/port/:server_id/:name", c.RemovePort)
+		serverAdmin.POST("/disk-scan/:server_id", controller.ScanDiskUsage)
+		serverAdmin.PUT("/idle/:server_id", c.SetIdlePolicy)
+		serverAdmin.POST("/hostname/:server_id", c.AddHostname)
+		serverAdmin.DELETE("/hostname/:server_id/:hostname", c.RemoveHostname)
+		serverAdmin.POST("/mod-upload/:server_id", c.UploadMod)
+		serverAdmin.POST("/mod-remove/:server_id", c.RemoveMod)
+		serverAdmin.POST("/mod-toggle/:server_id", c.ToggleMod)
+		serverAdmin.POST("/modrinth-install/:server_id", c.ModrinthInstall)
+		serverAdmin.POST("/upgrade/:server_id", c.Upgrade)
+		serverAdmin.POST("/template/:server_id", controller.SaveTemplate)
+		serverAdmin.POST("/duplicate/:server_id", c.Duplicate)
+		serverAdmin.POST("/invite/:server_id", controller.InviteMember)
+		serverAdmin.POST("/member-role/:server_id", controller.SetMemberRole)
+	}
+	owner := amcapi.Group("", middleware.ServerAccess(model.ServerRoleOwner))
+	{
+		owner.POST("/transfer/:server_id", controller.TransferOwnership)
+		owner.DELETE("/server/:server_id", c.DeleteServerById)
+	}
+	admin := amcapi.Group("/admin")
+	admin.Use(middleware.AdminJWT())
+	{
+		admin.GET("/artifacts", controller.ListArtifacts)
+		admin.POST("/artifacts/prefetch", controller.PrefetchArtifact)
+		admin.DELETE("/artifacts/:id", controller.EvictArtifact)
+		admin.GET("/quota-plans", controller.ListQuotaPlans)
+		admin.POST("/quota-plans", controller.CreateQuotaPlan)
+		admin.PUT("/quota-plans/:id", controller.UpdateQuotaPlan)
+		admin.DELETE("/quota-plans/:id", controller.DeleteQuotaPlan)
+		admin.PUT("/quota-roles/:role", controller.SetRoleQuota)
+		admin.PUT("/quota-users/:user_id", controller.SetUserQuota)
+		admin.PUT("/disk-limit/:server_id", controller.SetServerDiskLimit)
+		admin.GET("/lagging", c.LaggingServers)
 	}
 	sapi := router.Group("/server-api")
 	sapi.Use(gzip.Gzip(gzip.DefaultCompression),
@@ -54,7 +153,7 @@ func SetAPIRouter(router *gin.Engine) {
 	asapi := sapi.Group("/a")
 	asapi.Use(middleware.ValidateJWT())
 	{
-		asapi.GET("/log/:server_id", c.GetServerLog)
+		asapi.GET("/log/:server_id", middleware.ServerAccess(model.ServerRoleViewer), c.GetServerLog)
 	}
 
 	testApi := router.Group("/test-api")
@@ -63,12 +162,12 @@ func SetAPIRouter(router *gin.Engine) {
 	)
 	{
 		testApi.POST("/mc-server/create", controller.CreateServer)
-		testApi.POST("/status/:server_id", c.GetStatus)
-		testApi.POST("/startmyserver/:server_id", c.Start)
-		testApi.POST("/stopmyserver/:server_id", c.Stop)
-		testApi.POST("/:server_id/property", c.GetServerProperties)
-		testApi.POST("/:server_id/Upproperty", c.UploadProperty)
-		testApi.GET("/:server_id/bc", c.Backup)
+		testApi.POST("/status/:server_id", middleware.ServerAccess(model.ServerRoleViewer), c.GetStatus)
+		testApi.POST("/startmyserver/:server_id", middleware.ServerAccess(model.ServerRoleOperator), c.Start)
+		testApi.POST("/stopmyserver/:server_id", middleware.ServerAccess(model.ServerRoleOperator), c.Stop)
+		testApi.POST("/:server_id/property", middleware.ServerAccess(model.ServerRoleAdmin), c.GetServerProperties)
+		testApi.POST("/:server_id/Upproperty", middleware.ServerAccess(model.ServerRoleAdmin), c.UploadProperty)
+		testApi.GET("/:server_id/bc", middleware.ServerAccess(model.ServerRoleAdmin), c.Backup)
 	}
 
 	client := mcapi.Group("/client")
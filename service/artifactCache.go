@@ -0,0 +1,415 @@
+// service/artifactCache.go
+
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	artifactRetries         = 3
+	artifactDownloadTimeout = 10 * time.Minute
+)
+
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// ArtifactKey 快取的唯一鍵，Loader 沒有時為空字串
+type ArtifactKey struct {
+	ServerType string `json:"server_type"`
+	Version    string `json:"version"`
+	Loader     string `json:"loader"`
+	Name       string `json:"name"`
+}
+
+func (k ArtifactKey) String() string {
+	return strings.Join([]string{k.ServerType, k.Version, k.Loader, k.Name}, "/")
+}
+
+// ArtifactSource 下載來源，上游沒有提供 hash 時 HashAlgo 為空，下載後以 sha256 記錄
+type ArtifactSource struct {
+	URL      string
+	HashAlgo string // sha1, sha256, sha512, md5
+	Hash     string
+}
+
+// ArtifactSpec provider 解析出的安裝檔
+type ArtifactSpec struct {
+	Key    ArtifactKey
+	Source ArtifactSource
+	Loader string // 實際安裝的 loader / build 版本
+}
+
+// artifactCall 進行中的下載，不屬於任何一個 job；最後一個等待者離開時才取消
+type artifactCall struct {
+	done   chan struct{}
+	art    *model.Artifact
+	err    error
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	waiters map[*JobContext]int
+	refs    int
+	total   int64
+	unit    string
+	written int64
+}
+
+// join 加入等待，並把目前的進度補給這個 job
+func (c *artifactCall) join(jc *JobContext) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refs++
+	if jc == nil {
+		return
+	}
+	c.waiters[jc]++
+	if c.unit != "" {
+		jc.SetTotal(c.total, c.unit)
+		jc.Add(c.written)
+	}
+}
+
+// leave 等待者離開；沒有人在等時取消下載，之後的請求會重新下載
+func (c *artifactCall) leave(key ArtifactKey, jc *JobContext) {
+	artifactMu.Lock()
+	defer artifactMu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refs--
+	if jc != nil {
+		if c.waiters[jc]--; c.waiters[jc] <= 0 {
+			delete(c.waiters, jc)
+		}
+	}
+	if c.refs == 0 {
+		c.cancel()
+		if artifactCalls[key] == c {
+			delete(artifactCalls, key)
+		}
+	}
+}
+
+func (c *artifactCall) jobs() []*JobContext {
+	list := make([]*JobContext, 0, len(c.waiters))
+	for jc := range c.waiters {
+		list = append(list, jc)
+	}
+	return list
+}
+
+// SetTotal 與 Add 將下載進度回報給所有等待中的 job
+func (c *artifactCall) SetTotal(total int64, unit string) {
+	c.mu.Lock()
+	c.total, c.unit, c.written = total, unit, 0
+	jobs := c.jobs()
+	c.mu.Unlock()
+	for _, jc := range jobs {
+		jc.SetTotal(total, unit)
+	}
+}
+
+func (c *artifactCall) Add(n int64) {
+	c.mu.Lock()
+	c.written += n
+	jobs := c.jobs()
+	c.mu.Unlock()
+	for _, jc := range jobs {
+		jc.Add(n)
+	}
+}
+
+func (c *artifactCall) Write(p []byte) (int, error) {
+	c.Add(int64(len(p)))
+	return len(p), nil
+}
+
+var (
+	artifactMu    sync.Mutex
+	artifactCalls = make(map[ArtifactKey]*artifactCall)
+)
+
+func newHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256", "":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
+}
+
+func hashFile(path, algo string) (string, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// downloadVerified 下載到 dest 並同時計算 hash，expected 不為空時必須相符
+func downloadVerified(ctx context.Context, call *artifactCall, dest, url, algo, expected string) (string, int64, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", 0, err
+	}
+	client := common.NewHTTPClient(artifactDownloadTimeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("User-Agent", "carsupper665/mc-server-backend/"+common.Version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("http get %s error: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("bad status downloading %s: %s", url, resp.Status)
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return "", 0, err
+	}
+	call.SetTotal(resp.ContentLength, "bytes")
+	size, err := io.Copy(io.MultiWriter(out, h, call), resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("writing to %s error: %w", dest, err)
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if expected != "" && !strings.EqualFold(sum, expected) {
+		return "", 0, fmt.Errorf("%w: %s", ErrHashMismatch, url)
+	}
+	return sum, size, nil
+}
+
+// artifactPath 快取目錄結構：<type>/<version>/<loader>/<hash>/<name>
+func artifactPath(key ArtifactKey, sum string) string {
+	loader := key.Loader
+	if loader == "" {
+		loader = "_"
+	}
+	return filepath.Join(common.ArtifactCachePath, key.ServerType, key.Version, loader, sum, key.Name)
+}
+
+// FetchArtifact 取得快取檔案，沒有或驗證失敗就下載；同一個 key 同時只會有一個下載，
+// 下載不受單一 job 取消影響，進度回報給所有等待中的 job
+func FetchArtifact(jc *JobContext, spec *ArtifactSpec) (*model.Artifact, error) {
+	artifactMu.Lock()
+	call, ok := artifactCalls[spec.Key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		call = &artifactCall{done: make(chan struct{}), cancel: cancel, waiters: make(map[*JobContext]int)}
+		artifactCalls[spec.Key] = call
+		go func() {
+			call.art, call.err = fetchArtifact(ctx, call, spec)
+			cancel()
+			artifactMu.Lock()
+			if artifactCalls[spec.Key] == call {
+				delete(artifactCalls, spec.Key)
+			}
+			artifactMu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.join(jc)
+	artifactMu.Unlock()
+	defer call.leave(spec.Key, jc)
+
+	select {
+	case <-call.done:
+		return call.art, call.err
+	case <-jc.Context().Done():
+		return nil, jc.Context().Err()
+	}
+}
+
+func fetchArtifact(ctx context.Context, call *artifactCall, spec *ArtifactSpec) (*model.Artifact, error) {
+	key, src := spec.Key, spec.Source
+
+	existing, err := model.GetArtifact(key.ServerType, key.Version, key.Loader, key.Name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if existing != nil {
+		sameSource := src.Hash == "" || (src.HashAlgo == existing.HashAlgo && strings.EqualFold(src.Hash, existing.Hash))
+		if sameSource {
+			if sum, err := hashFile(existing.Path, existing.HashAlgo); err == nil && strings.EqualFold(sum, existing.Hash) {
+				_ = model.TouchArtifact(existing.ID)
+				return existing, nil
+			}
+			common.SysLog("artifact cache entry corrupted, re-downloading: " + key.String())
+		}
+		os.Remove(existing.Path)
+	}
+
+	tmpDir := filepath.Join(common.ArtifactCachePath, ".tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(tmpDir, "artifact-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	algo := src.HashAlgo
+	if algo == "" {
+		algo = "sha256"
+	}
+	var (
+		sum  string
+		size int64
+	)
+	for attempt := 1; attempt <= artifactRetries; attempt++ {
+		sum, size, err = downloadVerified(ctx, call, tmp.Name(), src.URL, algo, src.Hash)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+		common.SysError(fmt.Sprintf("artifact download failed (%d/%d) %s: %v", attempt, artifactRetries, key.String(), err))
+		if attempt < artifactRetries {
+			time.Sleep(time.Duration(attempt) * 2 * time.Second)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dest := artifactPath(key, sum)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return nil, err
+	}
+
+	art := &model.Artifact{
+		ServerType: key.ServerType,
+		Version:    key.Version,
+		Loader:     key.Loader,
+		Name:       key.Name,
+		HashAlgo:   algo,
+		Hash:       sum,
+		Path:       dest,
+		Size:       size,
+		SourceURL:  src.URL,
+		LastUsedAt: time.Now(),
+	}
+	if existing != nil {
+		art.ID = existing.ID
+		art.CreatedAt = existing.CreatedAt
+	}
+	if err := model.SaveArtifact(art); err != nil {
+		return nil, err
+	}
+	return art, nil
+}
+
+// ProvisionArtifact 從快取複製一份到 dest (先寫暫存檔再 rename)
+func ProvisionArtifact(jc *JobContext, spec *ArtifactSpec, dest string) error {
+	art, err := FetchArtifact(jc, spec)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(art.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// dest 通常在 server 資料夾內，暫存檔不能跟著事先放好的 symlink 寫出去
+	tmp := dest + ".tmp"
+	out, err := createInWorkDir(filepath.Dir(dest), tmp, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// PrefetchArtifact 預先下載某個類型與版本的安裝檔
+func PrefetchArtifact(serverType, version string, opts InstallOptions) (*model.Artifact, error) {
+	provider, err := GetServerType(serverType)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateVersionString(version); err != nil {
+		return nil, err
+	}
+	spec, err := provider.Artifact(version, opts)
+	if err != nil {
+		return nil, err
+	}
+	return FetchArtifact(nil, spec)
+}
+
+func ListArtifacts() ([]model.Artifact, error) {
+	return model.ListArtifacts()
+}
+
+// EvictArtifact 刪除快取檔案與紀錄
+func EvictArtifact(id uint) error {
+	art, err := model.GetArtifactByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrArtifactNotFound
+		}
+		return err
+	}
+	if err := os.RemoveAll(filepath.Dir(art.Path)); err != nil {
+		return err
+	}
+	return model.DeleteArtifact(id)
+}
+
+// hashAlgoIf 上游有提供 hash 時才帶演算法
+func hashAlgoIf(hash, algo string) string {
+	if hash == "" {
+		return ""
+	}
+	return algo
+}
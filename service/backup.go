@@ -0,0 +1,80 @@
+// service/backup.go
+
+package service
+
+import (
+	"go-backend/common"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var legacyBackupsMigrated sync.Map
+
+// serverBackupDir world 備份放在 BACKUP_PATH/<server_id>/world，不在 server 自己的資料夾裡，
+// server 的帳號無法用 symlink 把備份導向其他檔案
+func serverBackupDir(sid, workDir string) string {
+	dir := filepath.Join(common.BackupPath, sid, "world")
+	migrateLegacyBackups(sid, workDir, dir)
+	return dir
+}
+
+// upgradeBackupDir 升級前的啟動檔
+func upgradeBackupDir(sid string) string {
+	return filepath.Join(common.BackupPath, sid, "upgrade")
+}
+
+// migrateLegacyBackups 舊版的備份在 workDir/backup，第一次用到時搬出來，symlink 不搬
+func migrateLegacyBackups(sid, workDir, dir string) {
+	if _, done := legacyBackupsMigrated.LoadOrStore(sid, true); done {
+		return
+	}
+	legacy := filepath.Join(workDir, "backup")
+	if info, err := os.Lstat(legacy); err != nil || !info.IsDir() {
+		return
+	}
+	entries, err := os.ReadDir(legacy)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		common.SysError("failed to create backup directory: " + err.Error())
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		src, dst := filepath.Join(legacy, e.Name()), filepath.Join(dir, e.Name())
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		if err := os.Rename(src, dst); err != nil {
+			// 不同的檔案系統無法 rename，改成複製
+			if err := copyTree(nil, src, dst); err != nil {
+				os.RemoveAll(dst)
+				common.SysError("failed to move backup " + src + ": " + err.Error())
+				continue
+			}
+			os.RemoveAll(src)
+		}
+	}
+	os.Remove(legacy) // 只有空的時候才會刪掉
+	common.SysLog("moved backups of " + sid + " to " + dir)
+}
+
+// moveFile rename 失敗時 (不同的檔案系統) 複製後刪除來源，不跟隨 symlink
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := copyFileMode(src, dst, info.Mode()); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,85 @@
+// service/cgroup.go
+
+package service
+
+import (
+	"bufio"
+	"errors"
+	"go-backend/common"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrCgroupUnavailable 非 Linux、沒有 cgroup v2 或沒有權限時回傳，server 仍然照常啟動
+var ErrCgroupUnavailable = errors.New("cgroup v2 unavailable")
+
+// CgroupLimits 寫入 server cgroup 的限制，0 / 空字串代表不限制
+type CgroupLimits struct {
+	MemoryMax int64  // bytes
+	CPUMax    string // "quota period"，例如 "200000 100000" 代表 2 核
+	CPUWeight int
+	PidsMax   int64
+}
+
+// CgroupStats 從 cgroup 讀到的計數，放在 Snapshot 給前端顯示
+type CgroupStats struct {
+	Path             string `json:"path"`
+	MemoryCurrent    uint64 `json:"memory_current"`
+	MemoryMax        uint64 `json:"memory_max,omitempty"` // 0 代表不限制
+	MemoryMaxEvents  uint64 `json:"memory_max_events"`    // 碰到 memory.max 的次數
+	OOMEvents        uint64 `json:"oom_events"`
+	OOMKills         uint64 `json:"oom_kills"`
+	CPUPeriods       uint64 `json:"cpu_periods"`
+	CPUThrottled     uint64 `json:"cpu_throttled_periods"`
+	CPUThrottledUsec uint64 `json:"cpu_throttled_usec"`
+	PidsCurrent      uint64 `json:"pids_current"`
+	PidsMax          uint64 `json:"pids_max,omitempty"`
+}
+
+// cgroupLimitsFor 記憶體上限預設為 -Xmx 加上 CGROUP_MEMORY_OVERHEAD_MB (native memory、metaspace 等)
+func cgroupLimitsFor(maxMem string) CgroupLimits {
+	limits := CgroupLimits{
+		CPUMax:    common.CgroupCPUMax,
+		CPUWeight: common.CgroupCPUWeight,
+		PidsMax:   int64(common.CgroupPidsMax),
+	}
+	switch {
+	case common.CgroupMemoryMaxMB > 0:
+		limits.MemoryMax = int64(common.CgroupMemoryMaxMB) << 20
+	case parseMemoryMB(maxMem) > 0:
+		limits.MemoryMax = (parseMemoryMB(maxMem) + int64(common.CgroupMemoryOverheadMB)) << 20
+	}
+	return limits
+}
+
+// readKeyValues 讀 memory.events / cpu.stat 這種 "key value" 格式的檔案
+func readKeyValues(path string) map[string]uint64 {
+	values := make(map[string]uint64)
+	f, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = n
+		}
+	}
+	return values
+}
+
+// readCgroupUint 讀單一數值的檔案，"max" 或讀取失敗時回傳 0
+func readCgroupUint(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return n
+}
@@ -0,0 +1,135 @@
+//go:build linux
+
+// service/cgroup_linux.go
+
+package service
+
+import (
+	"fmt"
+	"go-backend/common"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+const cgroupMount = "/sys/fs/cgroup"
+
+var (
+	cgroupRootOnce sync.Once
+	cgroupRootErr  error
+)
+
+// setupCgroupRoot 建立 CGROUP_ROOT 並開啟 cpu / memory / pids controller，只做一次
+func setupCgroupRoot() error {
+	cgroupRootOnce.Do(func() {
+		if !common.CgroupEnabled {
+			cgroupRootErr = ErrCgroupUnavailable
+			return
+		}
+		if _, err := os.Stat(filepath.Join(cgroupMount, "cgroup.controllers")); err != nil {
+			cgroupRootErr = fmt.Errorf("%w: %s is not a cgroup v2 mount", ErrCgroupUnavailable, cgroupMount)
+			return
+		}
+		root := common.CgroupRoot
+		if err := os.MkdirAll(root, 0755); err != nil {
+			cgroupRootErr = fmt.Errorf("%w: %v", ErrCgroupUnavailable, err)
+			return
+		}
+		// 上一層也要開啟 controller，子 cgroup 才能使用
+		for _, dir := range []string{filepath.Dir(root), root} {
+			if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+cpu +memory +pids"), 0644); err != nil {
+				cgroupRootErr = fmt.Errorf("%w: enable controllers in %s: %v", ErrCgroupUnavailable, dir, err)
+				return
+			}
+		}
+	})
+	return cgroupRootErr
+}
+
+// serverCgroup 每個 server 的 java process 放在自己的 cgroup
+type serverCgroup struct {
+	path string
+	dir  *os.File // 給 CLONE_INTO_CGROUP 使用，remove 時關閉
+}
+
+// newServerCgroup 重新建立 cgroup 讓計數歸零，cgroup 無法使用時回傳 ErrCgroupUnavailable
+func newServerCgroup(sid string, limits CgroupLimits) (*serverCgroup, error) {
+	if err := setupCgroupRoot(); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(common.CgroupRoot, sid)
+	_ = os.Remove(path) // 上次留下的空 cgroup
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+
+	files := map[string]string{"cpu.max": limits.CPUMax}
+	if limits.MemoryMax > 0 {
+		files["memory.max"] = strconv.FormatInt(limits.MemoryMax, 10)
+		// 不使用 swap，超過時直接 OOM kill 而不是拖慢整台主機
+		files["memory.swap.max"] = "0"
+	}
+	if limits.CPUWeight > 0 {
+		files["cpu.weight"] = strconv.Itoa(limits.CPUWeight)
+	}
+	if limits.PidsMax > 0 {
+		files["pids.max"] = strconv.FormatInt(limits.PidsMax, 10)
+	}
+	for name, value := range files {
+		if value == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(path, name), []byte(value), 0644); err != nil {
+			// 沒有 swap 的主機不會有 memory.swap.max
+			if name == "memory.swap.max" && os.IsNotExist(err) {
+				continue
+			}
+			os.Remove(path)
+			return nil, fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &serverCgroup{path: path, dir: dir}, nil
+}
+
+// attach 讓 process 在 clone 時就建立在 cgroup 內
+func (cg *serverCgroup) attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cg.dir.Fd())
+}
+
+// remove process 結束或啟動失敗後刪除 cgroup，還有殘留的 process 時保留
+func (cg *serverCgroup) remove() {
+	cg.dir.Close()
+	if err := os.Remove(cg.path); err != nil && !os.IsNotExist(err) {
+		common.SysError("failed to remove cgroup " + cg.path + ": " + err.Error())
+	}
+}
+
+func (cg *serverCgroup) stats() *CgroupStats {
+	mem := readKeyValues(filepath.Join(cg.path, "memory.events"))
+	cpu := readKeyValues(filepath.Join(cg.path, "cpu.stat"))
+	return &CgroupStats{
+		Path:             cg.path,
+		MemoryCurrent:    readCgroupUint(filepath.Join(cg.path, "memory.current")),
+		MemoryMax:        readCgroupUint(filepath.Join(cg.path, "memory.max")),
+		MemoryMaxEvents:  mem["max"],
+		OOMEvents:        mem["oom"],
+		OOMKills:         mem["oom_kill"],
+		CPUPeriods:       cpu["nr_periods"],
+		CPUThrottled:     cpu["nr_throttled"],
+		CPUThrottledUsec: cpu["throttled_usec"],
+		PidsCurrent:      readCgroupUint(filepath.Join(cg.path, "pids.current")),
+		PidsMax:          readCgroupUint(filepath.Join(cg.path, "pids.max")),
+	}
+}
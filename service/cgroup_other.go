@@ -0,0 +1,19 @@
+//go:build !linux
+
+// service/cgroup_other.go
+
+package service
+
+import "os/exec"
+
+type serverCgroup struct{}
+
+func newServerCgroup(string, CgroupLimits) (*serverCgroup, error) {
+	return nil, ErrCgroupUnavailable
+}
+
+func (cg *serverCgroup) attach(*exec.Cmd) {}
+
+func (cg *serverCgroup) remove() {}
+
+func (cg *serverCgroup) stats() *CgroupStats { return nil }
@@ -0,0 +1,193 @@
+// service/configHistory.go
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 每個檔案保留的 revision 數量
+const maxConfigRevisions = 100
+
+// 需要保留修改紀錄的設定檔
+var trackedConfigFiles = []string{
+	"server.properties",
+	"ops.json",
+	"whitelist.json",
+	"banned-players.json",
+	"banned-ips.json",
+}
+
+var (
+	ErrConfigNotTracked     = errors.New("config file is not tracked")
+	ErrRevisionNotFound     = errors.New("revision not found")
+	ErrRevisionFileMismatch = errors.New("revisions belong to different files")
+)
+
+// 同一時間只有一個寫入/記錄，避免比對最新 revision 時互相覆蓋
+var configHistoryMu sync.Mutex
+
+func IsTrackedConfigFile(file string) bool {
+	for _, f := range trackedConfigFiles {
+		if f == file {
+			return true
+		}
+	}
+	return false
+}
+
+// recordConfigRevision 內容與最新的 revision 相同時不新增，檔案不存在時略過
+func recordConfigRevision(serverID, workDir, file string, authorID uint, message string) error {
+	f, err := openWorkDirFile(workDir, filepath.Join(workDir, file))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	prevContent := ""
+	prev, err := model.LatestConfigRevision(serverID, file)
+	switch {
+	case err == nil:
+		if prev.Hash == hash {
+			return nil
+		}
+		prevContent = prev.Content
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	rev := &model.ConfigRevision{
+		ServerID: serverID,
+		File:     file,
+		AuthorID: authorID,
+		Message:  message,
+		Hash:     hash,
+		Size:     int64(len(data)),
+		Content:  string(data),
+		Diff:     UnifiedDiff("a/"+file, "b/"+file, prevContent, string(data)),
+	}
+	if err := model.CreateConfigRevision(rev); err != nil {
+		return err
+	}
+	return model.PruneConfigRevisions(serverID, file, maxConfigRevisions)
+}
+
+// SnapshotConfigFiles 記錄不是透過 API 的修改 (遊戲內 /op、/whitelist 或手動編輯)
+func SnapshotConfigFiles(serverID, workDir string) error {
+	configHistoryMu.Lock()
+	defer configHistoryMu.Unlock()
+
+	for _, file := range trackedConfigFiles {
+		if err := recordConfigRevision(serverID, workDir, file, 0, "external change"); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// TrackConfigWrite 寫入前先保存外部修改，寫入後以 authorID 記錄新的 revision
+// 記錄失敗只寫 log，不影響寫入結果
+func TrackConfigWrite(serverID, workDir, file string, authorID uint, message string, write func() error) error {
+	configHistoryMu.Lock()
+	defer configHistoryMu.Unlock()
+
+	if err := recordConfigRevision(serverID, workDir, file, 0, "external change"); err != nil {
+		common.SysError("failed to record config revision: " + err.Error())
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	if err := recordConfigRevision(serverID, workDir, file, authorID, message); err != nil {
+		common.SysError("failed to record config revision: " + err.Error())
+	}
+	return nil
+}
+
+// ListConfigRevisions 先記錄外部修改再列出，file 為空時列出所有檔案
+func ListConfigRevisions(serverID, workDir, file string, limit int) ([]model.ConfigRevisionInfo, error) {
+	if file != "" && !IsTrackedConfigFile(file) {
+		return nil, ErrConfigNotTracked
+	}
+	if err := SnapshotConfigFiles(serverID, workDir); err != nil {
+		common.SysError("failed to snapshot config files: " + err.Error())
+	}
+	if limit <= 0 || limit > maxConfigRevisions {
+		limit = maxConfigRevisions
+	}
+	return model.ListConfigRevisions(serverID, file, limit)
+}
+
+func GetConfigRevision(serverID string, id uint) (*model.ConfigRevision, error) {
+	rev, err := model.GetConfigRevision(serverID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
+// DiffConfigRevisions 比較同一個檔案的任意兩個 revision
+func DiffConfigRevisions(serverID string, fromID, toID uint) (string, error) {
+	from, err := GetConfigRevision(serverID, fromID)
+	if err != nil {
+		return "", err
+	}
+	to, err := GetConfigRevision(serverID, toID)
+	if err != nil {
+		return "", err
+	}
+	if from.File != to.File {
+		return "", ErrRevisionFileMismatch
+	}
+	return UnifiedDiff(fmt.Sprintf("%s@%d", from.File, from.ID), fmt.Sprintf("%s@%d", to.File, to.ID), from.Content, to.Content), nil
+}
+
+// RollbackConfig server 執行中會自己改寫 server.properties，只能在停止時還原
+func (s *ServerService) RollbackConfig(serverID, workDir string, authorID, id uint) (*model.ConfigRevision, error) {
+	rev, err := GetConfigRevision(serverID, id)
+	if err != nil {
+		return nil, err
+	}
+	if rev.File == "server.properties" && s.mgr.IsRunning(serverID) {
+		return nil, ErrPropertiesWhileRunning
+	}
+	return RollbackConfig(serverID, workDir, authorID, id)
+}
+
+// RollbackConfig 把檔案還原成指定 revision 的內容，還原本身也會成為新的 revision
+func RollbackConfig(serverID, workDir string, authorID, id uint) (*model.ConfigRevision, error) {
+	rev, err := GetConfigRevision(serverID, id)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(workDir, rev.File)
+	err = TrackConfigWrite(serverID, workDir, rev.File, authorID, fmt.Sprintf("rollback to #%d", rev.ID), func() error {
+		return writeWorkDirFile(workDir, path, []byte(rev.Content), 0644)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return model.LatestConfigRevision(serverID, rev.File)
+}
@@ -0,0 +1,471 @@
+// service/diskUsage.go
+
+package service
+
+import (
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	diskHistoryDays = 30
+	// 兩次寫入歷史紀錄的最長間隔，大小沒變時也會記錄，讓圖表是連續的
+	diskRecordInterval = time.Hour
+	// 每走訪 diskScanYieldEvery 個檔案暫停一下，避免大 world 把 IO 吃滿
+	diskScanYieldEvery = 256
+	diskScanYield      = 2 * time.Millisecond
+)
+
+// QuotaServerDisk 單一 server 的磁碟上限
+const QuotaServerDisk = "server_disk_mb"
+
+// 依最上層的名稱分類，world 另外依 level-name 判斷
+var diskCategories = map[string]string{
+	"backup":         "backups",
+	"upgrade-backup": "backups",
+	"mods":           "mods",
+	"plugins":        "mods",
+	"logs":           "logs",
+	"crash-reports":  "logs",
+}
+
+type entryStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+type dirStamp struct {
+	modTime time.Time
+	size    int64
+	files   int64
+}
+
+// worldDirStamp world 底下單一資料夾的直接檔案大小，不含子資料夾
+type worldDirStamp struct {
+	dirStamp
+	subdirs []string
+}
+
+// region 檔是原地改寫的，資料夾的 mtime 不會變，每次都重新 stat；
+// 其他資料夾的檔案以暫存檔 rename 寫入，mtime 沒變就沿用上次的結果
+var inPlaceWorldDirs = map[string]bool{
+	"region":   true,
+	"entities": true,
+	"poi":      true,
+}
+
+// diskScanner 增量掃描：server 沒有執行過、最上層也沒有變動時沿用上次的結果，
+// 執行中的 server 只重新計算 world 裡有變動的資料夾，
+// 備份建立後不會再修改，每個備份資料夾只計算一次
+type diskScanner struct {
+	mu        sync.Mutex
+	isRunning func(string) bool
+	latest    map[string]*model.DiskUsage
+	stamps    map[string]map[string]entryStamp // sid -> 最上層每個項目的 mtime / size
+	backups   map[string]dirStamp              // 備份資料夾路徑 -> 大小
+	worlds    map[string]worldDirStamp         // world 底下的資料夾路徑 -> 大小
+	dirty     map[string]bool                  // 上次掃描後 server 有執行過
+	recorded  map[string]*model.DiskUsage      // 最後寫入歷史的一筆
+	pruned    time.Time
+}
+
+var diskScan = &diskScanner{
+	isRunning: func(string) bool { return false },
+	latest:    make(map[string]*model.DiskUsage),
+	stamps:    make(map[string]map[string]entryStamp),
+	backups:   make(map[string]dirStamp),
+	worlds:    make(map[string]worldDirStamp),
+	dirty:     make(map[string]bool),
+	recorded:  make(map[string]*model.DiskUsage),
+}
+
+// markDiskDirty server 啟動時呼叫，下次掃描一定會重新走訪 world
+func markDiskDirty(sid string) {
+	diskScan.mu.Lock()
+	diskScan.dirty[sid] = true
+	diskScan.mu.Unlock()
+}
+
+// invalidateDiskUsage 備份、還原等大量寫入後呼叫，下次查詢時重新掃描
+func invalidateDiskUsage(sid string) {
+	diskScan.mu.Lock()
+	delete(diskScan.latest, sid)
+	diskScan.dirty[sid] = true
+	diskScan.mu.Unlock()
+}
+
+// throttle 每個檔案呼叫一次，定期讓出 IO
+type throttle int
+
+func (t *throttle) tick() {
+	*t++
+	if *t%diskScanYieldEvery == 0 {
+		time.Sleep(diskScanYield)
+	}
+}
+
+func walkSize(path string, t *throttle) (size, files int64) {
+	filepath.WalkDir(path, func(_ string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		t.tick()
+		if !e.IsDir() {
+			if info, err := e.Info(); err == nil {
+				size += info.Size()
+				files++
+			}
+		}
+		return nil
+	})
+	return size, files
+}
+
+func topLevelStamps(workDir string) (map[string]entryStamp, []os.DirEntry, error) {
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	stamps := make(map[string]entryStamp, len(entries)+1)
+	if info, err := os.Stat(workDir); err == nil {
+		stamps["."] = entryStamp{modTime: info.ModTime()}
+	}
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil {
+			stamps[e.Name()] = entryStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps, entries, nil
+}
+
+func sameStamps(a, b map[string]entryStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !w.modTime.Equal(v.modTime) || w.size != v.size {
+			return false
+		}
+	}
+	return true
+}
+
+// backupsSize 每個備份資料夾依 mtime 快取
+func (d *diskScanner) backupsSize(dir string, t *throttle) (size, files int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if !e.IsDir() {
+			size += info.Size()
+			files++
+			continue
+		}
+		d.mu.Lock()
+		cached, ok := d.backups[path]
+		d.mu.Unlock()
+		if !ok || !cached.modTime.Equal(info.ModTime()) {
+			s, f := walkSize(path, t)
+			cached = dirStamp{modTime: info.ModTime(), size: s, files: f}
+			d.mu.Lock()
+			d.backups[path] = cached
+			d.mu.Unlock()
+		}
+		size += cached.size
+		files += cached.files
+	}
+	return size, files
+}
+
+// worldSize 依資料夾 mtime 快取，只重新列出有變動的資料夾與 region 資料夾
+func (d *diskScanner) worldSize(dir string, t *throttle) (size, files int64) {
+	info, err := os.Lstat(dir)
+	if err != nil || !info.IsDir() {
+		return 0, 0
+	}
+	d.mu.Lock()
+	cached, ok := d.worlds[dir]
+	d.mu.Unlock()
+	if !ok || !cached.modTime.Equal(info.ModTime()) || inPlaceWorldDirs[filepath.Base(dir)] {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return 0, 0
+		}
+		cached = worldDirStamp{dirStamp: dirStamp{modTime: info.ModTime()}}
+		for _, e := range entries {
+			t.tick()
+			if e.IsDir() {
+				cached.subdirs = append(cached.subdirs, e.Name())
+				continue
+			}
+			if fi, err := e.Info(); err == nil {
+				cached.size += fi.Size()
+				cached.files++
+			}
+		}
+		d.mu.Lock()
+		d.worlds[dir] = cached
+		d.mu.Unlock()
+	}
+
+	size, files = cached.size, cached.files
+	for _, name := range cached.subdirs {
+		s, f := d.worldSize(filepath.Join(dir, name), t)
+		size += s
+		files += f
+	}
+	return size, files
+}
+
+// scan force 為 true 時不沿用上次的結果 (備份資料夾的快取仍然有效)
+func (d *diskScanner) scan(sid, workDir string, force bool) (*model.DiskUsage, error) {
+	d.mu.Lock()
+	isRunning := d.isRunning
+	d.mu.Unlock()
+	running := isRunning(sid)
+	stamps, entries, err := topLevelStamps(workDir)
+	if err != nil {
+		return nil, err
+	}
+	// 備份放在 server 資料夾外面，資料夾有變動也要重新掃描
+	backupDirs := []string{serverBackupDir(sid, workDir), upgradeBackupDir(sid)}
+	for _, dir := range backupDirs {
+		if info, err := os.Stat(dir); err == nil {
+			stamps[dir] = entryStamp{modTime: info.ModTime()}
+		}
+	}
+
+	d.mu.Lock()
+	prev := d.latest[sid]
+	reuse := !force && !running && !d.dirty[sid] && prev != nil && sameStamps(d.stamps[sid], stamps)
+	d.mu.Unlock()
+	if reuse {
+		return prev, nil
+	}
+
+	var t throttle
+	worlds := worldDirs(workDir)
+	u := &model.DiskUsage{ServerID: sid, ScannedAt: time.Now()}
+	for _, e := range entries {
+		name := e.Name()
+		path := filepath.Join(workDir, name)
+		var size, files int64
+		switch {
+		case !e.IsDir():
+			if info, err := e.Info(); err == nil {
+				size, files = info.Size(), 1
+			}
+		case diskCategories[name] == "backups":
+			size, files = d.backupsSize(path, &t)
+		case worlds[name]:
+			size, files = d.worldSize(path, &t)
+		default:
+			size, files = walkSize(path, &t)
+		}
+
+		switch {
+		case worlds[name]:
+			u.World += size
+		case diskCategories[name] == "backups":
+			u.Backups += size
+		case diskCategories[name] == "mods":
+			u.Mods += size
+		case diskCategories[name] == "logs":
+			u.Logs += size
+		default:
+			u.Other += size
+		}
+		u.Total += size
+		u.Files += files
+	}
+	for _, dir := range backupDirs {
+		size, files := d.backupsSize(dir, &t)
+		u.Backups += size
+		u.Total += size
+		u.Files += files
+	}
+
+	d.mu.Lock()
+	d.latest[sid] = u
+	d.stamps[sid] = stamps
+	// 執行中的 server 還會繼續寫入，下次仍要重新掃描
+	d.dirty[sid] = running
+	d.mu.Unlock()
+	return u, nil
+}
+
+// record 大小有變或超過 diskRecordInterval 才寫入歷史
+func (d *diskScanner) record(u *model.DiskUsage) {
+	d.mu.Lock()
+	last := d.recorded[u.ServerID]
+	d.mu.Unlock()
+	if last != nil && last.Total == u.Total && u.ScannedAt.Sub(last.ScannedAt) < diskRecordInterval {
+		return
+	}
+	row := *u
+	row.ID = 0
+	if err := model.CreateDiskUsage(&row); err != nil {
+		common.SysError("failed to save disk usage: " + err.Error())
+		return
+	}
+	d.mu.Lock()
+	d.recorded[u.ServerID] = &row
+	d.mu.Unlock()
+}
+
+func (d *diskScanner) scanAll() {
+	servers, err := model.GetAllServers()
+	if err != nil {
+		common.SysError("disk scan: failed to list servers: " + err.Error())
+		return
+	}
+	exists := make(map[string]bool, len(servers))
+	for _, srv := range servers {
+		exists[srv.ServerID] = true
+		u, err := d.scan(srv.ServerID, srv.SystemPath, false)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				common.SysError(fmt.Sprintf("disk scan %s: %v", srv.ServerID, err))
+			}
+			continue
+		}
+		d.record(u)
+	}
+	d.prune(exists)
+	if time.Since(d.pruned) > 24*time.Hour {
+		if err := model.PruneDiskUsage(time.Now().AddDate(0, 0, -diskHistoryDays)); err != nil {
+			common.SysError("failed to prune disk usage: " + err.Error())
+		}
+		d.pruned = time.Now()
+	}
+}
+
+// prune 清掉已刪除的備份與 world 資料夾，以及已刪除的 server
+func (d *diskScanner) prune(exists map[string]bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for path := range d.backups {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			delete(d.backups, path)
+		}
+	}
+	for path := range d.worlds {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			delete(d.worlds, path)
+		}
+	}
+	for _, m := range []map[string]*model.DiskUsage{d.latest, d.recorded} {
+		for sid := range m {
+			if !exists[sid] {
+				delete(m, sid)
+			}
+		}
+	}
+	for sid := range d.stamps {
+		if !exists[sid] {
+			delete(d.stamps, sid)
+		}
+	}
+	for sid := range d.dirty {
+		if !exists[sid] {
+			delete(d.dirty, sid)
+		}
+	}
+}
+
+// StartDiskScanner 載入上次的結果後每 interval 掃描所有 server
+func (s *ServerService) StartDiskScanner(interval time.Duration) {
+	diskScan.mu.Lock()
+	diskScan.isRunning = s.mgr.IsRunning
+	diskScan.mu.Unlock()
+
+	if list, err := model.LatestDiskUsages(); err == nil {
+		diskScan.mu.Lock()
+		for i := range list {
+			u := &list[i]
+			diskScan.recorded[u.ServerID] = u
+		}
+		diskScan.mu.Unlock()
+	}
+
+	go func() {
+		for {
+			diskScan.scanAll()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// cachedDiskUsage 有掃描結果時直接使用，否則立即掃描一次
+func cachedDiskUsage(sid, workDir string) (*model.DiskUsage, error) {
+	diskScan.mu.Lock()
+	u := diskScan.latest[sid]
+	diskScan.mu.Unlock()
+	if u != nil {
+		return u, nil
+	}
+	u, err := diskScan.scan(sid, workDir, false)
+	if err != nil {
+		return nil, err
+	}
+	diskScan.record(u)
+	return u, nil
+}
+
+// ServerDiskUsage force 為 true 時重新掃描
+func ServerDiskUsage(rec *model.UserMinecraftServer, force bool) (*model.DiskUsage, error) {
+	if !force {
+		return cachedDiskUsage(rec.ServerID, rec.SystemPath)
+	}
+	u, err := diskScan.scan(rec.ServerID, rec.SystemPath, true)
+	if err != nil {
+		return nil, err
+	}
+	diskScan.record(u)
+	return u, nil
+}
+
+func DiskUsageHistory(sid string, since time.Time) ([]model.DiskUsage, error) {
+	return model.ListDiskUsage(sid, since)
+}
+
+// ServerDiskLimitMB server 自己的上限，沒有設定時使用 SERVER_DISK_LIMIT_MB
+func ServerDiskLimitMB(rec *model.UserMinecraftServer) int64 {
+	if rec.DiskLimitMB > 0 {
+		return rec.DiskLimitMB
+	}
+	return common.ServerDiskLimitMB
+}
+
+// checkServerDiskLimit backup 為 true 時以 world 目前的大小估計備份會增加的用量，
+// 否則只檢查是否已經超過上限 (啟動前)
+func checkServerDiskLimit(rec *model.UserMinecraftServer, backup bool) error {
+	limit := ServerDiskLimitMB(rec)
+	if limit <= 0 {
+		return nil
+	}
+	u, err := cachedDiskUsage(rec.ServerID, rec.SystemPath)
+	if err != nil {
+		return err
+	}
+	used := toMB(u.Total)
+	if !backup {
+		if used >= limit {
+			return &QuotaError{Resource: QuotaServerDisk, Limit: limit, Used: used}
+		}
+		return nil
+	}
+	return checkQuota(QuotaServerDisk, limit, used, toMB(u.World))
+}
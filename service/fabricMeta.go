@@ -0,0 +1,187 @@
+// service/fabricMeta.go
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// fabric meta 的列表變動不頻繁，快取一段時間避免每次建立 server 都打上游
+const fabricMetaTTL = 10 * time.Minute
+
+type FabricLoaderVersion struct {
+	Version string `json:"version"`
+	Build   int    `json:"build"`
+	Maven   string `json:"maven"`
+	Stable  bool   `json:"stable"`
+}
+
+type FabricInstallerVersion struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	Maven   string `json:"maven"`
+	Stable  bool   `json:"stable"`
+}
+
+type fabricMetaEntry struct {
+	body    []byte
+	fetched time.Time
+}
+
+// FabricMetaClient 讀取 meta.fabricmc.net，回應會依 URL 快取
+type FabricMetaClient struct {
+	mu    sync.Mutex
+	cache map[string]fabricMetaEntry
+	http  *http.Client
+}
+
+var fabricMeta = NewFabricMetaClient()
+
+func NewFabricMetaClient() *FabricMetaClient {
+	return &FabricMetaClient{
+		cache: make(map[string]fabricMetaEntry),
+		http:  common.NewHTTPClient(30 * time.Second),
+	}
+}
+
+func (f *FabricMetaClient) get(path string, out any) error {
+	u := common.FabricMetaURL + path
+
+	f.mu.Lock()
+	entry, ok := f.cache[u]
+	f.mu.Unlock()
+	if !ok || time.Since(entry.fetched) > fabricMetaTTL {
+		resp, err := f.http.Get(u)
+		if err != nil {
+			return fmt.Errorf("http get %s error: %w", u, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%s: %w", u, ErrBuildNotFound)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to get fabric meta %s, status code: %d", path, resp.StatusCode)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		entry = fabricMetaEntry{body: body, fetched: time.Now()}
+		f.mu.Lock()
+		f.cache[u] = entry
+		f.mu.Unlock()
+	}
+	return json.Unmarshal(entry.body, out)
+}
+
+func (f *FabricMetaClient) GameVersions() ([]GameVersion, error) {
+	var gv []GameVersion
+	err := f.get("/v2/versions/game", &gv)
+	return gv, err
+}
+
+// Loaders 所有 loader 版本，新到舊
+func (f *FabricMetaClient) Loaders() ([]FabricLoaderVersion, error) {
+	var loaders []FabricLoaderVersion
+	err := f.get("/v2/versions/loader", &loaders)
+	return loaders, err
+}
+
+// LoadersFor 支援指定 minecraft 版本的 loader，新到舊
+func (f *FabricMetaClient) LoadersFor(game string) ([]FabricLoaderVersion, error) {
+	if err := ValidateVersionString(game); err != nil {
+		return nil, err
+	}
+	var entries []struct {
+		Loader FabricLoaderVersion `json:"loader"`
+	}
+	if err := f.get("/v2/versions/loader/"+url.PathEscape(game), &entries); err != nil {
+		return nil, err
+	}
+	loaders := make([]FabricLoaderVersion, len(entries))
+	for i, e := range entries {
+		loaders[i] = e.Loader
+	}
+	return loaders, nil
+}
+
+func (f *FabricMetaClient) Installers() ([]FabricInstallerVersion, error) {
+	var installers []FabricInstallerVersion
+	err := f.get("/v2/versions/installer", &installers)
+	return installers, err
+}
+
+// LatestLoader 有設定 LATEST_FABRIC_LOADER_VERSION 時以設定為準，否則取最新的 stable
+// game 不為空時只考慮支援該版本的 loader
+func (f *FabricMetaClient) LatestLoader(game string) (string, error) {
+	if common.LatestFabricLoaderVersion != "" {
+		return common.LatestFabricLoaderVersion, nil
+	}
+	var (
+		loaders []FabricLoaderVersion
+		err     error
+	)
+	if game != "" {
+		loaders, err = f.LoadersFor(game)
+	} else {
+		loaders, err = f.Loaders()
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, l := range loaders {
+		if l.Stable {
+			return l.Version, nil
+		}
+	}
+	if len(loaders) > 0 {
+		return loaders[0].Version, nil
+	}
+	return "", fmt.Errorf("%w: no fabric loader for %s", ErrBuildNotFound, game)
+}
+
+// LatestInstaller 有設定 LATEST_FABRIC_INSTALLER_VERSION 時以設定為準，否則取最新的 stable
+func (f *FabricMetaClient) LatestInstaller() (string, error) {
+	if common.LatestFabricInstallerVersion != "" {
+		return common.LatestFabricInstallerVersion, nil
+	}
+	installers, err := f.Installers()
+	if err != nil {
+		return "", err
+	}
+	for _, i := range installers {
+		if i.Stable {
+			return i.Version, nil
+		}
+	}
+	if len(installers) > 0 {
+		return installers[0].Version, nil
+	}
+	return "", fmt.Errorf("%w: no fabric installer", ErrBuildNotFound)
+}
+
+func ListFabricLoaders(game string) ([]FabricLoaderVersion, error) {
+	if game == "" {
+		return fabricMeta.Loaders()
+	}
+	return fabricMeta.LoadersFor(game)
+}
+
+func ListFabricInstallers() ([]FabricInstallerVersion, error) {
+	return fabricMeta.Installers()
+}
+
+func LatestFabricLoader(game string) (string, error) {
+	return fabricMeta.LatestLoader(game)
+}
+
+func LatestFabricInstaller() (string, error) {
+	return fabricMeta.LatestInstaller()
+}
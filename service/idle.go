@@ -0,0 +1,243 @@
+// service/idle.go
+
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"net"
+	"sync"
+	"time"
+)
+
+// sleepingServer 因為閒置而關閉的 server，保留啟動參數並在它的 port 上等待玩家登入
+type sleepingServer struct {
+	sid, oid, workDir    string
+	maxMem, minMem, port string
+	args                 []string
+	ln                   net.Listener
+	since                time.Time
+	waking               bool
+}
+
+// idleWatcher 定期 ping 執行中的 server，沒有玩家超過時限就關閉並進入休眠
+type idleWatcher struct {
+	mu        sync.Mutex
+	svc       *ServerService
+	idleSince map[string]time.Time
+	sleeping  map[string]*sleepingServer
+}
+
+var idle = &idleWatcher{
+	idleSince: make(map[string]time.Time),
+	sleeping:  make(map[string]*sleepingServer),
+}
+
+// IdleInfo 給前端顯示的閒置狀態
+type IdleInfo struct {
+	IdleMinutes   int        `json:"idle_minutes"`      // server 自己的設定
+	Effective     int        `json:"effective_minutes"` // 實際使用的時限，0 代表不自動關閉
+	Players       int        `json:"players"`           // 最後一次 ping 的線上人數
+	PlayersAt     *time.Time `json:"players_at"`        // 還沒 ping 成功時為 null
+	IdleSince     *time.Time `json:"idle_since"`        // 沒有玩家的起始時間
+	Sleeping      bool       `json:"sleeping"`          // 已關閉並等待玩家登入
+	SleepingSince *time.Time `json:"sleeping_since,omitempty"`
+}
+
+// IdleMinutes 沒有設定時使用 IDLE_SHUTDOWN_MINUTES，0 代表不自動關閉
+func IdleMinutes(rec *model.UserMinecraftServer) int {
+	minutes := rec.IdleMinutes
+	if minutes == 0 {
+		minutes = common.IdleShutdownMinutes
+	}
+	if minutes < 0 {
+		return 0
+	}
+	return minutes
+}
+
+func (s *ServerService) StartIdleWatcher(interval time.Duration) {
+	idle.mu.Lock()
+	idle.svc = s
+	idle.mu.Unlock()
+
+	go func() {
+		for {
+			time.Sleep(interval)
+			for _, srv := range s.mgr.runningServers() {
+				idle.check(srv)
+			}
+		}
+	}()
+}
+
+func (w *idleWatcher) check(srv *Server) {
+	sid := srv.ID()
+	status, err := PingServer(net.JoinHostPort("127.0.0.1", srv.Port()), 5*time.Second)
+	if err != nil {
+		// 還在啟動或沒有回應，不算閒置
+		return
+	}
+	srv.setPlayers(status.Players.Online)
+
+	w.mu.Lock()
+	if status.Players.Online > 0 {
+		delete(w.idleSince, sid)
+		w.mu.Unlock()
+		return
+	}
+	since, ok := w.idleSince[sid]
+	if !ok {
+		since = time.Now()
+		w.idleSince[sid] = since
+	}
+	w.mu.Unlock()
+
+	rec, err := model.GetServerRecord(sid)
+	if err != nil {
+		return
+	}
+	limit := IdleMinutes(rec)
+	if limit <= 0 || time.Since(since) < time.Duration(limit)*time.Minute {
+		return
+	}
+	if err := w.sleep(srv); err != nil {
+		common.SysError("failed to put idle server " + sid + " to sleep: " + err.Error())
+		return
+	}
+	common.SysLog(fmt.Sprintf("server %s stopped after %d idle minutes", sid, limit))
+}
+
+// sleep 關閉 server 並在同一個 port 上開始等待登入
+func (w *idleWatcher) sleep(srv *Server) error {
+	sl := &sleepingServer{
+		sid:     srv.sid,
+		oid:     srv.oid,
+		workDir: srv.workDir,
+		maxMem:  srv.maxMem,
+		minMem:  srv.minMem,
+		port:    srv.Port(),
+		args:    srv.args,
+		since:   time.Now(),
+	}
+	if err := w.svc.Stop(sl.sid); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	delete(w.idleSince, sl.sid)
+	w.mu.Unlock()
+	return w.listen(sl)
+}
+
+func (w *idleWatcher) listen(sl *sleepingServer) error {
+	ln, err := net.Listen("tcp", ":"+sl.port)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	sl.ln = ln
+	sl.waking = false
+	w.sleeping[sl.sid] = sl
+	w.mu.Unlock()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go w.handle(sl, conn)
+		}
+	}()
+	return nil
+}
+
+// handle 回應清單上的 ping，真正登入時叫醒 server 並請玩家稍後再連
+func (w *idleWatcher) handle(sl *sleepingServer, conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	h, err := readHandshake(r)
+	if err != nil {
+		return
+	}
+	switch h.NextState {
+	case mcStateStatus:
+		serveStatus(conn, r, h, StatusResponse{
+			Version:     StatusVersion{Name: "Sleeping"},
+			Description: chatJSON(common.SleepingMOTD),
+		})
+	case mcStateLogin:
+		// 只有 handshake 還不算登入，收到帶有玩家名稱的 Login Start 才叫醒
+		if _, err := readLoginStart(r); err != nil {
+			return
+		}
+		sendLoginDisconnect(conn, common.WakeMessage)
+		w.wake(sl)
+	}
+}
+
+// wake 同時有多個玩家登入時只啟動一次
+func (w *idleWatcher) wake(sl *sleepingServer) {
+	w.mu.Lock()
+	if sl.waking || w.sleeping[sl.sid] != sl {
+		w.mu.Unlock()
+		return
+	}
+	sl.waking = true
+	w.mu.Unlock()
+
+	go func() {
+		common.SysLog("waking up server " + sl.sid)
+		if _, err := w.svc.Start(sl.sid, sl.oid, sl.workDir, sl.maxMem, sl.minMem, sl.args); err != nil {
+			common.SysError("failed to wake server " + sl.sid + ": " + err.Error())
+		}
+	}()
+}
+
+// release 關掉休眠的 listener 把 port 還給 server，沒有休眠時回傳 nil
+func (w *idleWatcher) release(sid string) *sleepingServer {
+	w.mu.Lock()
+	sl := w.sleeping[sid]
+	delete(w.sleeping, sid)
+	delete(w.idleSince, sid)
+	w.mu.Unlock()
+	if sl != nil {
+		sl.ln.Close()
+	}
+	return sl
+}
+
+func (w *idleWatcher) isSleeping(sid string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.sleeping[sid]
+	return ok
+}
+
+// ForgetIdleServer server 被刪除時停止休眠的 listener
+func ForgetIdleServer(sid string) {
+	idle.release(sid)
+}
+
+func (s *ServerService) IdleInfo(rec *model.UserMinecraftServer) IdleInfo {
+	info := IdleInfo{IdleMinutes: rec.IdleMinutes, Effective: IdleMinutes(rec)}
+	if srv, ok := s.mgr.get(rec.ServerID); ok {
+		if online, at := srv.Players(); !at.IsZero() {
+			info.Players = online
+			info.PlayersAt = &at
+		}
+	}
+	idle.mu.Lock()
+	if since, ok := idle.idleSince[rec.ServerID]; ok {
+		info.IdleSince = &since
+	}
+	if sl, ok := idle.sleeping[rec.ServerID]; ok {
+		info.Sleeping = true
+		info.SleepingSince = &sl.since
+	}
+	idle.mu.Unlock()
+	return info
+}
@@ -0,0 +1,169 @@
+// service/installerLibraries.go
+
+package service
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// forge / neoforge installer 會自己下載 libraries，不會經過 MIRROR_DIR / MIRROR_URL。
+// mirror 模式下先依 installer 內的 install_profile.json 與 version.json 把 libraries 下載好，
+// installer 看到 sha1 相符的檔案就不會再連線
+
+type installerLibrary struct {
+	Name      string `json:"name"`
+	Downloads struct {
+		Artifact struct {
+			Path string `json:"path"`
+			URL  string `json:"url"`
+			SHA1 string `json:"sha1"`
+		} `json:"artifact"`
+	} `json:"downloads"`
+}
+
+// installProfile installer 內的安裝資訊；1.12 以前的 installer 格式不同，讀出來會是空的
+type installProfile struct {
+	Minecraft     string             `json:"minecraft"`
+	ServerJarPath string             `json:"serverJarPath"`
+	Libraries     []installerLibrary `json:"libraries"`
+}
+
+// readInstallProfile 合併 install_profile.json (installer 執行用) 與 version.json (啟動用) 的 library，
+// 包在 installer 裡的 (沒有 url) 不列出
+func readInstallProfile(installer string) (*installProfile, error) {
+	zr, err := zip.OpenReader(installer)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var profile installProfile
+	seen := make(map[string]bool)
+	for _, name := range []string{"install_profile.json", "version.json"} {
+		f, err := zr.Open(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		var doc installProfile
+		err = json.NewDecoder(f).Decode(&doc)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if name == "install_profile.json" {
+			profile.Minecraft, profile.ServerJarPath = doc.Minecraft, doc.ServerJarPath
+		}
+		for _, lib := range doc.Libraries {
+			a := lib.Downloads.Artifact
+			if a.URL == "" || seen[a.Path] || !filepath.IsLocal(filepath.FromSlash(a.Path)) {
+				continue
+			}
+			seen[a.Path] = true
+			profile.Libraries = append(profile.Libraries, lib)
+		}
+	}
+	return &profile, nil
+}
+
+// serverJar installer 找 vanilla server jar 的位置，相對 workDir
+func (p *installProfile) serverJar() string {
+	if p.Minecraft == "" || p.ServerJarPath == "" {
+		return ""
+	}
+	path := strings.NewReplacer("{LIBRARY_DIR}", "libraries", "{MINECRAFT_VERSION}", p.Minecraft).Replace(p.ServerJarPath)
+	if path = filepath.FromSlash(path); !filepath.IsLocal(path) {
+		return ""
+	}
+	return path
+}
+
+// prefetchInstallerLibraries 只在 mirror 模式下執行，把 libraries 與 vanilla server jar 從 mirror 下載到 workDir
+func prefetchInstallerLibraries(jc *JobContext, workDir, installer string) error {
+	if !common.MirrorEnabled() {
+		return nil
+	}
+	profile, err := readInstallProfile(installer)
+	if err != nil {
+		return fmt.Errorf("failed to read installer profile: %w", err)
+	}
+	if jar := profile.serverJar(); jar != "" {
+		dest := filepath.Join(workDir, jar)
+		if err := mkdirAllInWorkDir(workDir, filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := DownloadVanillaServer(jc, dest, profile.Minecraft); err != nil {
+			return fmt.Errorf("minecraft server %s: %w", profile.Minecraft, err)
+		}
+	}
+
+	jc.Step("downloading installer libraries from mirror")
+	jc.SetTotal(int64(len(profile.Libraries)), "files")
+	for _, lib := range profile.Libraries {
+		a := lib.Downloads.Artifact
+		dest := filepath.Join(workDir, "libraries", filepath.FromSlash(a.Path))
+		if a.SHA1 != "" {
+			if sum, err := hashFile(dest, "sha1"); err == nil && strings.EqualFold(sum, a.SHA1) {
+				jc.Add(1)
+				continue
+			}
+		}
+		if err := downloadLibrary(jc, workDir, dest, a.URL, a.SHA1); err != nil {
+			return fmt.Errorf("library %s: %w", lib.Name, err)
+		}
+		jc.Add(1)
+	}
+	return nil
+}
+
+func downloadLibrary(jc *JobContext, workDir, dest, url, expected string) error {
+	req, err := http.NewRequestWithContext(jc.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "carsupper665/mc-server-backend/"+common.Version)
+	resp, err := common.NewHTTPClient(artifactDownloadTimeout).Do(req)
+	if err != nil {
+		return fmt.Errorf("http get %s error: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status downloading %s: %s", url, resp.Status)
+	}
+
+	if err := mkdirAllInWorkDir(workDir, filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".tmp"
+	out, err := createInWorkDir(workDir, tmp, 0644)
+	if err != nil {
+		return err
+	}
+	h := sha1.New()
+	_, err = io.Copy(io.MultiWriter(out, h), resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && expected != "" && !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), expected) {
+		err = fmt.Errorf("%w: %s", ErrHashMismatch, url)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
@@ -0,0 +1,177 @@
+// service/isolation.go
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	IsolationNone      = "none"
+	IsolationShared    = "shared"     // 所有 server 使用同一個沒有權限的帳號
+	IsolationPerServer = "per-server" // 每個 server 有自己的 UID / GID
+)
+
+var (
+	ErrIsolationUnsupported = errors.New("server isolation requires Linux and a backend running as root")
+	ErrNoFreeUID            = errors.New("no available uid for server isolation")
+)
+
+// serverIdentity server process 使用的帳號
+type serverIdentity struct {
+	UID int
+	GID int
+}
+
+var (
+	serverUIDMu     sync.Mutex
+	protectFilesOne sync.Once
+)
+
+// resolveServerIdentity 隔離模式為 none 時回傳 nil，process 以 backend 的帳號執行
+func resolveServerIdentity(sid string) (*serverIdentity, error) {
+	switch common.ServerIsolation {
+	case "", IsolationNone:
+		return nil, nil
+	case IsolationShared:
+		return &serverIdentity{UID: common.ServerRunUID, GID: common.ServerRunGID}, nil
+	case IsolationPerServer:
+		uid, err := ensureServerUID(sid)
+		if err != nil {
+			return nil, err
+		}
+		return &serverIdentity{UID: uid, GID: uid}, nil
+	default:
+		return nil, fmt.Errorf("unknown SERVER_ISOLATION %q", common.ServerIsolation)
+	}
+}
+
+// ensureServerUID 沿用已分配的 UID，沒有時取範圍內最小的空號
+func ensureServerUID(sid string) (int, error) {
+	serverUIDMu.Lock()
+	defer serverUIDMu.Unlock()
+
+	if u, err := model.GetServerUser(sid); err != nil {
+		return 0, err
+	} else if u != nil {
+		return u.UID, nil
+	}
+	used, err := model.UsedServerUIDs()
+	if err != nil {
+		return 0, err
+	}
+	for uid := common.ServerUIDStart; uid < common.ServerUIDStart+common.ServerUIDCount; uid++ {
+		if _, taken := used[uid]; taken {
+			continue
+		}
+		if err := model.SaveServerUser(&model.ServerUser{ServerID: sid, UID: uid}); err != nil {
+			return 0, err
+		}
+		return uid, nil
+	}
+	return 0, ErrNoFreeUID
+}
+
+// prepareWorkDir 把 server 資料夾交給 server 的帳號，其他 server 無法列出或讀取
+func prepareWorkDir(workDir string, id *serverIdentity) error {
+	protectFilesOne.Do(protectBackendFiles)
+	if err := chownTree(workDir, id.UID, id.GID); err != nil {
+		return err
+	}
+	if err := os.Chmod(workDir, 0700); err != nil {
+		return err
+	}
+	// 上層資料夾只保留進入權限，看不到其他 server 的資料夾名稱
+	return os.Chmod(filepath.Dir(workDir), 0711)
+}
+
+// protectBackendFiles 資料庫與 .env 只留給 backend 自己讀寫
+func protectBackendFiles() {
+	dbPath, _, _ := strings.Cut(common.SQLitePath, "?")
+	for _, path := range []string{dbPath, dbPath + "-wal", dbPath + "-shm", ".env"} {
+		if err := os.Chmod(path, 0600); err != nil && !os.IsNotExist(err) {
+			common.SysError("failed to restrict " + path + ": " + err.Error())
+		}
+	}
+}
+
+// server 的帳號可以在自己的資料夾放 symlink (例如把 server.jar.tmp 指向 .env)，
+// backend 寫入 workDir 時都透過 os.Root 開檔，不會跟著 symlink 寫到資料夾外面
+
+// createInWorkDir 在 workDir 底下建立新的檔案，先刪掉同名的檔案或 symlink，再以 O_EXCL 建立
+func createInWorkDir(workDir, path string, perm os.FileMode) (*os.File, error) {
+	rel, err := filepath.Rel(workDir, path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := os.OpenRoot(workDir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	if err := root.Remove(rel); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return root.OpenFile(rel, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+}
+
+// mkdirAllInWorkDir 在 workDir 底下逐層建立資料夾，路徑中的 symlink 不能指到外面
+func mkdirAllInWorkDir(workDir, dir string, perm os.FileMode) error {
+	rel, err := filepath.Rel(workDir, dir)
+	if err != nil {
+		return err
+	}
+	root, err := os.OpenRoot(workDir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	cur := ""
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		if err := root.Mkdir(cur, perm); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWorkDirFile 先寫到 .tmp 再 rename，rename 只會取代 symlink 本身
+func writeWorkDirFile(workDir, path string, data []byte, perm os.FileMode) error {
+	tmpPath := path + ".tmp"
+	f, err := createInWorkDir(workDir, tmpPath, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// openWorkDirFile 讀取 workDir 底下的檔案，指向資料夾外面的 symlink 會開啟失敗
+func openWorkDirFile(workDir, path string) (*os.File, error) {
+	rel, err := filepath.Rel(workDir, path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := os.OpenRoot(workDir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.Open(rel)
+}
@@ -0,0 +1,61 @@
+//go:build linux
+
+// service/isolation_linux.go
+
+package service
+
+import (
+	"go-backend/common"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// applyIsolation 以 server 的帳號啟動 java，SERVER_NAMESPACES 時透過 sandbox 只看得到自己的資料夾
+func applyIsolation(cmd *exec.Cmd, id *serverIdentity) error {
+	if os.Geteuid() != 0 {
+		return ErrIsolationUnsupported
+	}
+	if common.ServerNamespaces {
+		return sandboxCommand(cmd, id)
+	}
+	attr := &syscall.SysProcAttr{
+		Credential: &syscall.Credential{
+			Uid:    uint32(id.UID),
+			Gid:    uint32(id.GID),
+			Groups: []uint32{}, // 不繼承 backend 的附加群組
+		},
+		// backend 結束時一併結束，避免留下無人管理的 process
+		Pdeathsig: syscall.SIGKILL,
+	}
+	cmd.SysProcAttr = attr
+	return nil
+}
+
+// 以 root 複製 server 的檔案時不跟隨 symlink，也不會卡在被換成 fifo 的檔案
+const (
+	oNoFollow = syscall.O_NOFOLLOW
+	oNonBlock = syscall.O_NONBLOCK
+)
+
+// chownTree 只修改擁有者不同的檔案，每次啟動都呼叫也不會太慢
+func chownTree(root string, uid, gid int) error {
+	if os.Geteuid() != 0 {
+		return ErrIsolationUnsupported
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) == uid && int(st.Gid) == gid {
+			return nil
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+// service/isolation_other.go
+
+package service
+
+import (
+	"os/exec"
+)
+
+const (
+	oNoFollow = 0
+	oNonBlock = 0
+)
+
+// RunSandboxIfRequested sandbox 只在 Linux 上使用
+func RunSandboxIfRequested() {}
+
+func applyIsolation(*exec.Cmd, *serverIdentity) error {
+	return ErrIsolationUnsupported
+}
+
+func chownTree(string, int, int) error {
+	return ErrIsolationUnsupported
+}
@@ -0,0 +1,492 @@
+// service/jobs.go
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// 進度更新的寫入與推送間隔
+	jobFlushInterval = 500 * time.Millisecond
+	jobHistoryDays   = 30
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// JobContext 給 job 內的流程回報進度與取得取消訊號，nil 時所有方法都是 no-op
+type JobContext struct {
+	ctx     context.Context
+	jm      *JobManager
+	mu      sync.Mutex
+	job     model.Job
+	flushed time.Time
+}
+
+func (jc *JobContext) Context() context.Context {
+	if jc == nil {
+		return context.Background()
+	}
+	return jc.ctx
+}
+
+// Step 切換目前的步驟並重設進度
+func (jc *JobContext) Step(step string) {
+	if jc == nil {
+		return
+	}
+	jc.mu.Lock()
+	jc.job.Step = step
+	jc.job.Done, jc.job.Total, jc.job.Unit = 0, 0, ""
+	jc.mu.Unlock()
+	jc.flush(true)
+}
+
+func (jc *JobContext) SetTotal(total int64, unit string) {
+	if jc == nil {
+		return
+	}
+	jc.mu.Lock()
+	jc.job.Total, jc.job.Unit = total, unit
+	jc.mu.Unlock()
+	jc.flush(true)
+}
+
+func (jc *JobContext) Add(n int64) {
+	if jc == nil {
+		return
+	}
+	jc.mu.Lock()
+	jc.job.Done += n
+	jc.mu.Unlock()
+	jc.flush(false)
+}
+
+func (jc *JobContext) snapshot() model.Job {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+	return jc.job
+}
+
+// flush 寫回資料庫並通知訂閱者，force 為 false 時會節流
+func (jc *JobContext) flush(force bool) {
+	jc.mu.Lock()
+	if !force && time.Since(jc.flushed) < jobFlushInterval {
+		jc.mu.Unlock()
+		return
+	}
+	jc.flushed = time.Now()
+	job := jc.job
+	jc.mu.Unlock()
+
+	if err := model.SaveJob(&job); err != nil {
+		common.SysError("failed to save job: " + err.Error())
+	}
+	jc.jm.publish(job)
+}
+
+// progressWriter 將寫入的 byte 數回報給 job
+type progressWriter struct {
+	jc *JobContext
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w.jc.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// JobFunc 回傳值會以 JSON 存在 job.Result
+type JobFunc func(jc *JobContext) (any, error)
+
+type jobEntry struct {
+	jc     *JobContext
+	cancel context.CancelFunc
+	fn     JobFunc
+	subs   map[chan model.Job]struct{}
+}
+
+// JobManager 同一個 server 的 job 依序執行，不同 server 之間平行
+type JobManager struct {
+	mu      sync.Mutex
+	entries map[uint]*jobEntry
+	queues  map[string][]*jobEntry
+}
+
+var (
+	defaultJobs     *JobManager
+	defaultJobsOnce sync.Once
+)
+
+// Jobs 全域共用的 JobManager，第一次使用時才建立 (需要資料庫已初始化)
+func Jobs() *JobManager {
+	defaultJobsOnce.Do(func() {
+		defaultJobs = NewJobManager()
+	})
+	return defaultJobs
+}
+
+func NewJobManager() *JobManager {
+	if err := model.FailInterruptedJobs(); err != nil {
+		common.SysError("failed to mark interrupted jobs: " + err.Error())
+	}
+	if err := model.PruneJobs(time.Now().AddDate(0, 0, -jobHistoryDays)); err != nil {
+		common.SysError("failed to prune jobs: " + err.Error())
+	}
+	return &JobManager{
+		entries: make(map[uint]*jobEntry),
+		queues:  make(map[string][]*jobEntry),
+	}
+}
+
+// Submit 建立 job 並排入 serverID 的佇列，serverID 為空時直接執行
+func (jm *JobManager) Submit(kind, serverID string, ownerID uint, fn JobFunc) (*model.Job, error) {
+	job := model.Job{Kind: kind, ServerID: serverID, OwnerID: ownerID, Status: model.JobQueued}
+	if err := model.CreateJob(&job); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &jobEntry{
+		jc:     &JobContext{ctx: ctx, jm: jm, job: job},
+		cancel: cancel,
+		fn:     fn,
+		subs:   make(map[chan model.Job]struct{}),
+	}
+
+	jm.mu.Lock()
+	jm.entries[job.ID] = e
+	if serverID == "" {
+		jm.mu.Unlock()
+		go jm.run(e)
+		return &job, nil
+	}
+	q := jm.queues[serverID]
+	jm.queues[serverID] = append(q, e)
+	start := len(q) == 0
+	jm.mu.Unlock()
+
+	if start {
+		go jm.drain(serverID)
+	}
+	return &job, nil
+}
+
+// QueueDepth 正在執行與排隊等待的 job 數量，每個 server 的佇列第一個是執行中的 job
+func (jm *JobManager) QueueDepth() (running, queued int) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	for _, q := range jm.queues {
+		if len(q) > 0 {
+			running++
+			queued += len(q) - 1
+		}
+	}
+	return running, queued
+}
+
+// Busy serverID 是否有排隊中或執行中的 job
+func (jm *JobManager) Busy(serverID string) bool {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	return len(jm.queues[serverID]) > 0
+}
+
+func (jm *JobManager) drain(serverID string) {
+	for {
+		jm.mu.Lock()
+		q := jm.queues[serverID]
+		if len(q) == 0 {
+			delete(jm.queues, serverID)
+			jm.mu.Unlock()
+			return
+		}
+		e := q[0]
+		jm.mu.Unlock()
+
+		jm.run(e)
+
+		jm.mu.Lock()
+		jm.queues[serverID] = jm.queues[serverID][1:]
+		jm.mu.Unlock()
+	}
+}
+
+func (jm *JobManager) run(e *jobEntry) {
+	jc := e.jc
+	defer e.cancel()
+
+	if jc.ctx.Err() == nil {
+		now := time.Now()
+		jc.mu.Lock()
+		jc.job.Status = model.JobRunning
+		jc.job.StartedAt = &now
+		jc.mu.Unlock()
+		jc.flush(true)
+
+		result, err := jm.call(e)
+
+		jc.mu.Lock()
+		// 已經做完的 job 就算最後才收到取消也算成功
+		switch {
+		case err == nil:
+			jc.job.Status = model.JobSucceeded
+			if result != nil {
+				if b, err := json.Marshal(result); err == nil {
+					jc.job.Result = string(b)
+				}
+			}
+		case jc.ctx.Err() != nil:
+			jc.job.Status = model.JobCanceled
+		default:
+			jc.job.Status = model.JobFailed
+			jc.job.Error = err.Error()
+		}
+		jc.mu.Unlock()
+	} else {
+		jc.mu.Lock()
+		jc.job.Status = model.JobCanceled
+		jc.mu.Unlock()
+	}
+
+	now := time.Now()
+	jc.mu.Lock()
+	jc.job.FinishedAt = &now
+	jc.mu.Unlock()
+	jc.flush(true)
+
+	jm.mu.Lock()
+	for ch := range e.subs {
+		close(ch)
+		delete(e.subs, ch)
+	}
+	delete(jm.entries, jc.job.ID)
+	jm.mu.Unlock()
+}
+
+// call 執行 job，panic 轉成錯誤避免整個 backend 掛掉
+func (jm *JobManager) call(e *jobEntry) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			common.SysError(fmt.Sprintf("job panic: %v", r))
+			err = errors.New("internal error")
+		}
+	}()
+	return e.fn(e.jc)
+}
+
+func (jm *JobManager) publish(job model.Job) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	e, ok := jm.entries[job.ID]
+	if !ok {
+		return
+	}
+	for ch := range e.subs {
+		select {
+		case ch <- job:
+		default: // 訂閱者太慢就略過這次更新
+		}
+	}
+}
+
+// Cancel 取消排隊中或執行中的 job
+func (jm *JobManager) Cancel(id uint) error {
+	jm.mu.Lock()
+	e, ok := jm.entries[id]
+	jm.mu.Unlock()
+	if !ok {
+		if _, err := model.GetJob(id); err != nil {
+			return ErrJobNotFound
+		}
+		return ErrJobFinished
+	}
+	e.cancel()
+	return nil
+}
+
+// Get 執行中的 job 回傳記憶體內最新進度，其他從資料庫讀
+func (jm *JobManager) Get(id uint) (*model.Job, error) {
+	jm.mu.Lock()
+	e, ok := jm.entries[id]
+	jm.mu.Unlock()
+	if ok {
+		job := e.jc.snapshot()
+		return &job, nil
+	}
+	job, err := model.GetJob(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// Subscribe 取得進度更新，job 結束時 channel 會被關閉；job 已結束時回傳 nil
+func (jm *JobManager) Subscribe(id uint) (<-chan model.Job, func()) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	e, ok := jm.entries[id]
+	if !ok {
+		return nil, func() {}
+	}
+	ch := make(chan model.Job, 16)
+	e.subs[ch] = struct{}{}
+	return ch, func() {
+		jm.mu.Lock()
+		defer jm.mu.Unlock()
+		if _, ok := e.subs[ch]; ok {
+			delete(e.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// copyTree 複製資料夾並以檔案數回報進度，取消時中止
+func copyTree(jc *JobContext, src, dst string) error {
+	return copyTreeFilter(jc, src, dst, nil)
+}
+
+// copyTreeFilter 同 copyTree，skip 回傳 true 的路徑 (相對於 src) 不複製，資料夾會整個跳過
+// 來源或目的地可能在 server 帳號可寫的資料夾裡，兩邊都透過 os.Root 存取，symlink 與特殊檔案一律略過
+func copyTreeFilter(jc *JobContext, src, dst string, skip func(rel string, info os.FileInfo) bool) error {
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if !srcInfo.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+	from, err := os.OpenRoot(src)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+
+	walk := func(fn func(rel string, info os.FileInfo) error) error {
+		return fs.WalkDir(from.FS(), ".", func(rel string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if rel != "." && skip != nil && skip(rel, info) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return fn(filepath.FromSlash(rel), info)
+		})
+	}
+
+	var files int64
+	err = walk(func(_ string, info os.FileInfo) error {
+		if !info.IsDir() {
+			files++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	jc.SetTotal(files, "files")
+
+	if err := os.MkdirAll(dst, srcInfo.Mode().Perm()); err != nil {
+		return err
+	}
+	to, err := os.OpenRoot(dst)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+	// 開啟的必須是 dst 本身，不能是被換上的 symlink 指向的資料夾
+	opened, err := to.Stat(".")
+	if err != nil {
+		return err
+	}
+	if info, err := os.Lstat(dst); err != nil || !os.SameFile(info, opened) {
+		return fmt.Errorf("%s is not a directory", dst)
+	}
+
+	ctx := jc.Context()
+	return walk(func(rel string, info os.FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			if err := to.Mkdir(rel, info.Mode().Perm()); err != nil && !errors.Is(err, fs.ErrExist) {
+				return err
+			}
+			return nil
+		}
+		in, err := from.OpenFile(rel, os.O_RDONLY|oNoFollow|oNonBlock, 0)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := to.OpenFile(rel, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|oNoFollow, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if err := copyFrom(in, out); err != nil {
+			return err
+		}
+		jc.Add(1)
+		return nil
+	})
+}
+
+// copyFileMode 複製單一檔案，來源與目的地都不跟隨 symlink
+func copyFileMode(src, dst string, mode os.FileMode) error {
+	in, err := os.OpenFile(src, os.O_RDONLY|oNoFollow|oNonBlock, 0)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|oNoFollow, mode.Perm())
+	if err != nil {
+		return err
+	}
+	return copyFrom(in, out)
+}
+
+// copyFrom 開啟之後再確認一次是一般檔案，避免走訪後被換掉；會關閉 out
+func copyFrom(in, out *os.File) error {
+	info, err := in.Stat()
+	if err == nil && !info.Mode().IsRegular() {
+		err = fmt.Errorf("%s is not a regular file", in.Name())
+	}
+	if err == nil {
+		_, err = io.Copy(out, in)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,292 @@
+// service/mcproto.go
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Minecraft Java 版的連線協定，只實作 handshake、Server List Ping、Login Start 與登入時的斷線訊息
+
+const (
+	mcStateStatus = 1
+	mcStateLogin  = 2
+	// handshake 與 status 封包都很小，超過代表不是 Minecraft 用戶端
+	mcMaxHandshakeLen = 1024
+	mcMaxPacketLen    = 2 << 20
+)
+
+var (
+	ErrVarIntTooBig = errors.New("varint too big")
+	ErrPacketTooBig = errors.New("packet too big")
+	ErrNotHandshake = errors.New("not a minecraft handshake")
+	ErrNotLogin     = errors.New("not a minecraft login")
+)
+
+// Handshake 用戶端連線後的第一個封包
+type Handshake struct {
+	ProtocolVersion int
+	ServerAddress   string
+	ServerPort      uint16
+	NextState       int
+}
+
+type StatusVersion struct {
+	Name     string `json:"name"`
+	Protocol int    `json:"protocol"`
+}
+
+type StatusPlayers struct {
+	Max    int `json:"max"`
+	Online int `json:"online"`
+}
+
+type StatusResponse struct {
+	Version     StatusVersion   `json:"version"`
+	Players     StatusPlayers   `json:"players"`
+	Description json.RawMessage `json:"description"`
+}
+
+func readVarInt(r io.ByteReader) (int, error) {
+	var value uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint32(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return int(int32(value)), nil
+		}
+	}
+	return 0, ErrVarIntTooBig
+}
+
+func writeVarInt(buf *bytes.Buffer, v int) {
+	u := uint32(v)
+	for {
+		if u&^0x7f == 0 {
+			buf.WriteByte(byte(u))
+			return
+		}
+		buf.WriteByte(byte(u&0x7f | 0x80))
+		u >>= 7
+	}
+}
+
+func readMCString(r *bytes.Reader, max int) (string, error) {
+	n, err := readVarInt(r)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n > max*4 || n > r.Len() {
+		return "", ErrPacketTooBig
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func writeMCString(buf *bytes.Buffer, s string) {
+	writeVarInt(buf, len(s))
+	buf.WriteString(s)
+}
+
+// readPacket 讀一個未壓縮的封包，回傳封包 ID 與內容
+func readPacket(r *bufio.Reader, maxLen int) (int, *bytes.Reader, error) {
+	length, err := readVarInt(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length <= 0 || length > maxLen {
+		return 0, nil, ErrPacketTooBig
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	body := bytes.NewReader(data)
+	id, err := readVarInt(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, body, nil
+}
+
+func writePacket(w io.Writer, id int, payload []byte) error {
+	var body bytes.Buffer
+	writeVarInt(&body, id)
+	body.Write(payload)
+	var out bytes.Buffer
+	writeVarInt(&out, body.Len())
+	out.Write(body.Bytes())
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func encodeHandshake(h Handshake) []byte {
+	var buf bytes.Buffer
+	writeVarInt(&buf, h.ProtocolVersion)
+	writeMCString(&buf, h.ServerAddress)
+	binary.Write(&buf, binary.BigEndian, h.ServerPort)
+	writeVarInt(&buf, h.NextState)
+	return buf.Bytes()
+}
+
+// readHandshake 舊版 (1.6 以前) 的 0xFE ping 會回傳 ErrNotHandshake
+func readHandshake(r *bufio.Reader) (*Handshake, error) {
+	if first, err := r.Peek(1); err != nil {
+		return nil, err
+	} else if first[0] == 0xfe {
+		return nil, ErrNotHandshake
+	}
+	id, body, err := readPacket(r, mcMaxHandshakeLen)
+	if err != nil {
+		return nil, err
+	}
+	if id != 0x00 {
+		return nil, ErrNotHandshake
+	}
+	var h Handshake
+	if h.ProtocolVersion, err = readVarInt(body); err != nil {
+		return nil, err
+	}
+	if h.ServerAddress, err = readMCString(body, 255); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(body, binary.BigEndian, &h.ServerPort); err != nil {
+		return nil, err
+	}
+	if h.NextState, err = readVarInt(body); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// readLoginStart 讀 handshake 之後的 Login Start，回傳玩家名稱；名稱只能是 1~16 個英數字或底線
+func readLoginStart(r *bufio.Reader) (string, error) {
+	id, body, err := readPacket(r, mcMaxHandshakeLen)
+	if err != nil {
+		return "", err
+	}
+	if id != 0x00 {
+		return "", ErrNotLogin
+	}
+	name, err := readMCString(body, 16)
+	if err != nil {
+		return "", err
+	}
+	if len(name) == 0 || len(name) > 16 {
+		return "", ErrNotLogin
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return "", ErrNotLogin
+		}
+	}
+	return name, nil
+}
+
+// Hostname 去掉 Forge 附加的 "\x00FML\x00"、結尾的點與大小寫差異
+func (h *Handshake) Hostname() string {
+	host, _, _ := strings.Cut(h.ServerAddress, "\x00")
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// chatJSON 把純文字包成 chat component
+func chatJSON(text string) json.RawMessage {
+	b, _ := json.Marshal(map[string]string{"text": text})
+	return b
+}
+
+// serveStatus 回應 status 請求與之後的 ping，version.protocol 為 0 時使用用戶端的版本
+func serveStatus(conn net.Conn, r *bufio.Reader, h *Handshake, status StatusResponse) error {
+	if status.Version.Protocol == 0 {
+		// 使用用戶端的版本，清單上才不會顯示版本不相容
+		status.Version.Protocol = h.ProtocolVersion
+	}
+	for i := 0; i < 2; i++ {
+		id, body, err := readPacket(r, mcMaxHandshakeLen)
+		if err != nil {
+			return err
+		}
+		switch id {
+		case 0x00:
+			data, err := json.Marshal(status)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			writeMCString(&buf, string(data))
+			if err := writePacket(conn, 0x00, buf.Bytes()); err != nil {
+				return err
+			}
+		case 0x01:
+			payload := make([]byte, body.Len())
+			io.ReadFull(body, payload)
+			return writePacket(conn, 0x01, payload)
+		default:
+			return fmt.Errorf("unexpected status packet 0x%02x", id)
+		}
+	}
+	return nil
+}
+
+// sendLoginDisconnect 在登入階段讓用戶端顯示訊息後斷線
+func sendLoginDisconnect(conn net.Conn, message string) error {
+	var buf bytes.Buffer
+	writeMCString(&buf, string(chatJSON(message)))
+	return writePacket(conn, 0x00, buf.Bytes())
+}
+
+// PingServer 對 server 送出 Server List Ping，取得線上人數
+func PingServer(addr string, timeout time.Duration) (*StatusResponse, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	var port uint16
+	fmt.Sscanf(portStr, "%d", &port)
+	hs := encodeHandshake(Handshake{ProtocolVersion: -1, ServerAddress: host, ServerPort: port, NextState: mcStateStatus})
+	if err := writePacket(conn, 0x00, hs); err != nil {
+		return nil, err
+	}
+	if err := writePacket(conn, 0x00, nil); err != nil {
+		return nil, err
+	}
+
+	id, body, err := readPacket(bufio.NewReader(conn), mcMaxPacketLen)
+	if err != nil {
+		return nil, err
+	}
+	if id != 0x00 {
+		return nil, fmt.Errorf("unexpected status packet 0x%02x", id)
+	}
+	data, err := readMCString(body, mcMaxPacketLen/4)
+	if err != nil {
+		return nil, err
+	}
+	var status StatusResponse
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
@@ -0,0 +1,103 @@
+// service/mcproto_test.go
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func packet(t *testing.T, id int, payload []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writePacket(&buf, id, payload); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func loginStart(name string) []byte {
+	var buf bytes.Buffer
+	writeMCString(&buf, name)
+	// 1.19 以後名稱後面還有 UUID，不影響解析
+	buf.Write(make([]byte, 16))
+	return buf.Bytes()
+}
+
+func TestReadHandshake(t *testing.T) {
+	want := Handshake{ProtocolVersion: 767, ServerAddress: "play.example.com", ServerPort: 25565, NextState: mcStateLogin}
+	r := bufio.NewReader(bytes.NewReader(packet(t, 0x00, encodeHandshake(want))))
+	got, err := readHandshake(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != want {
+		t.Fatalf("got %+v, want %+v", *got, want)
+	}
+}
+
+func TestReadHandshakeRejects(t *testing.T) {
+	valid := encodeHandshake(Handshake{ProtocolVersion: 767, ServerAddress: "a", ServerPort: 1, NextState: mcStateStatus})
+	var oversized bytes.Buffer
+	writeVarInt(&oversized, mcMaxHandshakeLen+1)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"legacy ping", []byte{0xfe, 0x01}, ErrNotHandshake},
+		{"wrong packet id", packet(t, 0x01, valid), ErrNotHandshake},
+		{"too long", oversized.Bytes(), ErrPacketTooBig},
+		{"varint overflow", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, ErrVarIntTooBig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readHandshake(bufio.NewReader(bytes.NewReader(tt.data)))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// 宣告的長度比實際內容長
+	truncated := packet(t, 0x00, valid)
+	if _, err := readHandshake(bufio.NewReader(bytes.NewReader(truncated[:len(truncated)-2]))); err == nil {
+		t.Fatal("expected error for truncated packet")
+	}
+}
+
+func TestReadLoginStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{"valid", loginStart("Steve_01"), "Steve_01", false},
+		{"sixteen chars", loginStart("abcdefghijklmnop"), "abcdefghijklmnop", false},
+		{"empty", loginStart(""), "", true},
+		{"too long", loginStart("abcdefghijklmnopq"), "", true},
+		{"invalid char", loginStart("Steve!"), "", true},
+		{"non ascii", loginStart("Stéve"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewReader(packet(t, 0x00, tt.data)))
+			got, err := readLoginStart(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	r := bufio.NewReader(bytes.NewReader(packet(t, 0x01, loginStart("Steve"))))
+	if _, err := readLoginStart(r); !errors.Is(err, ErrNotLogin) {
+		t.Fatalf("got %v, want ErrNotLogin", err)
+	}
+}
@@ -0,0 +1,169 @@
+// service/membership.go
+
+package service
+
+import (
+	"errors"
+	"go-backend/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 邀請的有效期限
+const inviteTTL = 7 * 24 * time.Hour
+
+var (
+	ErrMemberForbidden    = errors.New("not allowed for your role")
+	ErrInvalidServerRole  = errors.New("invalid role")
+	ErrMemberUserNotFound = errors.New("user not found")
+	ErrInviteNotFound     = errors.New("invite not found")
+	ErrInviteSelf         = errors.New("cannot invite yourself")
+	ErrAlreadyMember      = errors.New("user is already a member")
+	ErrNotMember          = errors.New("user is not a member of this server")
+	ErrTransferSelf       = errors.New("you already own this server")
+)
+
+// assignableRole actor 能給別人的角色：不能給 owner，也不能高於自己
+func assignableRole(actor *model.ServerMember, role string) error {
+	if !model.ValidServerRole(role) || role == model.ServerRoleOwner {
+		return ErrInvalidServerRole
+	}
+	if !model.ServerRoleAtLeast(actor.Role, role) {
+		return ErrMemberForbidden
+	}
+	return nil
+}
+
+func InviteMember(actor *model.ServerMember, username, role string) (*model.ServerInvite, error) {
+	if err := assignableRole(actor, role); err != nil {
+		return nil, err
+	}
+	user, err := model.GetUserByName(username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMemberUserNotFound
+		}
+		return nil, err
+	}
+	if user.ID == actor.UserID {
+		return nil, ErrInviteSelf
+	}
+	if _, err := model.GetServerMember(actor.ServerID, user.ID); err == nil {
+		return nil, ErrAlreadyMember
+	}
+
+	inv := &model.ServerInvite{
+		ServerID:  actor.ServerID,
+		InviterID: actor.UserID,
+		InviteeID: user.ID,
+		Role:      role,
+		ExpiresAt: time.Now().Add(inviteTTL),
+	}
+	if err := model.CreateServerInvite(inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func getInvite(id uint) (*model.ServerInvite, error) {
+	inv, err := model.GetServerInvite(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, err
+	}
+	return inv, nil
+}
+
+func AcceptInvite(userID, id uint) (*model.ServerInvite, error) {
+	inv, err := getInvite(id)
+	if err != nil {
+		return nil, err
+	}
+	if inv.InviteeID != userID {
+		return nil, ErrInviteNotFound
+	}
+	if err := model.AcceptServerInvite(inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// DeclineInvite 被邀請者或 server 的 admin 以上可以刪除邀請
+func DeclineInvite(userID, id uint) error {
+	inv, err := getInvite(id)
+	if err != nil {
+		return err
+	}
+	if inv.InviteeID != userID {
+		m, err := model.GetServerMember(inv.ServerID, userID)
+		if err != nil || !model.ServerRoleAtLeast(m.Role, model.ServerRoleAdmin) {
+			return ErrInviteNotFound
+		}
+	}
+	return model.DeleteServerInvite(inv.ID)
+}
+
+// targetMember 取得要被修改的成員，owner 不能被修改或移除
+func targetMember(serverID string, userID uint) (*model.ServerMember, error) {
+	m, err := model.GetServerMember(serverID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotMember
+		}
+		return nil, err
+	}
+	if m.Role == model.ServerRoleOwner {
+		return nil, ErrMemberForbidden
+	}
+	return m, nil
+}
+
+func SetMemberRole(actor *model.ServerMember, userID uint, role string) error {
+	if err := assignableRole(actor, role); err != nil {
+		return err
+	}
+	m, err := targetMember(actor.ServerID, userID)
+	if err != nil {
+		return err
+	}
+	if !model.ServerRoleAtLeast(actor.Role, m.Role) {
+		return ErrMemberForbidden
+	}
+	return model.SetServerMemberRole(actor.ServerID, userID, role)
+}
+
+// RemoveMember 成員可以自己離開，移除別人需要 admin 以上且不能移除更高的角色
+func RemoveMember(actor *model.ServerMember, userID uint) error {
+	m, err := targetMember(actor.ServerID, userID)
+	if err != nil {
+		return err
+	}
+	if userID != actor.UserID {
+		if !model.ServerRoleAtLeast(actor.Role, model.ServerRoleAdmin) || !model.ServerRoleAtLeast(actor.Role, m.Role) {
+			return ErrMemberForbidden
+		}
+	}
+	return model.RemoveServerMember(actor.ServerID, userID)
+}
+
+// TransferOwnership 只有 owner 可以轉移，對象必須已經是成員
+func TransferOwnership(actor *model.ServerMember, userID uint) error {
+	if actor.Role != model.ServerRoleOwner {
+		return ErrMemberForbidden
+	}
+	if userID == actor.UserID {
+		return ErrTransferSelf
+	}
+	if _, err := targetMember(actor.ServerID, userID); err != nil {
+		return err
+	}
+	// server 在檢查之後被刪除，或 owner 已經轉給別人
+	err := model.TransferServerOwnership(actor.ServerID, actor.UserID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
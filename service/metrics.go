@@ -0,0 +1,119 @@
+// service/metrics.go
+
+package service
+
+import (
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"io"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+var processStart = time.Now()
+
+type gaugeSample struct {
+	labels []string
+	value  float64
+}
+
+func writeGauge(w io.Writer, name, help string, labelNames []string, samples []gaugeSample) {
+	common.WriteMetricHeader(w, name, help, "gauge")
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", name, common.FormatLabels(labelNames, s.labels), common.FormatMetricValue(s.value))
+	}
+}
+
+// WriteMetrics 每個 server 的狀態與用量、job 佇列、封鎖數量與 Go runtime，scrape 時才計算
+func (s *ServerService) WriteMetrics(w io.Writer) error {
+	servers, err := model.GetAllServers()
+	if err != nil {
+		return err
+	}
+
+	serverLabels := []string{"server_id", "owner"}
+	stateLabels := []string{"server_id", "owner", "state"}
+	var up, state, cpu, rss, threads, players, uptime, tps, mspt, lagging []gaugeSample
+	now := time.Now()
+	for _, rec := range servers {
+		labels := []string{rec.ServerID, strconv.FormatUint(uint64(rec.OwnerID), 10)}
+		current, _ := s.Status(rec.ServerID)
+		for _, st := range []string{"running", "sleeping", "stopped"} {
+			v := 0.0
+			if st == current {
+				v = 1
+			}
+			state = append(state, gaugeSample{append(labels[:2:2], st), v})
+		}
+		if current != "running" {
+			up = append(up, gaugeSample{labels, 0})
+			continue
+		}
+		up = append(up, gaugeSample{labels, 1})
+
+		srv, ok := s.mgr.get(rec.ServerID)
+		if !ok {
+			continue
+		}
+		if snap, ok := srv.GetLatestSnapshot(); ok && snap.Running {
+			cpu = append(cpu, gaugeSample{labels, snap.CPU})
+			rss = append(rss, gaugeSample{labels, float64(snap.RSS)})
+			threads = append(threads, gaugeSample{labels, float64(snap.Threads)})
+			if !snap.StartedAt.IsZero() {
+				uptime = append(uptime, gaugeSample{labels, now.Sub(snap.StartedAt).Seconds()})
+			}
+		}
+		if online, at := srv.Players(); !at.IsZero() {
+			players = append(players, gaugeSample{labels, float64(online)})
+		}
+		if perf, ok := s.TickPerf(rec.ServerID); ok {
+			tps = append(tps, gaugeSample{labels, perf.TPS})
+			if perf.MSPT >= 0 {
+				mspt = append(mspt, gaugeSample{labels, perf.MSPT})
+			}
+			v := 0.0
+			if perf.Lagging {
+				v = 1
+			}
+			lagging = append(lagging, gaugeSample{labels, v})
+		}
+	}
+	writeGauge(w, "mcbackend_server_up", "1 if the server process is running.", serverLabels, up)
+	writeGauge(w, "mcbackend_server_state", "Current server state (running, sleeping or stopped).", stateLabels, state)
+	writeGauge(w, "mcbackend_server_cpu_percent", "CPU usage of the server process, may exceed 100 on multiple cores.", serverLabels, cpu)
+	writeGauge(w, "mcbackend_server_rss_bytes", "Resident memory of the server process.", serverLabels, rss)
+	writeGauge(w, "mcbackend_server_threads", "Threads of the server process.", serverLabels, threads)
+	writeGauge(w, "mcbackend_server_players", "Online players from the last server list ping.", serverLabels, players)
+	writeGauge(w, "mcbackend_server_uptime_seconds", "Seconds since the server process started.", serverLabels, uptime)
+	writeGauge(w, "mcbackend_server_tps", "Ticks per second from the last tick query or lag warnings.", serverLabels, tps)
+	writeGauge(w, "mcbackend_server_mspt", "Milliseconds per tick from the last tick query.", serverLabels, mspt)
+	writeGauge(w, "mcbackend_server_lagging", "1 if TPS has stayed below TPS_LOW_THRESHOLD for TPS_LOW_MINUTES.", serverLabels, lagging)
+
+	running, queued := s.jobs.QueueDepth()
+	writeGauge(w, "mcbackend_jobs_running", "Background jobs currently running.", nil, []gaugeSample{{nil, float64(running)}})
+	writeGauge(w, "mcbackend_jobs_queued", "Background jobs waiting for an earlier job on the same server.", nil, []gaugeSample{{nil, float64(queued)}})
+	if banned, err := model.CountBannedIPs(); err == nil {
+		writeGauge(w, "mcbackend_banned_ips", "IPs currently banned.", nil, []gaugeSample{{nil, float64(banned)}})
+	}
+
+	writeRuntimeMetrics(w)
+	return nil
+}
+
+func writeRuntimeMetrics(w io.Writer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	writeGauge(w, "go_info", "Go version of the backend.", []string{"version"}, []gaugeSample{{[]string{runtime.Version()}, 1}})
+	writeGauge(w, "go_goroutines", "Number of goroutines.", nil, []gaugeSample{{nil, float64(runtime.NumGoroutine())}})
+	writeGauge(w, "go_memstats_alloc_bytes", "Bytes of allocated heap objects.", nil, []gaugeSample{{nil, float64(ms.Alloc)}})
+	writeGauge(w, "go_memstats_heap_inuse_bytes", "Bytes in in-use heap spans.", nil, []gaugeSample{{nil, float64(ms.HeapInuse)}})
+	writeGauge(w, "go_memstats_sys_bytes", "Bytes of memory obtained from the OS.", nil, []gaugeSample{{nil, float64(ms.Sys)}})
+	common.WriteMetricHeader(w, "go_gc_cycles_total", "Completed GC cycles.", "counter")
+	fmt.Fprintf(w, "go_gc_cycles_total %d\n", ms.NumGC)
+	common.WriteMetricHeader(w, "go_gc_pause_seconds_total", "Total GC stop-the-world pause time.", "counter")
+	fmt.Fprintf(w, "go_gc_pause_seconds_total %s\n", common.FormatMetricValue(float64(ms.PauseTotalNs)/1e9))
+	writeGauge(w, "process_start_time_seconds", "Start time of the backend since unix epoch.", nil, []gaugeSample{{nil, float64(processStart.Unix())}})
+}
@@ -2,12 +2,15 @@
 package service
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"go-backend/common"
-	"net/http"
+	"go-backend/model"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 type CreateServerRequest struct {
@@ -15,7 +18,23 @@ type CreateServerRequest struct {
 	ServerVer       string `json:"server_ver"`
 	FabricLoader    string `json:"fabric_loader"`
 	FabricInstaller string `json:"fabric_installer"`
+	LoaderVer       string `json:"loader_ver"` // quilt / forge / neoforge 的 loader 版本
+	Build           string `json:"build"`      // paper / purpur 的 build
 	DisplayName     string `json:"display_name"`
+	TemplateID      uint   `json:"template_id"` // 從 template 建立時不需要 server_type / server_ver
+}
+
+// InstallOptions 轉成 provider 用的安裝參數，fabric_loader 與 loader_ver 擇一即可
+func (r *CreateServerRequest) InstallOptions() InstallOptions {
+	loader := r.LoaderVer
+	if loader == "" {
+		loader = r.FabricLoader
+	}
+	return InstallOptions{
+		Loader:    loader,
+		Installer: r.FabricInstaller,
+		Build:     r.Build,
+	}
 }
 
 type GameVersion struct {
@@ -24,7 +43,8 @@ type GameVersion struct {
 }
 
 type ServerService struct {
-	mgr *ServerManager
+	mgr  *ServerManager
+	jobs *JobManager
 }
 
 func ErrorFileClear(path string) error {
@@ -35,10 +55,45 @@ func ErrorFileClear(path string) error {
 }
 
 func NewServerService(mgr *ServerManager) *ServerService {
-	return &ServerService{mgr: mgr}
+	return &ServerService{mgr: mgr, jobs: Jobs()}
 }
 
+// Start oid 為 server 的 owner，同時執行數量與記憶體以 owner 的方案計算
 func (s *ServerService) Start(sid, oid, workDir, maxMem, minMem string, args []string) (*Server, error) {
+	// 休眠中的 server 先把 port 讓出來，啟動失敗時繼續休眠
+	sleeper := idle.release(sid)
+	srv, err := s.start(sid, oid, workDir, maxMem, minMem, args)
+	if err != nil && sleeper != nil {
+		if err := idle.listen(sleeper); err != nil {
+			common.SysError("failed to resume sleeping listener for " + sid + ": " + err.Error())
+		}
+	}
+	return srv, err
+}
+
+// startMu 從配額檢查到 process 啟動完成之間不能有其他啟動，
+// 否則兩個請求可能同時通過檢查，或同一個 server 被啟動兩次
+var startMu sync.Mutex
+
+func (s *ServerService) start(sid, oid, workDir, maxMem, minMem string, args []string) (*Server, error) {
+	startMu.Lock()
+	defer startMu.Unlock()
+	if !s.mgr.IsRunning(sid) {
+		ownerID, err := strconv.ParseUint(oid, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkStartQuota(uint(ownerID), maxMem); err != nil {
+			return nil, err
+		}
+		rec, err := model.GetServerRecord(sid)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkServerDiskLimit(rec, false); err != nil {
+			return nil, err
+		}
+	}
 	return s.mgr.StartServer(sid, oid, workDir, maxMem, minMem, args)
 }
 
@@ -46,16 +101,24 @@ func (s *ServerService) GetServerUsage(sid string) (Snapshot, error) {
 	return s.mgr.GetServerUsage(sid)
 }
 
+// Stop 休眠中的 server 只關掉 listener，之後登入不會再叫醒
 func (s *ServerService) Stop(sid string) error {
+	if idle.release(sid) != nil {
+		return nil
+	}
 	return s.mgr.StopServer(sid)
 }
 
 func (s *ServerService) Status(sid string) (string, error) {
+	if idle.isSleeping(sid) {
+		return "sleeping", nil
+	}
 	return s.mgr.GetServerStatus(sid)
 }
 
 func (s *ServerService) OwnerCount(oid string) int {
-	return s.mgr.countByOwner(oid)
+	running, _ := s.mgr.ownerUsage(oid)
+	return running
 }
 
 func (s *ServerService) ReadLatestLog(sid string) (string, error) {
@@ -66,52 +129,185 @@ func (s *ServerService) SendCommand(sid string, command string) error {
 	return s.mgr.SendCommand(sid, command)
 }
 
-func (s *ServerService) Backup(sid, workDir string) error {
-	return s.mgr.BackUp(sid, workDir)
-}
+// CreateServerJob 先同步檢查類型與版本 (或 template)，下載與安裝在背景 job 執行，結果帶有 server_id
+func CreateServerJob(ownerID uint, req CreateServerRequest) (*model.Job, error) {
+	oid := strconv.FormatUint(uint64(ownerID), 10)
+	if err := CheckCreateQuota(ownerID); err != nil {
+		return nil, err
+	}
 
-func (s *ServerService) RollBackSave(sid, file, workDir string) error {
-	return s.mgr.ServerSaveRollBack(sid, file, workDir)
+	if req.TemplateID != 0 {
+		t, err := GetTemplate(ownerID, req.TemplateID)
+		if err != nil {
+			return nil, err
+		}
+		return Jobs().Submit("create", "", ownerID, func(jc *JobContext) (any, error) {
+			serverID, info, err := CreateServerFromTemplate(jc, oid, t)
+			return registerServer(ownerID, serverID, req.DisplayName, info, err)
+		})
+	}
+
+	if _, err := GetServerType(req.ServerType); err != nil {
+		return nil, err
+	}
+	if err := ValidateVersionString(req.ServerVer); err != nil {
+		return nil, err
+	}
+	return Jobs().Submit("create", "", ownerID, func(jc *JobContext) (any, error) {
+		opts := req.InstallOptions()
+		opts.Job = jc
+		serverID, info, err := CreateServer(oid, req.ServerType, req.ServerVer, opts)
+		return registerServer(ownerID, serverID, req.DisplayName, info, err)
+	})
 }
 
-func (s *ServerService) ListBackups(sid, workDir string) ([]string, error) {
-	return s.mgr.ServerSaveList(sid, workDir)
+// DuplicateJob 複製已停止的 server 到新的 server ID
+func (s *ServerService) DuplicateJob(ownerID uint, rec *model.UserMinecraftServer, displayName string) (*model.Job, error) {
+	if s.mgr.IsRunning(rec.ServerID) {
+		return nil, ErrServerRunning
+	}
+	if err := CheckCreateQuota(ownerID); err != nil {
+		return nil, err
+	}
+	return s.jobs.Submit("duplicate", rec.ServerID, ownerID, func(jc *JobContext) (any, error) {
+		serverID, info, err := DuplicateServer(jc, strconv.FormatUint(uint64(ownerID), 10), rec)
+		return registerServer(ownerID, serverID, displayName, info, err)
+	})
 }
 
-func CreateServer(ownerID string, serverType string, serverVer string, fabricLoader string, fabricInstaller string) (string, error) {
-	var idPerFix, fURL, vURL string
-	var err error
+// registerServer 建立完成後寫入資料庫，失敗時刪掉剛建立的檔案
+func registerServer(ownerID uint, serverID, displayName string, info ServerVersionInfo, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	sysPath := filepath.Join(common.MinecraftServerPath, serverID)
+	// 同時建立多個 server 時，送出時的檢查可能都通過，寫入前再檢查一次
+	if err := CheckCreateQuota(ownerID); errors.Is(err, ErrQuotaExceeded) {
+		ErrorFileClear(sysPath)
+		return nil, err
+	}
+	if err := model.AddServerToUser(ownerID, serverID, displayName, sysPath,
+		info.ServerType, info.MCVersion, info.LoaderVersion); err != nil {
+		ErrorFileClear(sysPath)
+		return nil, fmt.Errorf("failed to add server to user: %w", err)
+	}
+	return map[string]any{"server_id": serverID, "version": info}, nil
+}
 
-	if fabricLoader == "" {
-		fabricLoader = common.LatestFabricLoaderVersion // 預設值
+// BackupJob 備份的數量與大小算在 server owner 的方案
+func (s *ServerService) BackupJob(actorID uint, rec *model.UserMinecraftServer) (*model.Job, error) {
+	sid, workDir := rec.ServerID, rec.SystemPath
+	if s.mgr.IsRunning(sid) {
+		return nil, ErrServerRunning
+	}
+	if err := checkBackupQuota(rec); err != nil {
+		return nil, err
 	}
+	if err := checkServerDiskLimit(rec, true); err != nil {
+		return nil, err
+	}
+	return s.jobs.Submit("backup", sid, actorID, func(jc *JobContext) (any, error) {
+		name, err := s.mgr.BackUp(jc, sid, workDir)
+		invalidateDiskUsage(sid)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"backup": name}, nil
+	})
+}
 
-	if fabricInstaller == "" {
-		fabricInstaller = common.LatestFabricInstallerVersion
+func (s *ServerService) RollBackSaveJob(ownerID uint, sid, file, workDir string) (*model.Job, error) {
+	if s.mgr.IsRunning(sid) {
+		return nil, ErrServerRunning
+	}
+	if file == "" || file != filepath.Base(file) {
+		return nil, os.ErrNotExist
 	}
+	if _, err := os.Stat(filepath.Join(serverBackupDir(sid, workDir), file)); err != nil {
+		return nil, os.ErrNotExist
+	}
+	return s.jobs.Submit("restore", sid, ownerID, func(jc *JobContext) (any, error) {
+		err := s.mgr.ServerSaveRollBack(jc, sid, file, workDir)
+		invalidateDiskUsage(sid)
+		return nil, err
+	})
+}
 
-	switch serverType {
-	case "Fabric":
-		idPerFix = "mcsfv-"
-		fURL = fmt.Sprintf(
-			"https://meta.fabricmc.net/v2/versions/loader/%s/%s/%s/server/jar",
-			serverVer, fabricLoader, fabricInstaller,
-		)
-	case "Vanilla":
-		if idPerFix == "" {
-			idPerFix = "mcsvv-"
-		}
-		url, ok := common.VanillaServerUrl[serverVer]
-		if !ok {
-			return "", fmt.Errorf("unsupported server version: %s", serverVer)
+// UpgradeJob 與備份、還原排在同一個佇列，下載與安裝期間不會被啟動或同時還原
+func (s *ServerService) UpgradeJob(actorID uint, sid, workDir string, req UpgradeRequest) (*model.Job, error) {
+	if err := s.mgr.CheckUpgrade(sid, workDir, req); err != nil {
+		return nil, err
+	}
+	return s.jobs.Submit("upgrade", sid, actorID, func(jc *JobContext) (any, error) {
+		result, err := s.mgr.UpgradeServer(jc, sid, workDir, req)
+		invalidateDiskUsage(sid)
+		if err != nil {
+			return nil, err
 		}
-		vURL = url
-	default:
-		return "", fmt.Errorf("unsupported server type: %s", serverType)
+		return result, nil
+	})
+}
+
+func (s *ServerService) ListPorts(sid string) ([]model.ServerPort, error) {
+	return model.ListServerPorts(sid)
+}
+
+// AddPort 新的 port 在下次啟動時才會傳給 server
+func (s *ServerService) AddPort(sid, name, protocol string) (*model.ServerPort, error) {
+	return s.mgr.ports.Add(sid, name, protocol)
+}
+
+func (s *ServerService) RemovePort(sid, name string) error {
+	return s.mgr.ports.Remove(sid, name)
+}
+
+func (s *ServerService) IsRunning(sid string) bool {
+	return s.mgr.IsRunning(sid)
+}
+
+// UploadProperties 整份取代 server.properties
+func (s *ServerService) UploadProperties(rec *model.UserMinecraftServer, authorID uint, texts string) error {
+	if s.mgr.IsRunning(rec.ServerID) {
+		return ErrPropertiesWhileRunning
 	}
+	return TrackConfigWrite(rec.ServerID, rec.SystemPath, "server.properties", authorID, "upload properties", func() error {
+		return ReplaceProperty(rec.SystemPath, texts)
+	})
+}
 
-	uid := common.GetRandomIntString(4)
-	serverID := idPerFix + serverVer + "-" + uid + "-" + "OID-" + ownerID
+func (s *ServerService) Job(id uint) (*model.Job, error) {
+	return s.jobs.Get(id)
+}
+
+func (s *ServerService) CancelJob(id uint) error {
+	return s.jobs.Cancel(id)
+}
+
+func (s *ServerService) SubscribeJob(id uint) (<-chan model.Job, func()) {
+	return s.jobs.Subscribe(id)
+}
+
+func (s *ServerService) ListJobs(userID uint, sid string, limit int) ([]model.Job, error) {
+	return model.ListJobs(userID, sid, limit)
+}
+
+func (s *ServerService) ListBackups(sid, workDir string) ([]string, error) {
+	return s.mgr.ServerSaveList(sid, workDir)
+}
+
+func CreateServer(ownerID string, serverType string, serverVer string, opts InstallOptions) (string, ServerVersionInfo, error) {
+	var err error
+	info := ServerVersionInfo{ServerType: serverType, MCVersion: serverVer}
+
+	provider, err := GetServerType(serverType)
+	if err != nil {
+		return "", info, err
+	}
+	if err = ValidateVersionString(serverVer); err != nil {
+		return "", info, err
+	}
+
+	serverID := newServerID(provider, serverVer, ownerID)
 
 	sysPath := filepath.Join(common.MinecraftServerPath, serverID)
 	// defer 一個清理機制：若後續 err != nil，就把 sysPath 刪掉
@@ -121,48 +317,60 @@ func CreateServer(ownerID string, serverType string, serverVer string, fabricLoa
 				msg := fmt.Sprintf("warning: %v", clearErr)
 				common.SysLog(msg)
 			}
+			// installer 以 server 的帳號執行時已經分配了 UID
+			if delErr := model.DeleteServerUser(serverID); delErr != nil {
+				common.SysError("failed to release server uid: " + delErr.Error())
+			}
 		}
 	}()
 
 	if err = os.MkdirAll(sysPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create server directory %s: %w", sysPath, err)
-	}
-
-	if vURL != "" {
-		vanillaJarPath := filepath.Join(sysPath, "server.jar")
-		if err = common.DownloadFile(vanillaJarPath, vURL); err != nil {
-			return "", fmt.Errorf("failed to download vanilla server jar: %w", err)
-		}
+		return "", info, fmt.Errorf("failed to create server directory %s: %w", sysPath, err)
 	}
 
-	if fURL != "" {
-		fabricInstallerPath := filepath.Join(sysPath, "server.jar")
-		if err = common.DownloadFile(fabricInstallerPath, fURL); err != nil {
-			return "", fmt.Errorf("failed to download fabric installer: %w", err)
-		}
+	if info.LoaderVersion, err = provider.Install(sysPath, serverVer, opts); err != nil {
+		return "", info, err
 	}
 
 	eulaPath := filepath.Join(sysPath, "eula.txt")
 	eulaContent := []byte("eula=true\n")
-	if err = os.WriteFile(eulaPath, eulaContent, 0644); err != nil {
-		return "", fmt.Errorf("failed to write eula.txt: %w", err)
+	if err = writeWorkDirFile(sysPath, eulaPath, eulaContent, 0644); err != nil {
+		return "", info, fmt.Errorf("failed to write eula.txt: %w", err)
 	}
 
-	return serverID, nil
+	return serverID, info, nil
 }
 
-func GetAllFabricVersions() ([]string, error) {
-	resp, err := http.Get("https://meta.fabricmc.net/v2/versions/game")
+// newServerID 產生 <prefix><版本>-<隨機四碼>-OID-<owner> 格式的 server ID
+func newServerID(provider ServerTypeProvider, serverVer, ownerID string) string {
+	uid := common.GetRandomIntString(4)
+	return provider.IDPrefix() + serverVer + "-" + uid + "-" + "OID-" + ownerID
+}
+
+// ParseServerID 從 server ID (例如 mcsfv-1.20.1-1234-OID-1) 取出 server 類型與 minecraft 版本
+func ParseServerID(sid string) (string, string) {
+	provider, err := ServerTypeForID(sid)
 	if err != nil {
-		return nil, err
+		return "", ""
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get fabric versions, status code: %d", resp.StatusCode)
+	serverType := provider.Name()
+
+	rest := sid[len(provider.IDPrefix()):]
+	idx := strings.Index(rest, "-OID-")
+	if idx < 0 {
+		return serverType, ""
+	}
+	rest = rest[:idx]
+	// 去掉最後的隨機數字段
+	if i := strings.LastIndex(rest, "-"); i >= 0 {
+		rest = rest[:i]
 	}
+	return serverType, rest
+}
 
-	var gv []GameVersion
-	if err := json.NewDecoder(resp.Body).Decode(&gv); err != nil {
+func GetAllFabricVersions() ([]string, error) {
+	gv, err := fabricMeta.GameVersions()
+	if err != nil {
 		return nil, err
 	}
 
@@ -173,7 +381,24 @@ func GetAllFabricVersions() ([]string, error) {
 	return versions, nil
 }
 
-func GetAllVanillaVersions() (map[string]string, error) {
-	all := common.VanillaServerUrl
+// GetServerTypeVersions 列出指定 server 類型可安裝的版本
+func GetServerTypeVersions(serverType string) ([]string, error) {
+	provider, err := GetServerType(serverType)
+	if err != nil {
+		return nil, err
+	}
+	return provider.ListVersions()
+}
+
+// GetAllVanillaVersions version -> server.jar URL，kind 為 release / snapshot 或空字串
+func GetAllVanillaVersions(kind string) (map[string]string, error) {
+	list, err := ListVanillaVersions(kind)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]string, len(list))
+	for _, v := range list {
+		all[v.ID] = v.ServerURL
+	}
 	return all, nil
 }
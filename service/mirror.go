@@ -0,0 +1,133 @@
+// service/mirror.go
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"net/http"
+	"os"
+)
+
+// PopulateMirror 連線上游並把 metadata、指定版本的安裝檔與 Modrinth project 記錄到 dir，
+// 之後以 MIRROR_DIR=dir (或用靜態檔案伺服器提供 dir 並設定 MIRROR_URL) 離線使用
+func PopulateMirror(dir string, versions, mods []string) error {
+	common.EnableMirrorRecording(dir)
+	failed := 0
+	report := func(what string, err error) {
+		if err != nil {
+			failed++
+			common.SysError(fmt.Sprintf("mirror: %s: %v", what, err))
+		}
+	}
+
+	n, err := syncVersionCatalog(true)
+	report("version catalog", err)
+	common.SysLog(fmt.Sprintf("mirror: recorded %d minecraft version(s)", n))
+
+	var release struct{}
+	report("latest release", getUpstreamJSON(fmt.Sprintf("%s/repos/%s/releases/latest", common.GitHubAPIURL, repo), &release))
+
+	for _, name := range ServerTypeNames() {
+		provider, _ := GetServerType(name)
+		_, err := provider.ListVersions()
+		report(name+" versions", err)
+
+		for _, v := range versions {
+			spec, err := provider.Artifact(v, InstallOptions{})
+			if err != nil {
+				// 不是每種類型都支援所有版本
+				common.SysLog(fmt.Sprintf("mirror: skip %s %s: %v", name, v, err))
+				continue
+			}
+			if spec.Key.Name == "installer.jar" {
+				// forge / neoforge installer 需要的 libraries 也一起記錄
+				report(fmt.Sprintf("%s %s installer", name, v), recordInstaller(spec.Source.URL))
+			} else {
+				report(fmt.Sprintf("%s %s artifact", name, v), recordURL(spec.Source.URL))
+			}
+		}
+	}
+
+	// Fabric 的 loader / installer 列表，建立 server 時選擇版本用
+	_, err = fabricMeta.Loaders()
+	report("fabric loaders", err)
+	_, err = fabricMeta.Installers()
+	report("fabric installers", err)
+	for _, v := range versions {
+		if _, err := fabricMeta.LoadersFor(v); err != nil {
+			common.SysLog(fmt.Sprintf("mirror: skip fabric loaders for %s: %v", v, err))
+		}
+	}
+
+	// 搜尋無法離線使用，只記錄指定 project 在各版本與 loader 下的安裝
+	mc := NewModrinthClient()
+	for _, project := range mods {
+		for _, name := range ServerTypeNames() {
+			loader, err := ModLoaderFor(name)
+			if err != nil {
+				continue
+			}
+			for _, v := range versions {
+				err := mc.MirrorProject(project, v, loader)
+				if errors.Is(err, ErrNoCompatibleVersion) {
+					common.SysLog(fmt.Sprintf("mirror: skip %s for %s %s: %v", project, loader, v, err))
+					continue
+				}
+				report(fmt.Sprintf("modrinth %s %s %s", project, loader, v), err)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("mirror populated with %d error(s)", failed)
+	}
+	return nil
+}
+
+// recordURL 下載一次讓 recording transport 寫進 mirror
+func recordURL(url string) error {
+	return downloadTo(io.Discard, url)
+}
+
+func downloadTo(w io.Writer, url string) error {
+	resp, err := common.NewHTTPClient(artifactDownloadTimeout).Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status from %s: %s", url, resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// recordInstaller 記錄 installer jar，並依 installer 內的清單記錄 libraries
+func recordInstaller(url string) error {
+	tmp, err := os.CreateTemp("", "mirror-installer-*.jar")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = downloadTo(tmp, url)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	profile, err := readInstallProfile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	for _, lib := range profile.Libraries {
+		if err := recordURL(lib.Downloads.Artifact.URL); err != nil {
+			return fmt.Errorf("library %s: %w", lib.Name, err)
+		}
+	}
+	return nil
+}
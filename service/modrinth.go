@@ -0,0 +1,406 @@
+// service/modrinth.go
+
+package service
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrModsUnsupported     = errors.New("server type does not support mods")
+	ErrNoCompatibleVersion = errors.New("no compatible version found")
+	ErrModrinthNotFound    = errors.New("modrinth project or version not found")
+)
+
+const modrinthMaxDepDepth = 8
+
+// ModrinthClient 為 Modrinth v2 API 的簡易 client，BaseURL 可以指向本地的假 server 方便測試
+type ModrinthClient struct {
+	BaseURL string
+	http    *http.Client
+}
+
+type ModrinthSearchHit struct {
+	ProjectID     string   `json:"project_id"`
+	Slug          string   `json:"slug"`
+	Title         string   `json:"title"`
+	Description   string   `json:"description"`
+	Author        string   `json:"author"`
+	Downloads     int64    `json:"downloads"`
+	IconURL       string   `json:"icon_url"`
+	Categories    []string `json:"categories"`
+	ServerSide    string   `json:"server_side"`
+	LatestVersion string   `json:"latest_version"`
+}
+
+type ModrinthSearchResult struct {
+	Hits      []ModrinthSearchHit `json:"hits"`
+	Offset    int                 `json:"offset"`
+	Limit     int                 `json:"limit"`
+	TotalHits int                 `json:"total_hits"`
+}
+
+type ModrinthDependency struct {
+	VersionID      string `json:"version_id"`
+	ProjectID      string `json:"project_id"`
+	FileName       string `json:"file_name"`
+	DependencyType string `json:"dependency_type"` // required, optional, incompatible, embedded
+}
+
+type ModrinthFile struct {
+	Hashes struct {
+		SHA512 string `json:"sha512"`
+		SHA1   string `json:"sha1"`
+	} `json:"hashes"`
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Primary  bool   `json:"primary"`
+	Size     int64  `json:"size"`
+}
+
+type ModrinthVersion struct {
+	ID            string               `json:"id"`
+	ProjectID     string               `json:"project_id"`
+	Name          string               `json:"name"`
+	VersionNumber string               `json:"version_number"`
+	VersionType   string               `json:"version_type"`
+	GameVersions  []string             `json:"game_versions"`
+	Loaders       []string             `json:"loaders"`
+	Dependencies  []ModrinthDependency `json:"dependencies"`
+	Files         []ModrinthFile       `json:"files"`
+	DatePublished time.Time            `json:"date_published"`
+}
+
+// InstalledMod 為一次安裝所新增的檔案
+type InstalledMod struct {
+	FileName      string `json:"file_name"`
+	ProjectID     string `json:"project_id"`
+	VersionID     string `json:"version_id"`
+	VersionNumber string `json:"version_number"`
+	Dependency    bool   `json:"dependency"`
+}
+
+func NewModrinthClient() *ModrinthClient {
+	return &ModrinthClient{
+		BaseURL: strings.TrimSuffix(common.ModrinthAPIURL, "/"),
+		http:    common.NewHTTPClient(30 * time.Second),
+	}
+}
+
+// ModLoaderFor 回傳 server 類型對應到 Modrinth 的 loader 名稱
+func ModLoaderFor(serverType string) (string, error) {
+	switch serverType {
+	case "Fabric":
+		return "fabric", nil
+	case "Quilt":
+		return "quilt", nil
+	case "Forge":
+		return "forge", nil
+	case "NeoForge":
+		return "neoforge", nil
+	}
+	return "", ErrModsUnsupported
+}
+
+func (mc *ModrinthClient) getJSON(path string, query url.Values, out any) error {
+	u := mc.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	// Modrinth 要求帶能辨識的 User-Agent
+	req.Header.Set("User-Agent", "carsupper665/mc-server-backend/"+common.Version)
+
+	resp, err := mc.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("modrinth request %s error: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrModrinthNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("modrinth request %s bad status: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Search 搜尋 mod，會以 loader 與 minecraft 版本過濾
+func (mc *ModrinthClient) Search(query, mcVer, loader string, limit, offset int) (*ModrinthSearchResult, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	facets := [][]string{{"project_type:mod"}}
+	if loader != "" {
+		facets = append(facets, []string{"categories:" + loader})
+	}
+	if mcVer != "" {
+		facets = append(facets, []string{"versions:" + mcVer})
+	}
+	// 只找 server 端能用的
+	facets = append(facets, []string{"server_side:required", "server_side:optional"})
+	rawFacets, _ := json.Marshal(facets)
+
+	q := url.Values{}
+	q.Set("query", query)
+	q.Set("facets", string(rawFacets))
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(offset))
+
+	var result ModrinthSearchResult
+	if err := mc.getJSON("/search", q, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ProjectVersions 取得符合 minecraft 版本與 loader 的版本清單 (新的在前)
+func (mc *ModrinthClient) ProjectVersions(projectID, mcVer, loader string) ([]ModrinthVersion, error) {
+	q := url.Values{}
+	if loader != "" {
+		q.Set("loaders", fmt.Sprintf(`["%s"]`, loader))
+	}
+	if mcVer != "" {
+		q.Set("game_versions", fmt.Sprintf(`["%s"]`, mcVer))
+	}
+
+	var versions []ModrinthVersion
+	if err := mc.getJSON("/project/"+url.PathEscape(projectID)+"/version", q, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (mc *ModrinthClient) GetVersion(versionID string) (*ModrinthVersion, error) {
+	var v ModrinthVersion
+	if err := mc.getJSON("/version/"+url.PathEscape(versionID), nil, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// resolveVersion versionID 有給就直接取，否則取 project 最新的相容版本 (優先 release)
+func (mc *ModrinthClient) resolveVersion(projectID, versionID, mcVer, loader string) (*ModrinthVersion, error) {
+	if versionID != "" {
+		v, err := mc.GetVersion(versionID)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(v.Loaders, loader) || !slices.Contains(v.GameVersions, mcVer) {
+			return nil, fmt.Errorf("%w: %s does not support %s %s", ErrNoCompatibleVersion, v.VersionNumber, loader, mcVer)
+		}
+		return v, nil
+	}
+
+	versions, err := mc.ProjectVersions(projectID, mcVer, loader)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: project %s for %s %s", ErrNoCompatibleVersion, projectID, loader, mcVer)
+	}
+	for i := range versions {
+		if versions[i].VersionType == "release" {
+			return &versions[i], nil
+		}
+	}
+	return &versions[0], nil
+}
+
+// Install 安裝指定 project/version，並遞迴安裝 required 的相依
+func (mc *ModrinthClient) Install(sid, workDir, projectID, versionID, mcVer, loader string) ([]InstalledMod, error) {
+	installed := make([]InstalledMod, 0)
+	visited := make(map[string]bool)
+
+	var install func(projectID, versionID string, depth int, isDep bool) error
+	install = func(projectID, versionID string, depth int, isDep bool) error {
+		if depth > modrinthMaxDepDepth {
+			return errors.New("dependency tree too deep")
+		}
+
+		v, err := mc.resolveVersion(projectID, versionID, mcVer, loader)
+		if err != nil {
+			return err
+		}
+		if visited[v.ProjectID] {
+			return nil
+		}
+		visited[v.ProjectID] = true
+
+		// 相依已經裝過就跳過，主要安裝則允許換版本
+		if existing, err := model.GetModSourceByProject(sid, v.ProjectID); err == nil {
+			if isDep || existing.VersionID == v.ID {
+				return nil
+			}
+		}
+
+		for _, dep := range v.Dependencies {
+			if dep.DependencyType != "required" || (dep.ProjectID == "" && dep.VersionID == "") {
+				continue
+			}
+			if err := install(dep.ProjectID, dep.VersionID, depth+1, true); err != nil {
+				return fmt.Errorf("dependency %s: %w", dep.ProjectID, err)
+			}
+		}
+
+		file := primaryFile(v)
+		if file == nil {
+			return fmt.Errorf("%w: version %s has no files", ErrNoCompatibleVersion, v.ID)
+		}
+		if err := ValidateModFileName(file.Filename); err != nil {
+			return err
+		}
+
+		if err := mc.downloadModFile(workDir, file); err != nil {
+			return err
+		}
+
+		// 同一個 project 的舊檔案 (升級) 要移掉
+		if existing, err := model.GetModSourceByProject(sid, v.ProjectID); err == nil && existing.FileName != file.Filename {
+			_ = RemoveMod(workDir, existing.FileName)
+			_ = RemoveMod(workDir, existing.FileName+disabledModExt)
+			_ = model.RemoveModSource(sid, existing.FileName)
+		}
+
+		if err := model.SaveModSource(&model.ServerModSource{
+			ServerID:      sid,
+			FileName:      file.Filename,
+			Source:        "modrinth",
+			ProjectID:     v.ProjectID,
+			VersionID:     v.ID,
+			VersionNumber: v.VersionNumber,
+			SHA512:        file.Hashes.SHA512,
+		}); err != nil {
+			return err
+		}
+
+		installed = append(installed, InstalledMod{
+			FileName:      file.Filename,
+			ProjectID:     v.ProjectID,
+			VersionID:     v.ID,
+			VersionNumber: v.VersionNumber,
+			Dependency:    isDep,
+		})
+		return nil
+	}
+
+	if err := install(projectID, versionID, 0, false); err != nil {
+		return installed, err
+	}
+	return installed, nil
+}
+
+// MirrorProject 填充 mirror 用：以安裝時相同的請求解析相容版本與 required 相依，並下載檔案讓 mirror 記錄
+func (mc *ModrinthClient) MirrorProject(projectID, mcVer, loader string) error {
+	visited := make(map[string]bool)
+	var walk func(projectID, versionID string, depth int) error
+	walk = func(projectID, versionID string, depth int) error {
+		if depth > modrinthMaxDepDepth {
+			return errors.New("dependency tree too deep")
+		}
+		v, err := mc.resolveVersion(projectID, versionID, mcVer, loader)
+		if err != nil {
+			return err
+		}
+		if visited[v.ProjectID] {
+			return nil
+		}
+		visited[v.ProjectID] = true
+
+		for _, dep := range v.Dependencies {
+			if dep.DependencyType != "required" || (dep.ProjectID == "" && dep.VersionID == "") {
+				continue
+			}
+			if err := walk(dep.ProjectID, dep.VersionID, depth+1); err != nil {
+				return fmt.Errorf("dependency %s: %w", dep.ProjectID, err)
+			}
+		}
+		file := primaryFile(v)
+		if file == nil {
+			return fmt.Errorf("%w: version %s has no files", ErrNoCompatibleVersion, v.ID)
+		}
+		return recordURL(file.URL)
+	}
+	return walk(projectID, "", 0)
+}
+
+func primaryFile(v *ModrinthVersion) *ModrinthFile {
+	if len(v.Files) == 0 {
+		return nil
+	}
+	for i := range v.Files {
+		if v.Files[i].Primary {
+			return &v.Files[i]
+		}
+	}
+	return &v.Files[0]
+}
+
+// downloadModFile 下載到暫存檔，比對 SHA-512 後才搬進 mods 資料夾
+func (mc *ModrinthClient) downloadModFile(workDir string, file *ModrinthFile) error {
+	if file.Hashes.SHA512 == "" {
+		return errors.New("modrinth file has no sha512 hash")
+	}
+	dir := modsDir(workDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(dir, file.Filename)
+	tmp := dst + ".tmp"
+
+	req, err := http.NewRequest(http.MethodGet, file.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "carsupper665/mc-server-backend/"+common.Version)
+
+	client := common.NewHTTPClient(10 * time.Minute)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download %s error: %w", file.Filename, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status downloading %s: %s", file.Filename, resp.Status)
+	}
+
+	out, err := createInWorkDir(workDir, tmp, 0644)
+	if err != nil {
+		return err
+	}
+	hasher := sha512.New()
+	if _, err := io.Copy(io.MultiWriter(out, hasher), resp.Body); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s error: %w", file.Filename, err)
+	}
+	out.Close()
+
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if !strings.EqualFold(sum, file.Hashes.SHA512) {
+		os.Remove(tmp)
+		return fmt.Errorf("%w: %s", ErrHashMismatch, file.Filename)
+	}
+
+	return os.Rename(tmp, dst)
+}
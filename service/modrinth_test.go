@@ -0,0 +1,194 @@
+// service/modrinth_test.go
+
+package service
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeModrinth 模擬 Modrinth API：project 的版本清單、單一版本與檔案下載
+type fakeModrinth struct {
+	*httptest.Server
+	versions map[string][]ModrinthVersion // project ID -> 版本 (新的在前)
+	files    map[string][]byte            // 檔名 -> 內容
+	queries  []string
+}
+
+func newFakeModrinth(t *testing.T) *fakeModrinth {
+	t.Helper()
+	f := &fakeModrinth{versions: map[string][]ModrinthVersion{}, files: map[string][]byte{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		f.queries = append(f.queries, r.URL.RawQuery)
+		json.NewEncoder(w).Encode(ModrinthSearchResult{Hits: []ModrinthSearchHit{{ProjectID: "fabric-api", Title: "Fabric API"}}, TotalHits: 1})
+	})
+	mux.HandleFunc("/project/{id}/version", func(w http.ResponseWriter, r *http.Request) {
+		f.queries = append(f.queries, r.URL.RawQuery)
+		list, ok := f.versions[r.PathValue("id")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("/version/{id}", func(w http.ResponseWriter, r *http.Request) {
+		for _, list := range f.versions {
+			for _, v := range list {
+				if v.ID == r.PathValue("id") {
+					json.NewEncoder(w).Encode(v)
+					return
+				}
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/files/{name}", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := f.files[r.PathValue("name")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeModrinth) client() *ModrinthClient {
+	return &ModrinthClient{BaseURL: f.URL, http: f.Client()}
+}
+
+// addVersion 新增一個版本與它的檔案，hash 為空時使用檔案內容的 SHA-512
+func (f *fakeModrinth) addVersion(v ModrinthVersion, fileName string, content []byte, hash string) {
+	if hash == "" {
+		sum := sha512.Sum512(content)
+		hash = hex.EncodeToString(sum[:])
+	}
+	file := ModrinthFile{URL: f.URL + "/files/" + fileName, Filename: fileName, Primary: true, Size: int64(len(content))}
+	file.Hashes.SHA512 = hash
+	v.Files = []ModrinthFile{file}
+	f.versions[v.ProjectID] = append(f.versions[v.ProjectID], v)
+	f.files[fileName] = content
+}
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	common.SQLitePath = filepath.Join(t.TempDir(), "test.db")
+	if err := model.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if db, err := model.DB.DB(); err == nil {
+			db.Close()
+		}
+	})
+}
+
+func TestModrinthSearch(t *testing.T) {
+	f := newFakeModrinth(t)
+	result, err := f.client().Search("fabric api", "1.20.1", "fabric", 500, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].ProjectID != "fabric-api" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	want := `facets=%5B%5B%22project_type%3Amod%22%5D%2C%5B%22categories%3Afabric%22%5D%2C%5B%22versions%3A1.20.1%22%5D%2C%5B%22server_side%3Arequired%22%2C%22server_side%3Aoptional%22%5D%5D&limit=20&offset=0&query=fabric+api`
+	if f.queries[0] != want {
+		t.Fatalf("query = %s", f.queries[0])
+	}
+}
+
+func TestModrinthResolveVersion(t *testing.T) {
+	f := newFakeModrinth(t)
+	f.addVersion(ModrinthVersion{ID: "beta", ProjectID: "lithium", VersionType: "beta", GameVersions: []string{"1.20.1"}, Loaders: []string{"fabric"}}, "lithium-beta.jar", []byte("beta"), "")
+	f.addVersion(ModrinthVersion{ID: "rel", ProjectID: "lithium", VersionType: "release", GameVersions: []string{"1.20.1"}, Loaders: []string{"fabric"}}, "lithium.jar", []byte("rel"), "")
+	mc := f.client()
+
+	// 沒有指定版本時優先取 release
+	v, err := mc.resolveVersion("lithium", "", "1.20.1", "fabric")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.ID != "rel" {
+		t.Fatalf("got %s, want rel", v.ID)
+	}
+	if _, err := mc.resolveVersion("", "beta", "1.21", "fabric"); !errors.Is(err, ErrNoCompatibleVersion) {
+		t.Fatalf("got %v, want ErrNoCompatibleVersion", err)
+	}
+	if _, err := mc.resolveVersion("", "missing", "1.20.1", "fabric"); !errors.Is(err, ErrModrinthNotFound) {
+		t.Fatalf("got %v, want ErrModrinthNotFound", err)
+	}
+	if _, err := mc.resolveVersion("missing", "", "1.20.1", "fabric"); !errors.Is(err, ErrModrinthNotFound) {
+		t.Fatalf("got %v, want ErrModrinthNotFound", err)
+	}
+}
+
+func TestModrinthInstallWithDependencies(t *testing.T) {
+	setupTestDB(t)
+	f := newFakeModrinth(t)
+	compat := ModrinthVersion{GameVersions: []string{"1.20.1"}, Loaders: []string{"fabric"}, VersionType: "release"}
+
+	api := compat
+	api.ID, api.ProjectID, api.VersionNumber = "api-1", "fabric-api", "0.90.0"
+	f.addVersion(api, "fabric-api.jar", []byte("api"), "")
+
+	mod := compat
+	mod.ID, mod.ProjectID, mod.VersionNumber = "mod-1", "sodium", "0.5.0"
+	mod.Dependencies = []ModrinthDependency{
+		{ProjectID: "fabric-api", DependencyType: "required"},
+		{ProjectID: "modmenu", DependencyType: "optional"},
+	}
+	f.addVersion(mod, "sodium.jar", []byte("sodium"), "")
+
+	workDir := t.TempDir()
+	installed, err := f.client().Install("mcsfv-test", workDir, "sodium", "", "1.20.1", "fabric")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 2 || installed[0].ProjectID != "fabric-api" || !installed[0].Dependency || installed[1].ProjectID != "sodium" || installed[1].Dependency {
+		t.Fatalf("unexpected install result %+v", installed)
+	}
+	for name, want := range map[string]string{"fabric-api.jar": "api", "sodium.jar": "sodium"} {
+		data, err := os.ReadFile(filepath.Join(modsDir(workDir), name))
+		if err != nil || string(data) != want {
+			t.Fatalf("%s = %q, %v", name, data, err)
+		}
+	}
+	src, err := model.GetModSourceByProject("mcsfv-test", "sodium")
+	if err != nil || src.VersionID != "mod-1" {
+		t.Fatalf("mod source = %+v, %v", src, err)
+	}
+
+	// 相依已經裝過，再次安裝不會重新下載
+	again, err := f.client().Install("mcsfv-test", workDir, "sodium", "", "1.20.1", "fabric")
+	if err != nil || len(again) != 0 {
+		t.Fatalf("reinstall = %+v, %v", again, err)
+	}
+}
+
+func TestModrinthInstallHashMismatch(t *testing.T) {
+	setupTestDB(t)
+	f := newFakeModrinth(t)
+	f.addVersion(ModrinthVersion{ID: "bad-1", ProjectID: "bad", VersionType: "release", GameVersions: []string{"1.20.1"}, Loaders: []string{"fabric"}}, "bad.jar", []byte("tampered"), hex.EncodeToString(make([]byte, 64)))
+
+	workDir := t.TempDir()
+	if _, err := f.client().Install("mcsfv-test", workDir, "bad", "", "1.20.1", "fabric"); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("got %v, want ErrHashMismatch", err)
+	}
+	entries, _ := os.ReadDir(modsDir(workDir))
+	if len(entries) != 0 {
+		t.Fatalf("mods dir not empty: %v", entries)
+	}
+}
@@ -0,0 +1,477 @@
+// service/mods.go
+
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	modsDirName      = "mods"
+	disabledModExt   = ".disabled"
+	fabricModJSON    = "fabric.mod.json"
+	maxNestedJarSize = 64 << 20 // 巢狀 jar 超過 64MB 就不解析
+)
+
+var (
+	ErrInvalidModFile = errors.New("invalid mod file")
+	ErrModNotFound    = errors.New("mod not found")
+	ErrModExists      = errors.New("mod already exists")
+)
+
+// ModInfo 為 mods 資料夾內單一 jar 的資訊
+type ModInfo struct {
+	FileName     string            `json:"file_name"`
+	Enabled      bool              `json:"enabled"`
+	Size         int64             `json:"size"`
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Environment  string            `json:"environment"`
+	Depends      map[string]string `json:"depends"`
+	Provides     []string          `json:"provides,omitempty"`
+	ParseError   string            `json:"parse_error,omitempty"`
+	nestedModIDs map[string]string // jar-in-jar 的 mod id -> version
+}
+
+// fabricModMeta 對應 fabric.mod.json 會用到的欄位
+type fabricModMeta struct {
+	ID          string                     `json:"id"`
+	Name        string                     `json:"name"`
+	Version     string                     `json:"version"`
+	Environment string                     `json:"environment"`
+	Depends     map[string]json.RawMessage `json:"depends"`
+	Provides    []string                   `json:"provides"`
+	Jars        []struct {
+		File string `json:"file"`
+	} `json:"jars"`
+}
+
+// ModProblem 描述一個無法滿足的相依或版本範圍
+type ModProblem struct {
+	FileName    string `json:"file_name"`
+	ModID       string `json:"mod_id"`
+	Dependency  string `json:"dependency"`
+	Requirement string `json:"requirement"`
+	Reason      string `json:"reason"`
+}
+
+// ModCheckError 在啟動前檢查 mod 失敗時回傳，Problems 可直接回給前端
+type ModCheckError struct {
+	Problems []ModProblem
+}
+
+func (e *ModCheckError) Error() string {
+	msgs := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", p.FileName, p.Reason))
+	}
+	return "mod dependency check failed: " + strings.Join(msgs, "; ")
+}
+
+func modsDir(workDir string) string {
+	return filepath.Join(workDir, modsDirName)
+}
+
+// ListMods 列出 mods/*.jar 與 *.jar.disabled，並解析 fabric.mod.json
+func ListMods(workDir string) ([]ModInfo, error) {
+	list := make([]ModInfo, 0)
+
+	entries, err := os.ReadDir(modsDir(workDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return list, nil
+		}
+		return list, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		enabled := strings.HasSuffix(name, ".jar")
+		if !enabled && !strings.HasSuffix(name, ".jar"+disabledModExt) {
+			continue
+		}
+
+		info := ModInfo{FileName: name, Enabled: enabled}
+		if fi, err := entry.Info(); err == nil {
+			info.Size = fi.Size()
+		}
+		if err := readModMeta(filepath.Join(modsDir(workDir), name), &info); err != nil {
+			info.ParseError = err.Error()
+		}
+		list = append(list, info)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].FileName < list[j].FileName })
+	return list, nil
+}
+
+func readModMeta(path string, info *ModInfo) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("open jar: %w", err)
+	}
+	defer zr.Close()
+	return parseModZip(&zr.Reader, info, 0)
+}
+
+func parseModZip(zr *zip.Reader, info *ModInfo, depth int) error {
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	f, ok := files[fabricModJSON]
+	if !ok {
+		return errors.New(fabricModJSON + " not found")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+	meta := &fabricModMeta{}
+	// 有些 mod 的 json 會帶換行字元在字串內，先用寬鬆的方式處理
+	if err := json.Unmarshal(sanitizeModJSON(data), meta); err != nil {
+		return fmt.Errorf("parse %s: %w", fabricModJSON, err)
+	}
+
+	if depth == 0 {
+		info.ID = meta.ID
+		info.Name = meta.Name
+		info.Version = meta.Version
+		info.Environment = meta.Environment
+		if info.Environment == "" {
+			info.Environment = "*"
+		}
+		info.Provides = meta.Provides
+		info.Depends = make(map[string]string, len(meta.Depends))
+		for dep, raw := range meta.Depends {
+			info.Depends[dep] = decodeVersionRequirement(raw)
+		}
+		info.nestedModIDs = make(map[string]string)
+	} else if meta.ID != "" {
+		info.nestedModIDs[meta.ID] = meta.Version
+		for _, p := range meta.Provides {
+			info.nestedModIDs[p] = meta.Version
+		}
+	}
+
+	// jar-in-jar (例如 fabric-api 內含所有模組)
+	if depth >= 2 {
+		return nil
+	}
+	for _, j := range meta.Jars {
+		nf, ok := files[j.File]
+		if !ok || nf.UncompressedSize64 > maxNestedJarSize {
+			continue
+		}
+		rc, err := nf.Open()
+		if err != nil {
+			continue
+		}
+		buf, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			continue
+		}
+		nzr, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+		if err != nil {
+			continue
+		}
+		_ = parseModZip(nzr, info, depth+1)
+	}
+	return nil
+}
+
+// sanitizeModJSON 把字串內未跳脫的換行移掉 (fabric loader 本身是容忍的)
+func sanitizeModJSON(data []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	escaped := false
+	for _, b := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case b == '\\' && inString:
+			escaped = true
+		case b == '"':
+			inString = !inString
+		case inString && (b == '\n' || b == '\r' || b == '\t'):
+			out.WriteByte(' ')
+			continue
+		}
+		out.WriteByte(b)
+	}
+	return out.Bytes()
+}
+
+// decodeVersionRequirement depends 的值可能是字串或字串陣列 (陣列代表 OR)
+func decodeVersionRequirement(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var arr []string
+	if err := json.Unmarshal(raw, &arr); err == nil {
+		return strings.Join(arr, " || ")
+	}
+	return "*"
+}
+
+func matchRequirement(req, ver string) bool {
+	for _, alt := range strings.Split(req, "||") {
+		if MatchVersionPredicate(alt, ver) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateModFileName 避免路徑穿越，只接受 mods 資料夾內的 jar
+func ValidateModFileName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) {
+		return ErrInvalidModFile
+	}
+	if !strings.HasSuffix(name, ".jar") && !strings.HasSuffix(name, ".jar"+disabledModExt) {
+		return ErrInvalidModFile
+	}
+	return nil
+}
+
+// SaveModJar 將上傳的 jar 寫進 mods 資料夾，並確認是合法的 fabric mod
+func SaveModJar(workDir, fileName string, src io.Reader) (*ModInfo, error) {
+	if err := ValidateModFileName(fileName); err != nil || !strings.HasSuffix(fileName, ".jar") {
+		return nil, ErrInvalidModFile
+	}
+
+	dir := modsDir(workDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	dst := filepath.Join(dir, fileName)
+	if _, err := os.Stat(dst); err == nil {
+		return nil, ErrModExists
+	}
+	if _, err := os.Stat(dst + disabledModExt); err == nil {
+		return nil, ErrModExists
+	}
+
+	tmp := dst + ".tmp"
+	out, err := createInWorkDir(workDir, tmp, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(out)
+	if _, err := io.Copy(w, src); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+	out.Close()
+
+	info := ModInfo{FileName: fileName, Enabled: true}
+	if err := readModMeta(tmp, &info); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidModFile, err.Error())
+	}
+	if fi, err := os.Stat(tmp); err == nil {
+		info.Size = fi.Size()
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return &info, nil
+}
+
+// RemoveMod 刪除 mod (啟用或停用中的都可以)
+func RemoveMod(workDir, fileName string) error {
+	if err := ValidateModFileName(fileName); err != nil {
+		return err
+	}
+	path := filepath.Join(modsDir(workDir), fileName)
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrModNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// SetModEnabled 透過改名 .jar <-> .jar.disabled 啟用或停用 mod，回傳新的檔名
+func SetModEnabled(workDir, fileName string, enabled bool) (string, error) {
+	if err := ValidateModFileName(fileName); err != nil {
+		return "", err
+	}
+	base := strings.TrimSuffix(fileName, disabledModExt)
+	from := filepath.Join(modsDir(workDir), fileName)
+
+	var to string
+	if enabled {
+		to = filepath.Join(modsDir(workDir), base)
+	} else {
+		to = filepath.Join(modsDir(workDir), base+disabledModExt)
+	}
+	if from == to {
+		if _, err := os.Stat(from); err != nil {
+			return "", ErrModNotFound
+		}
+		return fileName, nil
+	}
+	if err := os.Rename(from, to); err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrModNotFound
+		}
+		return "", err
+	}
+	return filepath.Base(to), nil
+}
+
+// CheckModDependencies 檢查已啟用 mod 的相依與 minecraft / fabricloader 版本範圍
+// loaderVer 為空時跳過 fabricloader 的檢查
+func CheckModDependencies(workDir, mcVer, loaderVer string) ([]ModProblem, error) {
+	mods, err := ListMods(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// 可用的 mod id -> version，包含 provides 與 jar-in-jar
+	available := map[string]string{
+		"minecraft": mcVer,
+		"java":      "*",
+	}
+	if loaderVer != "" {
+		available["fabricloader"] = loaderVer
+	}
+	for _, m := range mods {
+		if !m.Enabled || m.ID == "" || m.Environment == "client" {
+			continue
+		}
+		available[m.ID] = m.Version
+		for _, p := range m.Provides {
+			available[p] = m.Version
+		}
+		for id, v := range m.nestedModIDs {
+			if _, exists := available[id]; !exists {
+				available[id] = v
+			}
+		}
+	}
+
+	problems := make([]ModProblem, 0)
+	for _, m := range mods {
+		if !m.Enabled {
+			continue
+		}
+		if m.ParseError != "" {
+			problems = append(problems, ModProblem{
+				FileName: m.FileName,
+				Reason:   "cannot read mod metadata: " + m.ParseError,
+			})
+			continue
+		}
+		if m.Environment == "client" {
+			continue // loader 在 server 端不會載入 client-only mod
+		}
+
+		for dep, req := range m.Depends {
+			if dep == "java" || (dep == "fabricloader" && loaderVer == "") {
+				continue
+			}
+			have, ok := available[dep]
+			if !ok {
+				problems = append(problems, ModProblem{
+					FileName:    m.FileName,
+					ModID:       m.ID,
+					Dependency:  dep,
+					Requirement: req,
+					Reason:      fmt.Sprintf("requires %s %s, which is not installed", dep, req),
+				})
+				continue
+			}
+			if have == "*" || have == "${version}" {
+				continue
+			}
+			if !matchRequirement(req, have) {
+				problems = append(problems, ModProblem{
+					FileName:    m.FileName,
+					ModID:       m.ID,
+					Dependency:  dep,
+					Requirement: req,
+					Reason:      fmt.Sprintf("requires %s %s, found %s", dep, req, have),
+				})
+			}
+		}
+	}
+	return problems, nil
+}
+
+// ReadFabricLoaderVersion 從 fabric server launcher jar 的 install.properties 取得 loader 版本
+func ReadFabricLoaderVersion(workDir string) string {
+	zr, err := zip.OpenReader(filepath.Join(workDir, "server.jar"))
+	if err != nil {
+		return ""
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.Name != "install.properties" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return ""
+		}
+		defer rc.Close()
+		scanner := bufio.NewScanner(rc)
+		for scanner.Scan() {
+			parts := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+			if len(parts) == 2 && strings.TrimSpace(parts[0]) == "fabric-loader-version" {
+				return strings.TrimSpace(parts[1])
+			}
+		}
+	}
+	return ""
+}
+
+// ValidateServerMods 在啟動 fabric server 前呼叫，有問題就回傳 *ModCheckError
+func ValidateServerMods(sid, workDir string) error {
+	info := ServerVersionByID(sid)
+	if info.ServerType != "Fabric" {
+		return nil
+	}
+	problems, err := CheckModDependencies(workDir, info.MCVersion, ReadFabricLoaderVersion(workDir))
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &ModCheckError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// service/mods_test.go
+
+package service
+
+import "testing"
+
+func TestMatchRequirement(t *testing.T) {
+	tests := []struct {
+		req  string
+		ver  string
+		want bool
+	}{
+		{"*", "1.20.1", true},
+		{"", "1.20.1", true},
+		{">=1.20", "1.20.1", true},
+		{">=1.20", "1.19.4", false},
+		{">=1.20 <1.21", "1.20.6", true},
+		{">=1.20 <1.21", "1.21", false},
+		{"~1.20.1", "1.20.4", true},
+		{"~1.20.1", "1.21", false},
+		{"^0.15.0", "0.15.11+build.1", true},
+		{"^0.15.0", "1.0.0", false},
+		{"1.20.x", "1.20.4", true},
+		{"1.20.x", "1.21.0", false},
+		{"=1.20.1", "1.20.1", true},
+		{"1.20", "1.20.0", true},
+		{">=1.20-pre1", "1.20", true},
+		{"<1.20", "1.20-pre1", true},
+		// 陣列形式的相依會被合併成 ||
+		{"1.19.x || 1.20.x", "1.20.2", true},
+		{"1.19.x || 1.20.x", "1.21", false},
+		{"<1.18 || >=1.20", "1.18.2", false},
+	}
+	for _, tt := range tests {
+		if got := matchRequirement(tt.req, tt.ver); got != tt.want {
+			t.Errorf("matchRequirement(%q, %q) = %v, want %v", tt.req, tt.ver, got, tt.want)
+		}
+	}
+}
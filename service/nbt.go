@@ -0,0 +1,178 @@
+// service/nbt.go
+
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 只實作讀取 level.dat 需要的部分，不是完整的 NBT library
+
+const (
+	nbtEnd byte = iota
+	nbtByte
+	nbtShort
+	nbtInt
+	nbtLong
+	nbtFloat
+	nbtDouble
+	nbtByteArray
+	nbtString
+	nbtList
+	nbtCompound
+	nbtIntArray
+	nbtLongArray
+)
+
+var ErrNBTTagNotFound = errors.New("nbt tag not found")
+
+type nbtReader struct {
+	r *bufio.Reader
+}
+
+func (n *nbtReader) read(v any) error {
+	return binary.Read(n.r, binary.BigEndian, v)
+}
+
+func (n *nbtReader) readString() (string, error) {
+	var l uint16
+	if err := n.read(&l); err != nil {
+		return "", err
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(n.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (n *nbtReader) discard(size int64) error {
+	_, err := io.CopyN(io.Discard, n.r, size)
+	return err
+}
+
+// skip 跳過一個指定型別的 payload
+func (n *nbtReader) skip(tag byte) error {
+	switch tag {
+	case nbtByte:
+		return n.discard(1)
+	case nbtShort:
+		return n.discard(2)
+	case nbtInt, nbtFloat:
+		return n.discard(4)
+	case nbtLong, nbtDouble:
+		return n.discard(8)
+	case nbtString:
+		_, err := n.readString()
+		return err
+	case nbtByteArray, nbtIntArray, nbtLongArray:
+		var l int32
+		if err := n.read(&l); err != nil {
+			return err
+		}
+		size := map[byte]int64{nbtByteArray: 1, nbtIntArray: 4, nbtLongArray: 8}[tag]
+		return n.discard(int64(l) * size)
+	case nbtList:
+		var elem byte
+		var l int32
+		if err := n.read(&elem); err != nil {
+			return err
+		}
+		if err := n.read(&l); err != nil {
+			return err
+		}
+		for i := int32(0); i < l; i++ {
+			if err := n.skip(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nbtCompound:
+		for {
+			var t byte
+			if err := n.read(&t); err != nil {
+				return err
+			}
+			if t == nbtEnd {
+				return nil
+			}
+			if _, err := n.readString(); err != nil {
+				return err
+			}
+			if err := n.skip(t); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("unknown nbt tag type %d", tag)
+}
+
+// findInt 在目前的 compound 內沿著 path 找 int 值
+func (n *nbtReader) findInt(path []string) (int32, error) {
+	for {
+		var t byte
+		if err := n.read(&t); err != nil {
+			return 0, err
+		}
+		if t == nbtEnd {
+			return 0, ErrNBTTagNotFound
+		}
+		name, err := n.readString()
+		if err != nil {
+			return 0, err
+		}
+		if name != path[0] {
+			if err := n.skip(t); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if len(path) == 1 {
+			if t != nbtInt {
+				return 0, fmt.Errorf("nbt tag %s is not an int", name)
+			}
+			var v int32
+			err := n.read(&v)
+			return v, err
+		}
+		if t != nbtCompound {
+			return 0, ErrNBTTagNotFound
+		}
+		return n.findInt(path[1:])
+	}
+}
+
+// ReadLevelDataVersion 讀取 level.dat 內 Data.DataVersion
+func ReadLevelDataVersion(levelDat string) (int, error) {
+	f, err := os.Open(levelDat)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, fmt.Errorf("level.dat is not gzip: %w", err)
+	}
+	defer gz.Close()
+
+	n := &nbtReader{r: bufio.NewReader(gz)}
+	var root byte
+	if err := n.read(&root); err != nil {
+		return 0, err
+	}
+	if root != nbtCompound {
+		return 0, errors.New("level.dat root is not a compound")
+	}
+	if _, err := n.readString(); err != nil {
+		return 0, err
+	}
+	v, err := n.findInt([]string{"Data", "DataVersion"})
+	return int(v), err
+}
@@ -0,0 +1,84 @@
+// service/pendingProperty.go
+
+package service
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+)
+
+var ErrPropertiesWhileRunning = errors.New("server.properties can only be replaced while the server is stopped")
+
+// applyPendingProperties 把執行中排隊的修改寫入 server.properties，server 必須已經停止
+func applyPendingProperties(sid, workDir string) error {
+	pending, err := model.TakePendingProperties(sid)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	values := make(map[string]string, len(pending))
+	var authorID uint
+	for _, p := range pending {
+		values[p.Key] = p.Value
+		authorID = p.AuthorID // 依 updated_at 排序，以最後修改的人為作者
+	}
+
+	err = TrackConfigWrite(sid, workDir, "server.properties", authorID, "apply staged properties", func() error {
+		return UpdateProperties(workDir, values)
+	})
+	if err != nil {
+		// 寫入失敗時放回去，下次再套用
+		if stageErr := model.StagePendingProperties(sid, authorID, values); stageErr != nil {
+			common.SysError("failed to restore staged properties: " + stageErr.Error())
+		}
+		return err
+	}
+	common.SysLog("applied staged properties for " + sid)
+	return nil
+}
+
+// PatchProperties server 停止時直接寫入；執行中時先排隊，difficulty / white-list 另外透過指令立即套用
+func (s *ServerService) PatchProperties(rec *model.UserMinecraftServer, authorID uint, changes map[string]any) (*PropertyPatchResult, error) {
+	mcVersion := ServerVersionOf(rec).MCVersion
+	values, err := ValidatePropertyChanges(mcVersion, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &PropertyPatchResult{Applied: map[string]string{}}
+	if !s.mgr.IsRunning(rec.ServerID) {
+		err := TrackConfigWrite(rec.ServerID, rec.SystemPath, "server.properties", authorID, "patch properties", func() error {
+			return UpdateProperties(rec.SystemPath, values)
+		})
+		if err != nil {
+			return nil, err
+		}
+		res.Applied = values
+		return res, nil
+	}
+
+	// server 會在執行中自己改寫 server.properties，所以檔案一律等停止後再寫
+	if err := model.StagePendingProperties(rec.ServerID, authorID, values); err != nil {
+		return nil, err
+	}
+	res.Pending = values
+	for k, v := range values {
+		if cmd := livePropertyCommand(k, v); cmd != "" {
+			if err := s.mgr.SendCommand(rec.ServerID, cmd); err == nil {
+				res.Live = append(res.Live, k)
+				continue
+			}
+		}
+		res.RestartRequired = true
+	}
+	return res, nil
+}
+
+func (s *ServerService) PendingProperties(sid string) ([]model.PendingProperty, error) {
+	return model.ListPendingProperties(sid)
+}
+
+// DiscardPendingProperties keys 為空時捨棄全部；已透過指令套用的設定不會被還原
+func (s *ServerService) DiscardPendingProperties(sid string, keys []string) (int64, error) {
+	return model.DeletePendingProperties(sid, keys...)
+}
@@ -19,6 +19,8 @@ type Snapshot struct {
 	At        time.Time `json:"at"`
 	Running   bool      `json:"running"`
 	Err       string    `json:"err,omitempty"`
+
+	Cgroup *CgroupStats `json:"cgroup,omitempty"` // 沒有 cgroup 時為 nil
 }
 
 type Monitor struct {
@@ -26,6 +28,7 @@ type Monitor struct {
 	last Snapshot
 
 	p      *process.Process
+	cgroup *serverCgroup
 	ctx    context.Context
 	cancel context.CancelFunc
 }
@@ -84,6 +87,11 @@ func (m *Monitor) sampleOnce() {
 		s.Err = "process not running"
 	}
 
+	// process 結束後仍讀 cgroup，才看得到 OOM kill
+	if m.cgroup != nil {
+		s.Cgroup = m.cgroup.stats()
+	}
+
 	// 就算 not running，仍可能拿到一些資訊；但通常可直接回錯
 	if !running {
 		m.setLast(s)
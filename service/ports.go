@@ -0,0 +1,253 @@
+// service/ports.go
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoFreePort    = errors.New("no available ports")
+	ErrPortName      = errors.New("invalid port name")
+	ErrPortProtocol  = errors.New("protocol must be tcp or udp")
+	ErrPortReserved  = errors.New("the game port cannot be removed")
+	ErrPortNotFound  = errors.New("port not found")
+	ErrPortDuplicate = errors.New("port name already exists")
+)
+
+var portNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// portProperties 這些 port 會寫進 server.properties
+var portProperties = map[string]string{
+	model.PortGame:  "server-port",
+	model.PortRcon:  "rcon.port",
+	model.PortQuery: "query.port",
+}
+
+type PortRequest struct {
+	Name     string
+	Protocol string
+}
+
+// parsePortRequests 解析 SERVER_COMPANION_PORTS，例如 "rcon,query,voice:udp"
+func parsePortRequests(spec string) []PortRequest {
+	var reqs []PortRequest
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(strings.ToLower(item))
+		if item == "" {
+			continue
+		}
+		name, protocol, _ := strings.Cut(item, ":")
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		if !portNamePattern.MatchString(name) || (protocol != "tcp" && protocol != "udp") {
+			common.SysError("invalid SERVER_COMPANION_PORTS entry: " + item)
+			continue
+		}
+		reqs = append(reqs, PortRequest{Name: name, Protocol: protocol})
+	}
+	return reqs
+}
+
+// PortAllocator 從設定的範圍分配 port，分配結果存在 DB，同一個 server 每次啟動使用相同的 port
+type PortAllocator struct {
+	start      int
+	end        int
+	companions []PortRequest
+	mu         sync.Mutex
+}
+
+func NewPortAllocator(start, end int, companions string) *PortAllocator {
+	return &PortAllocator{start: start, end: end, companions: parsePortRequests(companions)}
+}
+
+func (a *PortAllocator) inRange(port int) bool {
+	return port >= a.start && port <= a.end
+}
+
+func probePort(port int, protocol string) bool {
+	if protocol == "udp" {
+		return common.CheckUDPPortAvailable(port)
+	}
+	return common.CheckPortAvailable(port)
+}
+
+// pick 找一個沒有分配給其他 server、而且主機上沒有其他程式使用的 port
+func (a *PortAllocator) pick(used map[int]string, protocol string) (int, error) {
+	for port := a.start; port <= a.end; port++ {
+		if _, taken := used[port]; taken {
+			continue
+		}
+		if !probePort(port, protocol) {
+			continue
+		}
+		return port, nil
+	}
+	return 0, fmt.Errorf("%w in range %d-%d", ErrNoFreePort, a.start, a.end)
+}
+
+// Ensure 啟動前呼叫 (server 必須是停止的)：沿用已分配的 port，不在範圍內或被其他程式佔用時才重新分配
+func (a *PortAllocator) Ensure(sid string) ([]model.ServerPort, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	existing, err := model.ListServerPorts(sid)
+	if err != nil {
+		return nil, err
+	}
+	used, err := model.UsedPorts()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]model.ServerPort, len(existing))
+	for _, p := range existing {
+		byName[p.Name] = p
+	}
+	reqs := append([]PortRequest{{Name: model.PortGame, Protocol: "tcp"}}, a.companions...)
+	for _, p := range existing {
+		reqs = append(reqs, PortRequest{Name: p.Name, Protocol: p.Protocol})
+	}
+
+	list := make([]model.ServerPort, 0, len(reqs))
+	seen := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		if seen[req.Name] {
+			continue
+		}
+		seen[req.Name] = true
+
+		p, ok := byName[req.Name]
+		if ok && a.inRange(p.Port) && probePort(p.Port, p.Protocol) {
+			list = append(list, p)
+			continue
+		}
+		if ok {
+			common.SysLog(fmt.Sprintf("server %s: port %s (%d) unavailable, reassigning", sid, p.Name, p.Port))
+			delete(used, p.Port)
+		} else {
+			p = model.ServerPort{ServerID: sid, Name: req.Name, Protocol: req.Protocol}
+		}
+		port, err := a.pick(used, p.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		p.Port = port
+		if err := model.SaveServerPort(&p); err != nil {
+			return nil, err
+		}
+		used[port] = sid
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Port < list[j].Port })
+	return list, nil
+}
+
+// Add 額外分配一個 plugin 使用的 port
+func (a *PortAllocator) Add(sid, name, protocol string) (*model.ServerPort, error) {
+	name = strings.ToLower(name)
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if !portNamePattern.MatchString(name) {
+		return nil, ErrPortName
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return nil, ErrPortProtocol
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	existing, err := model.ListServerPorts(sid)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range existing {
+		if p.Name == name {
+			return nil, ErrPortDuplicate
+		}
+	}
+	used, err := model.UsedPorts()
+	if err != nil {
+		return nil, err
+	}
+	port, err := a.pick(used, protocol)
+	if err != nil {
+		return nil, err
+	}
+	p := &model.ServerPort{ServerID: sid, Name: name, Port: port, Protocol: protocol}
+	if err := model.SaveServerPort(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (a *PortAllocator) Remove(sid, name string) error {
+	if name == model.PortGame {
+		return ErrPortReserved
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n, err := model.DeleteServerPort(sid, name)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPortNotFound
+	}
+	return nil
+}
+
+func gamePort(ports []model.ServerPort) int {
+	for _, p := range ports {
+		if p.Name == model.PortGame {
+			return p.Port
+		}
+	}
+	return 0
+}
+
+// portEnv 傳給 server process 的環境變數，例如 SERVER_PORT_VOICE=30003，讓 plugin 設定檔可以引用
+func portEnv(ports []model.ServerPort) []string {
+	env := make([]string, 0, len(ports))
+	for _, p := range ports {
+		name := strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_"))
+		env = append(env, "SERVER_PORT_"+name+"="+strconv.Itoa(p.Port))
+	}
+	return env
+}
+
+// applyPortProperties 把 game / rcon / query port 寫進 server.properties，值沒變時不寫入
+func applyPortProperties(sid, workDir string, ports []model.ServerPort) error {
+	current, err := ReadProperties(workDir)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string)
+	for _, p := range ports {
+		key, ok := portProperties[p.Name]
+		if !ok {
+			continue
+		}
+		if v := strconv.Itoa(p.Port); current[key] != v {
+			values[key] = v
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return TrackConfigWrite(sid, workDir, "server.properties", 0, "assign ports", func() error {
+		return UpdateProperties(workDir, values)
+	})
+}
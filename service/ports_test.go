@@ -0,0 +1,27 @@
+// service/ports_test.go
+
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortRequests(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []PortRequest
+	}{
+		{"", nil},
+		{"rcon", []PortRequest{{"rcon", "tcp"}}},
+		{"rcon, query:udp ,voice:UDP", []PortRequest{{"rcon", "tcp"}, {"query", "udp"}, {"voice", "udp"}}},
+		{"rcon,,query", []PortRequest{{"rcon", "tcp"}, {"query", "tcp"}}},
+		// 格式錯誤的項目略過，其他照常
+		{"rcon:sctp,bad_name,-dash,voice:udp", []PortRequest{{"voice", "udp"}}},
+	}
+	for _, tt := range tests {
+		if got := parsePortRequests(tt.spec); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePortRequests(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
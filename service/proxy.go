@@ -0,0 +1,213 @@
+// service/proxy.go
+
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrHostname      = errors.New("invalid hostname")
+	ErrHostnameTaken = errors.New("hostname already in use")
+	ErrHostNotFound  = errors.New("hostname not found")
+	ErrServerOffline = errors.New("server is offline")
+)
+
+var hostLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// hostProxy 在單一 port 上讀 handshake 的 server address，轉送到對應 server 的 game port
+type hostProxy struct {
+	mu     sync.RWMutex
+	svc    *ServerService
+	routes map[string]string // hostname -> server ID
+}
+
+var proxy = &hostProxy{routes: make(map[string]string)}
+
+// normalizeHostname 設定 PROXY_DOMAIN 時可以只給子網域名稱，例如 "myworld"
+func normalizeHostname(name string) (string, error) {
+	host := strings.ToLower(strings.Trim(strings.TrimSpace(name), "."))
+	if host == "" || len(host) > 253 {
+		return "", ErrHostname
+	}
+	if domain := common.ProxyDomain; domain != "" {
+		if !strings.Contains(host, ".") {
+			host += "." + domain
+		}
+		if !strings.HasSuffix(host, "."+domain) {
+			return "", fmt.Errorf("%w: must be under %s", ErrHostname, domain)
+		}
+	}
+	for _, label := range strings.Split(host, ".") {
+		if !hostLabelPattern.MatchString(label) {
+			return "", ErrHostname
+		}
+	}
+	return host, nil
+}
+
+func (s *ServerService) StartProxy(addr string) error {
+	routes, err := model.AllServerHosts()
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	proxy.mu.Lock()
+	proxy.svc = s
+	proxy.routes = routes
+	proxy.mu.Unlock()
+
+	common.SysLog("minecraft proxy listening on " + addr)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				common.SysError("proxy accept error: " + err.Error())
+				time.Sleep(time.Second)
+				continue
+			}
+			go proxy.handle(conn)
+		}
+	}()
+	return nil
+}
+
+func (p *hostProxy) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	h, err := readHandshake(r)
+	if err != nil {
+		return
+	}
+
+	p.mu.RLock()
+	sid, ok := p.routes[h.Hostname()]
+	p.mu.RUnlock()
+	if !ok {
+		p.reject(conn, r, h, "Unknown server address")
+		return
+	}
+	addr, err := p.backendAddr(sid)
+	if err != nil {
+		p.reject(conn, r, h, "This server is offline")
+		return
+	}
+	backend, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		// 剛啟動還沒開始 listen
+		p.reject(conn, r, h, "This server is starting, please try again in a moment")
+		return
+	}
+	defer backend.Close()
+
+	// 原封不動轉送 handshake (含 Forge 的標記)，之後的封包直接對接
+	if err := writePacket(backend, 0x00, encodeHandshake(*h)); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	go func() {
+		io.Copy(backend, r)
+		backend.Close()
+	}()
+	io.Copy(conn, backend)
+}
+
+// backendAddr 執行中或休眠中 (由休眠的 listener 回應並叫醒) 的 server 才轉送
+func (p *hostProxy) backendAddr(sid string) (string, error) {
+	p.mu.RLock()
+	svc := p.svc
+	p.mu.RUnlock()
+	if !svc.mgr.IsRunning(sid) && !idle.isSleeping(sid) {
+		return "", ErrServerOffline
+	}
+	ports, err := model.ListServerPorts(sid)
+	if err != nil {
+		return "", err
+	}
+	port := gamePort(ports)
+	if port == 0 {
+		return "", ErrServerOffline
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), nil
+}
+
+// reject 清單上顯示原因，登入時以斷線訊息告知
+func (p *hostProxy) reject(conn net.Conn, r *bufio.Reader, h *Handshake, message string) {
+	switch h.NextState {
+	case mcStateStatus:
+		serveStatus(conn, r, h, StatusResponse{
+			Version:     StatusVersion{Name: "Offline"},
+			Description: chatJSON(message),
+		})
+	case mcStateLogin:
+		sendLoginDisconnect(conn, message)
+	}
+}
+
+func (s *ServerService) ListHostnames(sid string) ([]model.ServerHost, error) {
+	return model.ListServerHosts(sid)
+}
+
+func (s *ServerService) AddHostname(sid, name string) (*model.ServerHost, error) {
+	host, err := normalizeHostname(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := model.GetServerHost(host); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, ErrHostnameTaken
+	}
+	h := &model.ServerHost{Host: host, ServerID: sid}
+	if err := model.CreateServerHost(h); err != nil {
+		return nil, err
+	}
+	proxy.mu.Lock()
+	proxy.routes[host] = sid
+	proxy.mu.Unlock()
+	return h, nil
+}
+
+func (s *ServerService) RemoveHostname(sid, name string) error {
+	host := strings.ToLower(strings.Trim(strings.TrimSpace(name), "."))
+	n, err := model.DeleteServerHost(sid, host)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrHostNotFound
+	}
+	proxy.mu.Lock()
+	delete(proxy.routes, host)
+	proxy.mu.Unlock()
+	return nil
+}
+
+// ForgetProxyHosts server 被刪除後移除它的路由
+func ForgetProxyHosts(sid string) {
+	proxy.mu.Lock()
+	for host, id := range proxy.routes {
+		if id == sid {
+			delete(proxy.routes, host)
+		}
+	}
+	proxy.mu.Unlock()
+}
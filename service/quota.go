@@ -0,0 +1,200 @@
+// service/quota.go
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/model"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+const (
+	QuotaServers  = "servers"
+	QuotaRunning  = "running"
+	QuotaMemory   = "memory_mb"
+	QuotaDisk     = "disk_mb"
+	QuotaBackups  = "backups"
+	QuotaBackupMB = "backup_mb"
+)
+
+// QuotaError 哪一項超過上限，used 為目前用量，requested 為這次要增加的量
+type QuotaError struct {
+	Resource  string `json:"resource"`
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Requested int64  `json:"requested"`
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s (used %d + %d, limit %d)", e.Resource, e.Used, e.Requested, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuotaExceeded }
+
+// checkQuota limit 為 0 代表不限制
+func checkQuota(resource string, limit, used, requested int64) error {
+	if limit > 0 && used+requested > limit {
+		return &QuotaError{Resource: resource, Limit: limit, Used: used, Requested: requested}
+	}
+	return nil
+}
+
+// QuotaUsage 使用者 (以 owner 計算) 的用量與方案
+type QuotaUsage struct {
+	Plan     *model.QuotaPlan `json:"plan"`
+	Servers  int64            `json:"servers"`
+	Running  int64            `json:"running"`
+	MemoryMB int64            `json:"memory_mb"`
+	DiskMB   int64            `json:"disk_mb"`
+	Backups  int64            `json:"backups"`
+	BackupMB int64            `json:"backup_mb"`
+}
+
+// parseMemoryMB 解析 -Xmx 格式 (例如 2G、512M)
+func parseMemoryMB(v string) int64 {
+	v = strings.TrimSpace(strings.ToUpper(v))
+	if v == "" {
+		return 0
+	}
+	unit := v[len(v)-1]
+	num := v
+	if unit < '0' || unit > '9' {
+		num = v[:len(v)-1]
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return 0
+	}
+	switch unit {
+	case 'G':
+		return n * 1024
+	case 'M':
+		return n
+	case 'K':
+		return n / 1024
+	default:
+		return n / (1024 * 1024)
+	}
+}
+
+func toMB(bytes int64) int64 {
+	return (bytes + 1<<20 - 1) >> 20
+}
+
+// backupCount 一個 server 的備份數量
+func backupCount(sid, workDir string) int64 {
+	entries, err := os.ReadDir(serverBackupDir(sid, workDir))
+	if err != nil {
+		return 0
+	}
+	var count int64
+	for _, e := range entries {
+		if e.IsDir() {
+			count++
+		}
+	}
+	return count
+}
+
+// diskUsage owner 所有 server 的磁碟與備份用量，大小使用磁碟掃描的結果
+func diskUsage(servers []model.UserMinecraftServer) (disk, backups, backupSize int64) {
+	for _, srv := range servers {
+		if u, err := cachedDiskUsage(srv.ServerID, srv.SystemPath); err == nil {
+			disk += u.Total
+			backupSize += u.Backups
+		}
+		backups += backupCount(srv.ServerID, srv.SystemPath)
+	}
+	return disk, backups, backupSize
+}
+
+// ownerQuota 取得方案與擁有的 server
+func ownerQuota(ownerID uint) (*model.QuotaPlan, []model.UserMinecraftServer, error) {
+	plan, err := model.ResolveQuotaPlan(ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	servers, err := model.GetServersByOwner(ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan, servers, nil
+}
+
+// CheckCreateQuota 建立 (含複製、從 template 建立) server 前檢查數量與磁碟
+func CheckCreateQuota(ownerID uint) error {
+	plan, servers, err := ownerQuota(ownerID)
+	if err != nil {
+		return err
+	}
+	if err := checkQuota(QuotaServers, int64(plan.MaxServers), int64(len(servers)), 1); err != nil {
+		return err
+	}
+	if plan.MaxDiskMB > 0 {
+		// 建立前不知道新 server 的大小，只檢查目前是否已經用完
+		disk, _, _ := diskUsage(servers)
+		if used := toMB(disk); used >= plan.MaxDiskMB {
+			return &QuotaError{Resource: QuotaDisk, Limit: plan.MaxDiskMB, Used: used}
+		}
+	}
+	return nil
+}
+
+// checkStartQuota 啟動前檢查同時執行數量與記憶體總和
+func (s *ServerService) checkStartQuota(ownerID uint, maxMem string) error {
+	plan, err := model.ResolveQuotaPlan(ownerID)
+	if err != nil {
+		return err
+	}
+	running, memMB := s.mgr.ownerUsage(strconv.FormatUint(uint64(ownerID), 10))
+	if err := checkQuota(QuotaRunning, int64(plan.MaxRunning), int64(running), 1); err != nil {
+		return err
+	}
+	return checkQuota(QuotaMemory, plan.MaxMemoryMB, memMB, parseMemoryMB(maxMem))
+}
+
+// checkBackupQuota 以 world 目前的大小估計這次備份會增加的用量
+func checkBackupQuota(rec *model.UserMinecraftServer) error {
+	plan, servers, err := ownerQuota(rec.OwnerID)
+	if err != nil {
+		return err
+	}
+	if plan.MaxBackups == 0 && plan.MaxBackupMB == 0 && plan.MaxDiskMB == 0 {
+		return nil
+	}
+	disk, backups, backupSize := diskUsage(servers)
+	var world int64
+	if u, err := cachedDiskUsage(rec.ServerID, rec.SystemPath); err == nil {
+		world = toMB(u.World)
+	}
+	if err := checkQuota(QuotaBackups, int64(plan.MaxBackups), backups, 1); err != nil {
+		return err
+	}
+	if err := checkQuota(QuotaBackupMB, plan.MaxBackupMB, toMB(backupSize), world); err != nil {
+		return err
+	}
+	return checkQuota(QuotaDisk, plan.MaxDiskMB, toMB(disk), world)
+}
+
+func (s *ServerService) QuotaUsage(ownerID uint) (*QuotaUsage, error) {
+	plan, servers, err := ownerQuota(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	running, memMB := s.mgr.ownerUsage(strconv.FormatUint(uint64(ownerID), 10))
+	disk, backups, backupSize := diskUsage(servers)
+	return &QuotaUsage{
+		Plan:     plan,
+		Servers:  int64(len(servers)),
+		Running:  int64(running),
+		MemoryMB: memMB,
+		DiskMB:   toMB(disk),
+		Backups:  backups,
+		BackupMB: toMB(backupSize),
+	}, nil
+}
@@ -0,0 +1,28 @@
+// service/quota_test.go
+
+package service
+
+import "testing"
+
+func TestParseMemoryMB(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"2G", 2048},
+		{"2g", 2048},
+		{"512M", 512},
+		{" 1024m ", 1024},
+		{"1048576K", 1024},
+		{"1073741824", 1024},
+		{"", 0},
+		{"G", 0},
+		{"abc", 0},
+		{"1.5G", 0},
+	}
+	for _, tt := range tests {
+		if got := parseMemoryMB(tt.in); got != tt.want {
+			t.Errorf("parseMemoryMB(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
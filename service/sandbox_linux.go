@@ -0,0 +1,129 @@
+//go:build linux
+
+// service/sandbox_linux.go
+
+package service
+
+import (
+	"fmt"
+	"go-backend/common"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sandboxArg backend 以這個參數重新執行自己，在新的 mount namespace 裡準備好檔案系統後再 exec java
+const sandboxArg = "__server-sandbox"
+
+// sandboxCommand 把 cmd 改成透過 sandbox 啟動：
+// 新的 mount / PID / IPC / UTS namespace，servers 根目錄與 backend 的資料夾被 tmpfs 蓋掉，只掛回自己的 workDir
+func sandboxCommand(cmd *exec.Cmd, id *serverIdentity) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	workDir, err := filepath.Abs(cmd.Dir)
+	if err != nil {
+		return err
+	}
+	hide := []string{filepath.Dir(workDir)}
+	for _, dir := range []string{common.TemplatePath, common.BackupPath, common.ArtifactCachePath} {
+		if abs, err := filepath.Abs(dir); err == nil && abs != hide[0] {
+			hide = append(hide, abs)
+		}
+	}
+
+	args := []string{self, sandboxArg, workDir, strconv.Itoa(id.UID), strconv.Itoa(id.GID),
+		strings.Join(hide, string(filepath.ListSeparator)), cmd.Path}
+	cmd.Path = self
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		// sandbox 以 root 執行，掛載完成後才切換到 server 的帳號
+		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		Pdeathsig:  syscall.SIGKILL,
+	}
+	return nil
+}
+
+// RunSandboxIfRequested 由 sandboxCommand 啟動時不會返回，必須在 main 一開始呼叫
+func RunSandboxIfRequested() {
+	if len(os.Args) < 2 || os.Args[1] != sandboxArg {
+		return
+	}
+	if err := runSandbox(os.Args[2:]); err != nil {
+		// stderr 接在 server 的 console 上
+		fmt.Fprintln(os.Stderr, "server sandbox: "+err.Error())
+	}
+	os.Exit(1)
+}
+
+func runSandbox(args []string) error {
+	if len(args) < 5 {
+		return fmt.Errorf("missing arguments")
+	}
+	workDir, hide, javaPath, javaArgs := args[0], filepath.SplitList(args[3]), args[4], args[5:]
+	uid, err := strconv.Atoi(args[1])
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(args[2])
+	if err != nil {
+		return err
+	}
+
+	// 掛載只影響這個 namespace，不會傳回 host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	dir, err := os.OpenFile(workDir, os.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return err
+	}
+	for _, path := range hide {
+		// 包含 workDir 的上層資料夾不能蓋掉
+		if rel, err := filepath.Rel(path, workDir); err == nil && filepath.IsLocal(rel) && path != filepath.Dir(workDir) {
+			continue
+		}
+		if info, err := os.Lstat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := syscall.Mount("tmpfs", path, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "mode=0711,size=64k"); err != nil {
+			return fmt.Errorf("hide %s: %w", path, err)
+		}
+	}
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return err
+	}
+	if err := syscall.Mount(fmt.Sprintf("/proc/self/fd/%d", dir.Fd()), workDir, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("mount %s: %w", workDir, err)
+	}
+	dir.Close()
+	if err := syscall.Chdir(workDir); err != nil {
+		return err
+	}
+	// 新的 PID namespace 需要自己的 /proc，才看不到 host 的 process
+	if err := syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return err
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return err
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return err
+	}
+	// 切換帳號會清掉 Pdeathsig，重新設定
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_PDEATHSIG, uintptr(syscall.SIGKILL), 0); errno != 0 {
+		return errno
+	}
+	return syscall.Exec(javaPath, append([]string{"java"}, javaArgs...), os.Environ())
+}
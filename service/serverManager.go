@@ -8,23 +8,20 @@ import (
 	"errors"
 	"fmt"
 	"go-backend/common"
+	"go-backend/model"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 )
 
-const (
-	// MaxServersPerOwner 限制每個 owner 同時開啟的最大伺服器數量
-	MaxServersPerOwner = 3
-)
-
 var ErrAlreadyRunning = errors.New("server already running")
 var ErrNotFound = errors.New("Server Not Found.")
-var ErrMaxReached = errors.New("User has reached the maximum number of servers")
 var ErrServerRunning = errors.New("Cannot Backup while server is running")
+var ErrServerBusy = errors.New("server has a job in progress")
 
 type Server struct {
 	sid       string
@@ -34,6 +31,7 @@ type Server struct {
 	minMem    string
 	port      string
 	cmd       *exec.Cmd
+	exited    chan struct{} // cmd 結束且清理完成後關閉
 	Monitor   *Monitor
 	stdin     io.Writer
 	stdout    io.Reader
@@ -42,6 +40,11 @@ type Server struct {
 	exp       time.Time
 	sdc       func(string)
 	args      []string
+	env       []string
+	cgroup    *serverCgroup
+	ticks     *tickMonitor
+	players   int // 最後一次 Server List Ping 的線上人數
+	playersAt time.Time
 	mu        sync.RWMutex
 }
 
@@ -66,56 +69,150 @@ func (s *Server) Start() error {
 	if s.running {
 		return ErrAlreadyRunning
 	}
+	// 上次執行中排隊的設定在啟動前寫入
+	if err := applyPendingProperties(s.sid, s.workDir); err != nil {
+		common.SysError("failed to apply staged properties: " + err.Error())
+	}
+	// fabric server 啟動前先確認 mod 相依都滿足，避免開到一半才 crash
+	if err := ValidateServerMods(s.sid, s.workDir); err != nil {
+		return err
+	}
+	// 隔離模式下 server 以自己的帳號執行，資料夾擁有者也要一致
+	id, err := resolveServerIdentity(s.sid)
+	if err != nil {
+		return err
+	}
+	if id != nil {
+		if err := prepareWorkDir(s.workDir, id); err != nil {
+			return fmt.Errorf("failed to prepare server directory: %w", err)
+		}
+	}
+	// 依 server 類型決定啟動方式 (-jar 或 forge 的 @args 檔)
+	launchArgs := []string{"-jar", "server.jar"}
+	if provider, err := ServerTypeForID(s.sid); err == nil {
+		if launchArgs, err = provider.LaunchArgs(s.workDir); err != nil {
+			return err
+		}
+	}
 	// 建立命令參數
 	cmdArgs := []string{
 		"-Xms" + s.minMem,
 		"-Xmx" + s.maxMem,
-		"-jar", "server.jar",
-		"--port", s.port,
 	}
+	cmdArgs = append(cmdArgs, launchArgs...)
+	cmdArgs = append(cmdArgs, "--port", s.port)
 	cmdArgs = append(cmdArgs, s.args...)
-	cmd := exec.CommandContext(context.Background(), "java", cmdArgs...)
-	cmd.Dir = s.workDir
-	stdin, err := cmd.StdinPipe()
+
+	// cgroup 只是額外的限制，建立失敗時照常啟動
+	cg, err := newServerCgroup(s.sid, cgroupLimitsFor(s.maxMem))
 	if err != nil {
-		return err
+		if !errors.Is(err, ErrCgroupUnavailable) {
+			common.SysError("failed to create cgroup for server " + s.sid + ": " + err.Error())
+		}
+		cg = nil
+	}
+	cmd, stdin, stdout, err := s.startCommand(cmdArgs, id, cg)
+	if err != nil && cg != nil {
+		// 5.7 以前的 kernel 不支援直接在 cgroup 內建立 process，改成不使用 cgroup
+		common.SysError("failed to start server " + s.sid + " in cgroup, retrying without it: " + err.Error())
+		cg.remove()
+		cg = nil
+		cmd, stdin, stdout, err = s.startCommand(cmdArgs, id, nil)
 	}
-	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return err
 	}
-	cmd.Stderr = cmd.Stdout
 
 	s.cmd = cmd
 	s.stdin = stdin
 	s.stdout = stdout
 	s.logBuffer.Reset()
-
-	if err := cmd.Start(); err != nil {
-		return err
-	}
+	s.ticks = newTickMonitor(s.sid)
 	s.running = true
+	markDiskDirty(s.sid)
 	pid := int32(cmd.Process.Pid)
+	s.cgroup = cg
+	// process 已經結束時拿不到 monitor，交給 waitAndCleanup 處理
 	mon, err := SetUpMonitor(pid)
+	if err != nil {
+		common.SysError("failed to monitor server " + s.sid + ": " + err.Error())
+		mon = nil
+	} else {
+		mon.cgroup = cg
+		mon.Start(2 * time.Second) // 2 Seconds interval
+	}
 	s.Monitor = mon
-	s.Monitor.Start(2 * time.Second) // 2 Seconds interval
+	s.exited = make(chan struct{})
 	s.exp = time.Now().Add(3 * time.Minute)
 	go s.captureLogs()
-	go s.waitAndCleanup()
+	go s.waitAndCleanup(cmd, cg, mon, s.exited)
 	return nil
 }
 
+// startCommand 建立並啟動 java；cg 不為 nil 時 process 一開始就在 cgroup 內，fork 出的子 process 也逃不出去
+func (s *Server) startCommand(cmdArgs []string, id *serverIdentity, cg *serverCgroup) (*exec.Cmd, io.WriteCloser, io.ReadCloser, error) {
+	cmd := exec.CommandContext(context.Background(), "java", cmdArgs...)
+	cmd.Dir = s.workDir
+	cmd.Env = append(os.Environ(), s.env...)
+	if id != nil {
+		if err := applyIsolation(cmd, id); err != nil {
+			return nil, nil, nil, err
+		}
+		// java 會寫 ~/.java，HOME 指向 server 自己的資料夾
+		cmd.Env = append(cmd.Env, "HOME="+s.workDir)
+	}
+	if cg != nil {
+		cg.attach(cmd)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+	return cmd, stdin, stdout, nil
+}
+
 func (s *Server) captureLogs() {
-	io.Copy(s.logBuffer, s.stdout)
+	io.Copy(io.MultiWriter(s.logBuffer, s.tickMonitor()), s.stdout)
 }
 
-func (s *Server) waitAndCleanup() {
-	s.cmd.Wait()
+func (s *Server) tickMonitor() *tickMonitor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ticks
+}
+
+// waitAndCleanup 只清理這次啟動的 cmd / cgroup / monitor；Restart 後 s 上的欄位已經屬於新的 process
+func (s *Server) waitAndCleanup(cmd *exec.Cmd, cg *serverCgroup, mon *Monitor, exited chan struct{}) {
+	cmd.Wait()
+	if mon != nil {
+		mon.Stop()
+		mon.sampleOnce()
+		if snap := mon.Snapshot(); snap.Cgroup != nil && snap.Cgroup.OOMKills > 0 {
+			common.SysLog(fmt.Sprintf("server %s was OOM killed (memory.max %d bytes)", s.sid, snap.Cgroup.MemoryMax))
+		}
+	}
+	if cg != nil {
+		cg.remove()
+	}
+	// Stop 拿著 s.mu 等這個 channel，必須在上鎖前關閉
+	close(exited)
+
 	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd != cmd {
+		return
+	}
 	s.running = false
 	s.exp = time.Now().Add(3 * time.Minute)
-	s.Monitor.Stop()
-	s.mu.Unlock()
+	s.cgroup = nil
 }
 
 func (s *Server) GetLatestSnapshot() (snap Snapshot, ok bool) {
@@ -143,27 +240,23 @@ func (s *Server) Stop() error {
 
 	_, _ = io.WriteString(s.stdin, "stop\n")
 
+	// cmd.Wait 只能由 waitAndCleanup 呼叫，這裡等它清理完成
 	timeout := 30 * time.Second
-	done := make(chan error, 1)
-	go func() {
-		done <- s.cmd.Wait()
-	}()
-
 	select {
 	case <-time.After(timeout):
 		// 超時，強制 kill
 		if s.cmd.Process != nil {
 			_ = s.cmd.Process.Kill()
 		}
-		<-done // 等候 goroutine 結束（可以忽略 error）
-	case err := <-done:
-		if err != nil {
-			common.SysError(err.Error())
-		}
+		<-s.exited
+	case <-s.exited:
 	}
 
 	s.running = false
 	s.exp = time.Now().Add(3 * time.Minute)
+	if err := applyPendingProperties(s.sid, s.workDir); err != nil {
+		common.SysError("failed to apply staged properties: " + err.Error())
+	}
 	return nil
 }
 
@@ -183,6 +276,20 @@ func (s *Server) Status() string {
 	return "running"
 }
 
+// Players 最後一次 ping 到的線上人數，還沒 ping 成功時 at 為零值
+func (s *Server) Players() (online int, at time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.players, s.playersAt
+}
+
+func (s *Server) setPlayers(online int) {
+	s.mu.Lock()
+	s.players = online
+	s.playersAt = time.Now()
+	s.mu.Unlock()
+}
+
 func (s *Server) Port() string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -216,12 +323,13 @@ func (s *Server) SendCommand(cmd string) error {
 	return err
 }
 
+// SetProperty server 執行中時先排隊，下次停止或啟動時才寫入
 func (s *Server) SetProperty(key, value string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.running {
-		return errors.New("必須先停止 server 才能修改 server.properties")
+		return model.StagePendingProperties(s.sid, 0, map[string]string{key: value})
 	}
 	return UpdateProperty(s.workDir, key, value)
 }
@@ -244,17 +352,15 @@ func (s *Server) ShutDown() error {
 // ---------------- ServerManager ----------------
 
 type ServerManager struct {
-	servers        map[string]*Server
-	availablePorts []int
-	usingPorts     map[int]string //port -> server ID
-	mu             sync.RWMutex
+	servers map[string]*Server
+	ports   *PortAllocator
+	mu      sync.RWMutex
 }
 
-func NewServerManager(ports []int) *ServerManager {
+func NewServerManager(ports *PortAllocator) *ServerManager {
 	sm := &ServerManager{
-		servers:        make(map[string]*Server),
-		availablePorts: ports,
-		usingPorts:     make(map[int]string),
+		servers: make(map[string]*Server),
+		ports:   ports,
 	}
 	go sm.cleanupExpired()
 	return sm
@@ -265,8 +371,7 @@ func (sm *ServerManager) ServerSaveList(sid, workDir string) ([]string, error) {
 	list := make([]string, 0)
 
 	// os read dir
-	backupDir := filepath.Join(workDir, "backup")
-	entries, err := os.ReadDir(backupDir)
+	entries, err := os.ReadDir(serverBackupDir(sid, workDir))
 	if err != nil {
 		return list, err
 	}
@@ -280,21 +385,36 @@ func (sm *ServerManager) ServerSaveList(sid, workDir string) ([]string, error) {
 	return list, nil
 }
 
-func (sm *ServerManager) ServerSaveRollBack(sid, fileName, workDir string) error {
+// IsRunning 給建立 job 前的同步檢查使用
+func (sm *ServerManager) IsRunning(sid string) bool {
 	sm.mu.RLock()
 	srv, exists := sm.servers[sid]
 	sm.mu.RUnlock()
+	return exists && srv.Status() == "running"
+}
 
-	if exists && srv.Status() == "running" {
+// ServerSaveRollBack 先複製到暫存資料夾，完成後才替換 world，中途失敗或取消不會動到現有的 world
+func (sm *ServerManager) ServerSaveRollBack(jc *JobContext, sid, fileName, workDir string) error {
+	if sm.IsRunning(sid) {
 		return ErrServerRunning
 	}
 
-	src := filepath.Join(workDir, "backup", fileName)
+	src := filepath.Join(serverBackupDir(sid, workDir), fileName)
 	if _, err := os.ReadDir(src); err != nil {
 		return os.ErrNotExist
 	}
 
-	dst := filepath.Join(workDir, "world")
+	dst := filepath.Join(workDir, levelName(workDir))
+	tmp := dst + ".restoring"
+
+	jc.Step("copying " + fileName)
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := copyTree(jc, src, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
 
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -303,92 +423,52 @@ func (sm *ServerManager) ServerSaveRollBack(sid, fileName, workDir string) error
 		return errRemove
 	}
 
-	if errCopy := common.Copy(src, dst); errCopy != nil {
-		return errCopy
-	}
-
-	return nil
+	return os.Rename(tmp, dst)
 }
 
-func (sm *ServerManager) countByOwner(oid string) int {
+// ownerUsage owner 執行中的 server 數量與記憶體 (-Xmx) 總和
+func (sm *ServerManager) ownerUsage(oid string) (running int, memMB int64) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
-	count := 0
 	for _, srv := range sm.servers {
-		if srv.oid == oid {
-			count++
+		if srv.oid == oid && srv.Status() == "running" {
+			running++
+			memMB += parseMemoryMB(srv.maxMem)
 		}
 	}
-	return count
-}
-
-func (sm *ServerManager) allocatePort() (int, error) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	if len(sm.availablePorts) == 0 {
-		return 0, errors.New("no available ports")
-	}
-	port := sm.availablePorts[0]
-	sm.availablePorts = sm.availablePorts[1:]
-	sm.usingPorts[port] = ""
-	return port, nil
-}
-
-// assignPortToServer 將 port 綁定給 sid
-func (sm *ServerManager) assignPortToServer(port int, sid string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	sm.usingPorts[port] = sid
-}
-
-// releasePort 釋放回 pool
-func (sm *ServerManager) releasePort(portStr string) {
-	var port int
-	fmt.Sscanf(portStr, "%d", &port)
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	delete(sm.usingPorts, port)
-	sm.availablePorts = append(sm.availablePorts, port)
-}
-
-func (sm *ServerManager) releasePortWithOutLock(portStr string) {
-	var port int
-	fmt.Sscanf(portStr, "%d", &port)
-	delete(sm.usingPorts, port)
-	sm.availablePorts = append(sm.availablePorts, port)
+	return running, memMB
 }
 
 func (sm *ServerManager) StartServer(sid, oid, workDir, maxMem, minMem string, args []string) (*Server, error) {
-	if sm.countByOwner(oid) >= MaxServersPerOwner {
-		return nil, ErrMaxReached
-	}
-
 	sm.mu.Lock()
 	if s, exists := sm.servers[sid]; exists {
-		err := s.Start()
-		if err != nil && errors.Is(err, ErrAlreadyRunning) {
+		if s.Status() == "running" {
 			sm.mu.Unlock()
 			common.SysDebug("server already running sid: " + sid)
 			return s, nil
-		} else if err != nil {
-			panic("Unknow error:" + err.Error())
 		}
-		sm.mu.Unlock()
-		common.SysDebug("Server is running: " + sid)
-		return s, nil // Server Running successfully
+		// 已停止的舊紀錄重新建立，才會套用最新的 port 設定
+		delete(sm.servers, sid)
 	}
 	sm.mu.Unlock()
 
-	var allocatedPort int
-	p, err := sm.allocatePort()
+	// 備份、還原與升級會替換 world 與啟動檔，做完之前不能啟動
+	if Jobs().Busy(sid) {
+		return nil, ErrServerBusy
+	}
+
+	// 每個 server 使用固定的 port，位址不會因為重新啟動而改變
+	ports, err := sm.ports.Ensure(sid)
 	if err != nil {
 		return nil, err
 	}
-	allocatedPort = p
-	portStr := fmt.Sprintf("%d", p)
+	if err := applyPortProperties(sid, workDir, ports); err != nil {
+		return nil, err
+	}
+	portStr := strconv.Itoa(gamePort(ports))
 
 	srv := NewServer(sid, oid, workDir, maxMem, minMem, portStr, sm.shutDownServerCallback, args)
-	sm.assignPortToServer(allocatedPort, sid)
+	srv.env = portEnv(ports)
 
 	sm.mu.Lock()
 	sm.servers[sid] = srv
@@ -397,7 +477,6 @@ func (sm *ServerManager) StartServer(sid, oid, workDir, maxMem, minMem string, a
 	if err := srv.Start(); err != nil {
 		sm.mu.Lock()
 		delete(sm.servers, sid)
-		sm.releasePort(portStr)
 		sm.mu.Unlock()
 		return nil, err
 	}
@@ -464,6 +543,31 @@ func (sm *ServerManager) GetServerUsage(sid string) (Snapshot, error) {
 	return snap, nil
 }
 
+func (sm *ServerManager) get(sid string) (*Server, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	srv, ok := sm.servers[sid]
+	return srv, ok
+}
+
+// runningServers 目前執行中的 server
+func (sm *ServerManager) runningServers() []*Server {
+	sm.mu.RLock()
+	list := make([]*Server, 0, len(sm.servers))
+	for _, srv := range sm.servers {
+		list = append(list, srv)
+	}
+	sm.mu.RUnlock()
+
+	running := list[:0]
+	for _, srv := range list {
+		if srv.Status() == "running" {
+			running = append(running, srv)
+		}
+	}
+	return running
+}
+
 func (sm *ServerManager) GetServerStatus(sid string) (string, error) {
 	sm.mu.RLock()
 	srv, exists := sm.servers[sid]
@@ -477,9 +581,6 @@ func (sm *ServerManager) GetServerStatus(sid string) (string, error) {
 func (sm *ServerManager) shutDownServerCallback(sid string) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	// shut down server時必須釋放port
-	srv := sm.servers[sid]
-	sm.releasePortWithOutLock(srv.port)
 	delete(sm.servers, sid)
 }
 
@@ -495,20 +596,26 @@ func (sm *ServerManager) ReadLatestLog(sid string) (string, error) {
 	return srv.ReadLatestLog(), nil
 }
 
-func (sm *ServerManager) BackUp(sid, workDir string) error {
-	sm.mu.RLock()
-	srv, exists := sm.servers[sid]
-	sm.mu.RUnlock()
-
-	if exists && srv.Status() == "running" {
-		return ErrServerRunning
+// BackUp 複製 world 到 BACKUP_PATH/<server_id>/world/<時間>，回傳備份名稱；失敗或取消時刪掉不完整的備份
+func (sm *ServerManager) BackUp(jc *JobContext, sid, workDir string) (string, error) {
+	if sm.IsRunning(sid) {
+		return "", ErrServerRunning
 	}
 
-	src := workDir + "/world"
-	dst := workDir + "/backup/" + time.Now().Format("20060102_150405")
-
-	return common.Copy(src, dst)
+	name := time.Now().Format("20060102_150405")
+	src := filepath.Join(workDir, levelName(workDir))
+	dir := serverBackupDir(sid, workDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, name)
 
+	jc.Step("copying world")
+	if err := copyTree(jc, src, dst); err != nil {
+		os.RemoveAll(dst)
+		return "", err
+	}
+	return name, nil
 }
 
 func (sm *ServerManager) cleanupExpired() {
@@ -521,7 +628,6 @@ func (sm *ServerManager) cleanupExpired() {
 			s := srv.Status()
 			isExp := srv.exp.Before(now)
 			if s == "stopped" && isExp {
-				sm.releasePortWithOutLock(srv.port)
 				delete(sm.servers, sid)
 				common.SysLog(fmt.Sprintf("Server: %s del, port: %s", sid, srv.port))
 			}
@@ -7,20 +7,24 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf16"
 )
 
 func read(dir string) (*os.File, error) {
 	path := dir + "/server.properties"
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		header := fmt.Sprintf("#Minecraft server properties\n#Generated on %s\n", time.Now().Format(time.RFC1123))
-		if err := os.WriteFile(path, []byte(header), 0644); err != nil {
+		if err := writeWorkDirFile(dir, path, []byte(header), 0644); err != nil {
 			return nil, err
 		}
 	}
 
-	f, err := os.Open(path)
+	f, err := openWorkDirFile(dir, path)
 
 	if err != nil {
 		return nil, err
@@ -29,13 +33,13 @@ func read(dir string) (*os.File, error) {
 	return f, nil
 }
 
-func backUp(src, dst string) error {
-	in, err := os.Open(src)
+func backUp(workDir, src, dst string) error {
+	in, err := openWorkDirFile(workDir, src)
 	if err != nil {
 		return err
 	}
 	defer in.Close()
-	out, err := os.Create(dst)
+	out, err := createInWorkDir(workDir, dst, 0644)
 	if err != nil {
 		return err
 	}
@@ -47,10 +51,15 @@ func backUp(src, dst string) error {
 }
 
 func UpdateProperty(workDir, key, value string) error {
+	return UpdateProperties(workDir, map[string]string{key: value})
+}
+
+// UpdateProperties 一次寫入多個設定，保留註解、順序與其他 key，沒有的 key 加在最後
+func UpdateProperties(workDir string, values map[string]string) error {
 	path := workDir + "/server.properties"
-	_ = backUp(path, path+".bak")
+	_ = backUp(workDir, path, path+".bak")
 
-	f, err := read(path)
+	f, err := read(workDir)
 
 	if err != nil {
 		return err
@@ -60,7 +69,7 @@ func UpdateProperty(workDir, key, value string) error {
 
 	scanner := bufio.NewScanner(f)
 	var lines []string
-	found := false
+	found := make(map[string]bool, len(values))
 	for scanner.Scan() {
 		line := scanner.Text()
 		trimmedLine := strings.TrimSpace(line)
@@ -72,10 +81,11 @@ func UpdateProperty(workDir, key, value string) error {
 		}
 
 		parts := strings.SplitN(trimmedLine, "=", 2)
-		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+		key := strings.TrimSpace(parts[0])
+		if value, ok := values[key]; ok && len(parts) == 2 {
 			// 改成新的值（覆寫）
-			lines = append(lines, fmt.Sprintf("%s=%s", key, value))
-			found = true
+			lines = append(lines, fmt.Sprintf("%s=%s", key, escapePropertyValue(value)))
+			found[key] = true
 		} else {
 			lines = append(lines, line)
 		}
@@ -85,27 +95,143 @@ func UpdateProperty(workDir, key, value string) error {
 		return fmt.Errorf("error reading file: %w", err)
 	}
 
-	if !found {
-		lines = append(lines, fmt.Sprintf("%s=%s", key, value))
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if !found[key] {
+			keys = append(keys, key)
+		}
 	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading file: %w", err)
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%s", key, escapePropertyValue(values[key])))
 	}
 
-	tmpPath := path + ".tmp"
 	joined := strings.Join(lines, "\n")
 	if !strings.HasSuffix(joined, "\n") {
 		joined += "\n" // 保留 trailing newline
 	}
-	if err := os.WriteFile(tmpPath, []byte(joined), 0644); err != nil {
-		return err
+	return writeWorkDirFile(workDir, path, []byte(joined), 0644)
+
+}
+
+// escapePropertyValue 舊版 server 以 ISO-8859-1 讀取，非 ASCII 字元寫成 \uXXXX
+func escapePropertyValue(v string) string {
+	var b strings.Builder
+	for _, r := range v {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r > 0x7e:
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04X`, u)
+			}
+		default:
+			b.WriteRune(r)
+		}
 	}
-	if err := os.Rename(tmpPath, path); err != nil {
-		return err
+	return b.String()
+}
+
+// unescapePropertyValue 還原 Java properties 的跳脫字元 (\:、\=、\uXXXX 等)
+func unescapePropertyValue(v string) string {
+	if !strings.Contains(v, `\`) {
+		return v
+	}
+	var units []uint16
+	var b strings.Builder
+	flush := func() {
+		if len(units) > 0 {
+			b.WriteString(string(utf16.Decode(units)))
+			units = units[:0]
+		}
+	}
+	for i := 0; i < len(v); i++ {
+		if v[i] != '\\' || i+1 >= len(v) {
+			flush()
+			b.WriteByte(v[i])
+			continue
+		}
+		i++
+		switch c := v[i]; c {
+		case 'u':
+			if i+4 < len(v) {
+				if n, err := strconv.ParseUint(v[i+1:i+5], 16, 16); err == nil {
+					units = append(units, uint16(n))
+					i += 4
+					continue
+				}
+			}
+			flush()
+			b.WriteByte(c)
+		case 't':
+			flush()
+			b.WriteByte('\t')
+		case 'n', 'r':
+			flush()
+		default:
+			flush()
+			b.WriteByte(c)
+		}
 	}
-	return nil
+	flush()
+	return b.String()
+}
+
+// ReadProperties 讀出所有設定 (已還原跳脫字元)，檔案不存在時回傳空的 map
+func ReadProperties(workDir string) (map[string]string, error) {
+	props := make(map[string]string)
+	f, err := openWorkDirFile(workDir, workDir+"/server.properties")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return props, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
 
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		props[strings.TrimSpace(parts[0])] = unescapePropertyValue(strings.TrimSpace(parts[1]))
+	}
+	return props, scanner.Err()
+}
+
+// PropertyValue 讀取單一設定值，沒有檔案或沒有這個 key 時回傳空字串
+func PropertyValue(workDir, key string) string {
+	f, err := openWorkDirFile(workDir, workDir+"/server.properties")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return unescapePropertyValue(strings.TrimSpace(parts[1]))
+		}
+	}
+	return ""
+}
+
+// levelName server.properties 的 level-name，沒有設定或不在 workDir 底下時為 world
+func levelName(workDir string) string {
+	if level := PropertyValue(workDir, "level-name"); level != "" && filepath.IsLocal(level) {
+		return level
+	}
+	return "world"
 }
 
 func GetPropertyText(workDir string) (string, error) {
@@ -133,22 +259,14 @@ func ReplaceProperty(workDir string, texts string) error {
 	path := workDir + "/server.properties"
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		header := fmt.Sprintf("#Minecraft server properties\n#Generated on %s\n", time.Now().Format(time.RFC1123))
-		if err := os.WriteFile(path, []byte(header), 0644); err != nil {
+		if err := writeWorkDirFile(workDir, path, []byte(header), 0644); err != nil {
 			return err
 		}
 	}
 
-	_ = backUp(path, path+".bak")
-	tmpPath := path + ".tmp"
+	_ = backUp(workDir, path, path+".bak")
 	if !strings.HasSuffix(texts, "\n") {
 		texts += "\n" // 保留 trailing newline
 	}
-	if err := os.WriteFile(tmpPath, []byte(texts), 0644); err != nil {
-		return err
-	}
-	if err := os.Rename(tmpPath, path); err != nil {
-		return err
-	}
-
-	return nil
+	return writeWorkDirFile(workDir, path, []byte(texts), 0644)
 }
@@ -0,0 +1,381 @@
+// service/serverPropertySchema.go
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PropertySchemaVersion 修改 schema 內容時要加一，前端可以依此更新快取
+const PropertySchemaVersion = 2
+
+const (
+	PropBool   = "bool"
+	PropInt    = "int"
+	PropString = "string"
+	PropEnum   = "enum"
+)
+
+// PropertySpec server.properties 單一設定的型別與限制
+type PropertySpec struct {
+	Key         string   `json:"key"`
+	Type        string   `json:"type"`
+	Default     string   `json:"default"`
+	Min         *int64   `json:"min,omitempty"`
+	Max         *int64   `json:"max,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Description string   `json:"description"`
+	Restart     bool     `json:"restart"`           // 修改後需要重啟才會生效
+	Live        bool     `json:"live,omitempty"`    // server 執行中可以透過指令立即套用
+	Managed     bool     `json:"managed,omitempty"` // 由 ServerManager 控制，不能透過 API 修改
+	Since       string   `json:"since,omitempty"`   // 從哪個 minecraft 版本開始有
+}
+
+func (p PropertySpec) since(v string) PropertySpec {
+	p.Since = v
+	return p
+}
+
+func (p PropertySpec) managed() PropertySpec {
+	p.Managed = true
+	return p
+}
+
+func (p PropertySpec) live() PropertySpec {
+	p.Live = true
+	p.Restart = false
+	return p
+}
+
+func boolProp(key string, def bool, desc string) PropertySpec {
+	return PropertySpec{Key: key, Type: PropBool, Default: strconv.FormatBool(def), Description: desc, Restart: true}
+}
+
+func intProp(key string, def, min, max int64, desc string) PropertySpec {
+	return PropertySpec{Key: key, Type: PropInt, Default: strconv.FormatInt(def, 10), Min: &min, Max: &max, Description: desc, Restart: true}
+}
+
+func strProp(key, def, desc string) PropertySpec {
+	return PropertySpec{Key: key, Type: PropString, Default: def, Description: desc, Restart: true}
+}
+
+func enumProp(key, def string, values []string, desc string) PropertySpec {
+	return PropertySpec{Key: key, Type: PropEnum, Default: def, Enum: values, Description: desc, Restart: true}
+}
+
+const maxInt32 = 2147483647
+
+var propertySchema = []PropertySpec{
+	boolProp("accepts-transfers", false, "Accept incoming transfers from other servers").since("1.20.5"),
+	boolProp("allow-flight", false, "Allow flight in survival mode (mods or clients)"),
+	boolProp("allow-nether", true, "Allow players to travel to the Nether"),
+	boolProp("broadcast-console-to-ops", true, "Send console command output to online operators"),
+	boolProp("broadcast-rcon-to-ops", true, "Send rcon command output to online operators"),
+	enumProp("difficulty", "easy", []string{"peaceful", "easy", "normal", "hard"}, "World difficulty").live(),
+	boolProp("enable-command-block", false, "Enable command blocks"),
+	boolProp("enable-jmx-monitoring", false, "Expose JMX MBeans for tick times"),
+	boolProp("enable-query", false, "Enable the GameSpy4 query protocol"),
+	boolProp("enable-rcon", false, "Enable remote console access"),
+	boolProp("enable-status", true, "Show the server as online in the server list"),
+	boolProp("enforce-secure-profile", true, "Require players to have a Mojang-signed public key").since("1.19"),
+	boolProp("enforce-whitelist", false, "Kick players that are not on the whitelist when it is reloaded"),
+	intProp("entity-broadcast-range-percentage", 100, 10, 1000, "Distance entities are sent to clients, in percent"),
+	boolProp("force-gamemode", false, "Force players into the default game mode when joining"),
+	intProp("function-permission-level", 2, 1, 4, "Permission level of functions"),
+	enumProp("gamemode", "survival", []string{"survival", "creative", "adventure", "spectator"}, "Default game mode"),
+	boolProp("generate-structures", true, "Generate structures such as villages"),
+	strProp("generator-settings", "{}", "Settings used to customize world generation"),
+	boolProp("hardcore", false, "Players are set to spectator mode when they die"),
+	boolProp("hide-online-players", false, "Hide the player list in the server status").since("1.18"),
+	strProp("initial-disabled-packs", "", "Datapacks not enabled automatically on world creation").since("1.19.3"),
+	strProp("initial-enabled-packs", "vanilla", "Datapacks enabled on world creation").since("1.19.3"),
+	strProp("level-name", "world", "World folder name"),
+	strProp("level-seed", "", "Seed for the world generator"),
+	strProp("level-type", "minecraft:normal", "World preset"),
+	boolProp("log-ips", true, "Log client IP addresses").since("1.20.2"),
+	intProp("max-chained-neighbor-updates", 1000000, -1, maxInt32, "Limit of consecutive neighbor updates").since("1.19"),
+	intProp("max-players", 20, 0, maxInt32, "Maximum number of players"),
+	intProp("max-tick-time", 60000, -1, 9223372036854775807, "Milliseconds a tick may take before the watchdog stops the server"),
+	intProp("max-world-size", 29999984, 1, 29999984, "Maximum world border radius"),
+	strProp("motd", "A Minecraft Server", "Message shown in the server list"),
+	intProp("network-compression-threshold", 256, -1, maxInt32, "Packet size before compression, -1 disables"),
+	boolProp("online-mode", true, "Check players against the Minecraft account database"),
+	intProp("op-permission-level", 4, 0, 4, "Default permission level for operators"),
+	intProp("player-idle-timeout", 0, 0, maxInt32, "Minutes before idle players are kicked, 0 disables"),
+	boolProp("prevent-proxy-connections", false, "Kick players whose ISP differs from the auth server"),
+	boolProp("pvp", true, "Allow players to damage each other"),
+	intProp("query.port", 25565, 1, 65534, "Query port").managed(),
+	intProp("rate-limit", 0, 0, maxInt32, "Packets per second before kicking, 0 disables"),
+	strProp("rcon.password", "", "Password for remote console"),
+	intProp("rcon.port", 25575, 1, 65534, "Remote console port").managed(),
+	boolProp("require-resource-pack", false, "Kick players who decline the resource pack"),
+	strProp("resource-pack", "", "URL of the server resource pack"),
+	strProp("resource-pack-prompt", "", "Message shown with the resource pack prompt"),
+	strProp("resource-pack-sha1", "", "SHA-1 of the resource pack"),
+	strProp("server-ip", "", "Address the server binds to"),
+	intProp("server-port", 25565, 1, 65534, "Server port").managed(),
+	intProp("simulation-distance", 10, 3, 32, "Chunk radius in which entities are ticked").since("1.18"),
+	boolProp("spawn-animals", true, "Spawn animals"),
+	boolProp("spawn-monsters", true, "Spawn monsters"),
+	boolProp("spawn-npcs", true, "Spawn villagers"),
+	intProp("spawn-protection", 16, 0, maxInt32, "Radius around spawn non-operators cannot build in"),
+	boolProp("sync-chunk-writes", true, "Write chunk files synchronously"),
+	boolProp("use-native-transport", true, "Use optimized packet handling on Linux"),
+	intProp("view-distance", 10, 3, 32, "Chunk radius sent to clients"),
+	boolProp("white-list", false, "Only allow players on the whitelist").live(),
+}
+
+var propertySchemaByKey = func() map[string]PropertySpec {
+	m := make(map[string]PropertySpec, len(propertySchema))
+	for _, p := range propertySchema {
+		m[p.Key] = p
+	}
+	return m
+}()
+
+// isReleaseVersion 快照 (例如 23w31a) 無法比較版本，視為包含全部設定
+func isReleaseVersion(v string) bool {
+	return v != "" && v[0] >= '0' && v[0] <= '9' && strings.Contains(v, ".") && !strings.Contains(v, "w")
+}
+
+// PropertySchema 指定 minecraft 版本可用的設定，mcVersion 為空時回傳全部
+func PropertySchema(mcVersion string) []PropertySpec {
+	if !isReleaseVersion(mcVersion) {
+		return propertySchema
+	}
+	list := make([]PropertySpec, 0, len(propertySchema))
+	for _, p := range propertySchema {
+		if p.Since != "" && CompareVersions(mcVersion, p.Since) < 0 {
+			continue
+		}
+		list = append(list, p)
+	}
+	return list
+}
+
+func lookupPropertySpec(key, mcVersion string) (PropertySpec, bool) {
+	p, ok := propertySchemaByKey[key]
+	if !ok {
+		return p, false
+	}
+	if p.Since != "" && isReleaseVersion(mcVersion) && CompareVersions(mcVersion, p.Since) < 0 {
+		return p, false
+	}
+	return p, true
+}
+
+var ErrInvalidProperty = errors.New("invalid property")
+
+// PropertyValidationError 每個 key 對應的錯誤訊息
+type PropertyValidationError struct {
+	Errors map[string]string
+}
+
+func (e *PropertyValidationError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for k := range e.Errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + ": " + e.Errors[k]
+	}
+	return "invalid property: " + strings.Join(parts, "; ")
+}
+
+func (e *PropertyValidationError) Unwrap() error { return ErrInvalidProperty }
+
+// validPropertySyntax 不在 schema 內的 key 只檢查不會破壞檔案格式
+func validPropertySyntax(key, value string) bool {
+	return key != "" && !strings.ContainsAny(key, "=:#!\r\n \t") && !strings.ContainsAny(value, "\r\n")
+}
+
+// NormalizeProperty 把 JSON 的值轉成寫入檔案的字串，nil 代表還原成預設值
+func NormalizeProperty(spec PropertySpec, v any) (string, error) {
+	if v == nil {
+		return spec.Default, nil
+	}
+	switch spec.Type {
+	case PropBool:
+		switch b := v.(type) {
+		case bool:
+			return strconv.FormatBool(b), nil
+		case string:
+			if b == "true" || b == "false" {
+				return b, nil
+			}
+		}
+		return "", errors.New("must be a boolean")
+	case PropInt:
+		var n int64
+		switch x := v.(type) {
+		case float64:
+			if x != float64(int64(x)) {
+				return "", errors.New("must be an integer")
+			}
+			n = int64(x)
+		case string:
+			parsed, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+			if err != nil {
+				return "", errors.New("must be an integer")
+			}
+			n = parsed
+		default:
+			return "", errors.New("must be an integer")
+		}
+		if spec.Min != nil && n < *spec.Min {
+			return "", fmt.Errorf("must be at least %d", *spec.Min)
+		}
+		if spec.Max != nil && n > *spec.Max {
+			return "", fmt.Errorf("must be at most %d", *spec.Max)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case PropEnum:
+		s, ok := v.(string)
+		if ok {
+			for _, e := range spec.Enum {
+				if s == e {
+					return s, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("must be one of %s", strings.Join(spec.Enum, ", "))
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return "", errors.New("must be a string")
+		}
+		if strings.ContainsAny(s, "\r\n") {
+			return "", errors.New("must not contain line breaks")
+		}
+		return s, nil
+	}
+}
+
+// ValidatePropertyChanges 依 schema 檢查修改，全部通過才回傳要寫入的字串值
+// 由 ServerManager 控制的設定 (port 等) 一律拒絕
+func ValidatePropertyChanges(mcVersion string, changes map[string]any) (map[string]string, error) {
+	values := make(map[string]string, len(changes))
+	errs := make(map[string]string)
+	for key, v := range changes {
+		spec, known := lookupPropertySpec(key, mcVersion)
+		if !known {
+			s, ok := v.(string)
+			if !ok || !validPropertySyntax(key, s) {
+				errs[key] = "unknown property must be a single-line string"
+				continue
+			}
+			values[key] = s
+			continue
+		}
+		if spec.Managed {
+			errs[key] = "managed by the server manager"
+			continue
+		}
+		s, err := NormalizeProperty(spec, v)
+		if err != nil {
+			errs[key] = err.Error()
+			continue
+		}
+		values[key] = s
+	}
+	if len(errs) > 0 {
+		return nil, &PropertyValidationError{Errors: errs}
+	}
+	return values, nil
+}
+
+// typedPropertyValue 把檔案內的字串轉回 JSON 型別，無法解析時回傳錯誤
+func typedPropertyValue(spec PropertySpec, raw string) (any, error) {
+	switch spec.Type {
+	case PropBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("not a boolean")
+		}
+		return b, nil
+	case PropInt:
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return nil, errors.New("not an integer")
+		}
+		return n, nil
+	case PropEnum:
+		for _, e := range spec.Enum {
+			if raw == e {
+				return raw, nil
+			}
+		}
+		return nil, errors.New("not a valid option")
+	}
+	return raw, nil
+}
+
+// PropertiesView GET 回傳的內容，unknown 是 schema 以外的設定，invalid 是檔案內無法解析的值，
+// pending 是 server 執行中排隊、尚未寫入的修改
+type PropertiesView struct {
+	SchemaVersion int               `json:"schema_version"`
+	Properties    map[string]any    `json:"properties"`
+	Unknown       map[string]string `json:"unknown"`
+	Invalid       map[string]string `json:"invalid"`
+	Pending       map[string]string `json:"pending"`
+}
+
+// GetTypedProperties 讀取 server.properties 並依 schema 轉型，檔案沒有的 key 使用預設值
+func GetTypedProperties(workDir, mcVersion string) (*PropertiesView, error) {
+	raw, err := ReadProperties(workDir)
+	if err != nil {
+		return nil, err
+	}
+	view := &PropertiesView{
+		SchemaVersion: PropertySchemaVersion,
+		Properties:    make(map[string]any),
+		Unknown:       make(map[string]string),
+		Invalid:       make(map[string]string),
+		Pending:       make(map[string]string),
+	}
+	for _, spec := range PropertySchema(mcVersion) {
+		v, ok := raw[spec.Key]
+		if !ok {
+			v = spec.Default
+		}
+		typed, err := typedPropertyValue(spec, v)
+		if err != nil {
+			view.Invalid[spec.Key] = v
+			typed, _ = typedPropertyValue(spec, spec.Default)
+		}
+		view.Properties[spec.Key] = typed
+	}
+	for k, v := range raw {
+		if _, ok := view.Properties[k]; !ok {
+			view.Unknown[k] = v
+		}
+	}
+	return view, nil
+}
+
+type PropertyPatchResult struct {
+	Applied         map[string]string `json:"applied"`           // 已寫入 server.properties
+	Pending         map[string]string `json:"pending,omitempty"` // server 執行中，下次停止或啟動時寫入
+	Live            []string          `json:"live,omitempty"`    // 已透過指令立即套用
+	RestartRequired bool              `json:"restart_required"`
+}
+
+// livePropertyCommand 可以在執行中套用的設定對應的 console 指令
+func livePropertyCommand(key, value string) string {
+	switch key {
+	case "difficulty":
+		return "difficulty " + value
+	case "white-list":
+		if value == "true" {
+			return "whitelist on"
+		}
+		return "whitelist off"
+	}
+	return ""
+}
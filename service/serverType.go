@@ -0,0 +1,183 @@
+// service/serverType.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnsupportedServerType = errors.New("unsupported server type")
+	ErrInvalidVersion        = errors.New("invalid version string")
+)
+
+// 版本字串會被拼進 URL 與 server ID，只允許安全字元
+var versionPattern = regexp.MustCompile(`^[0-9A-Za-z._+-]{1,40}$`)
+
+// InstallOptions 各類型 server 安裝時可選的參數，沒用到的欄位會被忽略
+type InstallOptions struct {
+	Loader    string      // fabric / quilt / forge / neoforge 的 loader 版本
+	Installer string      // fabric installer 版本
+	Build     string      // paper / purpur 的 build 編號
+	Job       *JobContext // 回報下載與安裝進度，可以是 nil
+}
+
+// ServerTypeProvider 為每一種 server 類型 (Vanilla, Fabric, Paper...) 要實作的介面
+type ServerTypeProvider interface {
+	// Name 對外顯示與 API 使用的名稱，例如 "Fabric"
+	Name() string
+	// IDPrefix server ID 的前綴，固定 6 個字元，例如 "mcsfv-"
+	IDPrefix() string
+	// ListVersions 可安裝的 minecraft 版本
+	ListVersions() ([]string, error)
+	// Artifact 解析安裝需要下載的檔案 (server jar 或 installer)，供快取與預先下載使用
+	Artifact(version string, opts InstallOptions) (*ArtifactSpec, error)
+	// Install 從快取取得安裝檔並完成安裝 (例如執行 forge installer)，回傳實際安裝的 loader / build 版本，沒有則回傳空字串
+	Install(workDir, version string, opts InstallOptions) (string, error)
+	// LaunchArgs java 記憶體參數之後、--port 之前的啟動參數
+	LaunchArgs(workDir string) ([]string, error)
+}
+
+var (
+	serverTypesMu sync.RWMutex
+	serverTypes   = make(map[string]ServerTypeProvider)
+)
+
+// RegisterServerType 註冊一種 server 類型，名稱或 ID 前綴重複會 panic
+func RegisterServerType(p ServerTypeProvider) {
+	serverTypesMu.Lock()
+	defer serverTypesMu.Unlock()
+	if len(p.IDPrefix()) != 6 {
+		panic("server type id prefix must be 6 characters: " + p.IDPrefix())
+	}
+	for name, existing := range serverTypes {
+		if name == p.Name() || existing.IDPrefix() == p.IDPrefix() {
+			panic("duplicate server type: " + p.Name())
+		}
+	}
+	serverTypes[p.Name()] = p
+}
+
+func GetServerType(name string) (ServerTypeProvider, error) {
+	serverTypesMu.RLock()
+	defer serverTypesMu.RUnlock()
+	p, ok := serverTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedServerType, name)
+	}
+	return p, nil
+}
+
+// ServerTypeForID 依 server ID 前綴找出 provider
+func ServerTypeForID(sid string) (ServerTypeProvider, error) {
+	serverTypesMu.RLock()
+	defer serverTypesMu.RUnlock()
+	for _, p := range serverTypes {
+		if strings.HasPrefix(sid, p.IDPrefix()) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedServerType, sid)
+}
+
+func ServerTypeNames() []string {
+	serverTypesMu.RLock()
+	defer serverTypesMu.RUnlock()
+	names := make([]string, 0, len(serverTypes))
+	for name := range serverTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ValidateVersionString(v string) error {
+	if !versionPattern.MatchString(v) {
+		return fmt.Errorf("%w: %q", ErrInvalidVersion, v)
+	}
+	return nil
+}
+
+func init() {
+	RegisterServerType(&vanillaProvider{})
+	RegisterServerType(&fabricProvider{})
+	RegisterServerType(&quiltProvider{})
+	RegisterServerType(newPaperProvider())
+	RegisterServerType(newPurpurProvider())
+	RegisterServerType(newForgeProvider())
+	RegisterServerType(newNeoForgeProvider())
+}
+
+// ---------------- helpers ----------------
+
+// jarLaunchArgs 大部分類型都是直接 -jar
+func jarLaunchArgs(workDir, jar string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(workDir, jar)); err != nil {
+		return nil, fmt.Errorf("%s not found in %s", jar, workDir)
+	}
+	return []string{"-jar", jar}, nil
+}
+
+// provisionJar 大部分類型只需要把 server jar 複製到 workDir
+func provisionJar(p ServerTypeProvider, workDir, version string, opts InstallOptions) (string, error) {
+	spec, err := p.Artifact(version, opts)
+	if err != nil {
+		return "", err
+	}
+	opts.Job.Step("downloading " + spec.Key.Name)
+	if err := ProvisionArtifact(opts.Job, spec, filepath.Join(workDir, "server.jar")); err != nil {
+		return "", fmt.Errorf("failed to provision %s server jar: %w", p.Name(), err)
+	}
+	return spec.Loader, nil
+}
+
+// runInstaller 在 workDir 內以 headless 方式執行 installer jar，輸出寫到 installer.log
+// installer 會執行下載來的程式碼，隔離模式下和 server 一樣以 server 的帳號執行
+func runInstaller(jc *JobContext, workDir string, args ...string) error {
+	jc.Step("running installer")
+	ctx, cancel := context.WithTimeout(jc.Context(), 15*time.Minute)
+	defer cancel()
+
+	// workDir 的資料夾名稱就是 server ID
+	id, err := resolveServerIdentity(filepath.Base(workDir))
+	if err != nil {
+		return err
+	}
+	if id != nil {
+		if err := prepareWorkDir(workDir, id); err != nil {
+			return fmt.Errorf("failed to prepare server directory: %w", err)
+		}
+	}
+
+	logPath := filepath.Join(workDir, "installer.log")
+	logFile, err := createInWorkDir(workDir, logPath, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.CommandContext(ctx, "java", append([]string{"-Djava.awt.headless=true"}, args...)...)
+	cmd.Dir = workDir
+	if id != nil {
+		if err := applyIsolation(cmd, id); err != nil {
+			return err
+		}
+		cmd.Env = append(os.Environ(), "HOME="+workDir)
+	}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("installer failed (see %s): %w", logPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+// service/serverTypeForge.go
+
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go-backend/common"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// argsFileName forge / neoforge installer 依作業系統產生不同的 args 檔
+func argsFileName() string {
+	if runtime.GOOS == "windows" {
+		return "win_args.txt"
+	}
+	return "unix_args.txt"
+}
+
+// findArgsFile 在 libraries 底下找 installer 產生的 args 檔，回傳相對 workDir 的路徑
+func findArgsFile(workDir string, libDirs ...string) string {
+	for _, lib := range libDirs {
+		matches, _ := filepath.Glob(filepath.Join(workDir, lib, "*", argsFileName()))
+		if len(matches) == 0 {
+			continue
+		}
+		// 升級後可能留有多個版本，取版本號最大的
+		sort.Slice(matches, func(i, j int) bool {
+			return CompareVersions(filepath.Base(filepath.Dir(matches[i])), filepath.Base(filepath.Dir(matches[j]))) < 0
+		})
+		rel, err := filepath.Rel(workDir, matches[len(matches)-1])
+		if err != nil {
+			continue
+		}
+		return filepath.ToSlash(rel)
+	}
+	return ""
+}
+
+// installFromInstaller 從快取取得 installer jar 並以 --installServer 執行，完成後刪掉 installer
+func installFromInstaller(p ServerTypeProvider, workDir, version string, opts InstallOptions) (string, error) {
+	spec, err := p.Artifact(version, opts)
+	if err != nil {
+		return "", err
+	}
+	installer := filepath.Join(workDir, "installer.jar")
+	opts.Job.Step("downloading installer")
+	if err := ProvisionArtifact(opts.Job, spec, installer); err != nil {
+		return "", fmt.Errorf("failed to provision installer: %w", err)
+	}
+	defer os.Remove(installer)
+
+	if err := prefetchInstallerLibraries(opts.Job, workDir, installer); err != nil {
+		return "", err
+	}
+	if err := runInstaller(opts.Job, workDir, "-jar", "installer.jar", "--installServer"); err != nil {
+		return "", err
+	}
+	return spec.Loader, nil
+}
+
+// ---------------- Forge ----------------
+
+type forgeProvider struct{}
+
+func newForgeProvider() *forgeProvider {
+	return &forgeProvider{}
+}
+
+func (p *forgeProvider) mavenBase() string {
+	return common.ForgeMavenURL + "/net/minecraftforge/forge"
+}
+
+func (p *forgeProvider) promotionsURL() string {
+	return common.ForgeFilesURL + "/net/minecraftforge/forge/promotions_slim.json"
+}
+
+func (p *forgeProvider) Name() string     { return "Forge" }
+func (p *forgeProvider) IDPrefix() string { return "mcsgv-" }
+
+type mavenMetadata struct {
+	Versioning struct {
+		Versions []string `xml:"versions>version"`
+	} `xml:"versioning"`
+}
+
+func (p *forgeProvider) mavenVersions() ([]string, error) {
+	url := p.mavenBase() + "/maven-metadata.xml"
+	client := common.NewHTTPClient(30 * time.Second)
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("http get %s error: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status from %s: %s", url, resp.Status)
+	}
+	var meta mavenMetadata
+	if err := xml.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, err
+	}
+	return meta.Versioning.Versions, nil
+}
+
+func (p *forgeProvider) ListVersions() ([]string, error) {
+	all, err := p.mavenVersions()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	versions := make([]string, 0)
+	for _, v := range all {
+		// 格式為 <mc>-<forge>，例如 1.20.1-47.2.0
+		mc, _, ok := strings.Cut(v, "-")
+		if !ok || seen[mc] {
+			continue
+		}
+		seen[mc] = true
+		versions = append(versions, mc)
+	}
+	sort.Slice(versions, func(i, j int) bool { return CompareVersions(versions[i], versions[j]) > 0 })
+	return versions, nil
+}
+
+// resolveLoader 沒指定 forge 版本時，優先 recommended 再來 latest
+func (p *forgeProvider) resolveLoader(version, loader string) (string, error) {
+	if loader != "" {
+		return loader, nil
+	}
+	var promos struct {
+		Promos map[string]string `json:"promos"`
+	}
+	if err := getUpstreamJSON(p.promotionsURL(), &promos); err != nil {
+		return "", err
+	}
+	if v, ok := promos.Promos[version+"-recommended"]; ok {
+		return v, nil
+	}
+	if v, ok := promos.Promos[version+"-latest"]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: no forge release for %s", ErrBuildNotFound, version)
+}
+
+func (p *forgeProvider) Artifact(version string, opts InstallOptions) (*ArtifactSpec, error) {
+	loader, err := p.resolveLoader(version, opts.Loader)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateVersionString(loader); err != nil {
+		return nil, err
+	}
+	full := version + "-" + loader
+	return &ArtifactSpec{
+		Key:    ArtifactKey{ServerType: p.Name(), Version: version, Loader: loader, Name: "installer.jar"},
+		Source: ArtifactSource{URL: fmt.Sprintf("%s/%s/forge-%s-installer.jar", p.mavenBase(), full, full)},
+		Loader: loader,
+	}, nil
+}
+
+func (p *forgeProvider) Install(workDir, version string, opts InstallOptions) (string, error) {
+	return installFromInstaller(p, workDir, version, opts)
+}
+
+func (p *forgeProvider) LaunchArgs(workDir string) ([]string, error) {
+	// 1.17 以後使用 args 檔
+	if args := findArgsFile(workDir, "libraries/net/minecraftforge/forge"); args != "" {
+		return []string{"@" + args}, nil
+	}
+	// 舊版 installer 會產生 forge-<ver>.jar
+	matches, _ := filepath.Glob(filepath.Join(workDir, "forge-*.jar"))
+	for _, m := range matches {
+		if !strings.Contains(filepath.Base(m), "installer") {
+			return []string{"-jar", filepath.Base(m)}, nil
+		}
+	}
+	return nil, fmt.Errorf("forge launch files not found in %s", workDir)
+}
+
+// ---------------- NeoForge ----------------
+
+type neoForgeProvider struct{}
+
+func newNeoForgeProvider() *neoForgeProvider {
+	return &neoForgeProvider{}
+}
+
+func (p *neoForgeProvider) mavenBase() string {
+	return common.NeoForgeMavenURL + "/releases/net/neoforged/neoforge"
+}
+
+func (p *neoForgeProvider) versionsURL() string {
+	return common.NeoForgeMavenURL + "/api/maven/versions/releases/net/neoforged/neoforge"
+}
+
+func (p *neoForgeProvider) Name() string     { return "NeoForge" }
+func (p *neoForgeProvider) IDPrefix() string { return "mcsnv-" }
+
+// neoForgeMCVersion neoforge 版本號的前兩段對應 minecraft 版本，例如 21.1.77 -> 1.21.1、20.4.237 -> 1.20.4
+func neoForgeMCVersion(v string) string {
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 {
+		return ""
+	}
+	if parts[1] == "0" {
+		return "1." + parts[0]
+	}
+	return "1." + parts[0] + "." + parts[1]
+}
+
+func (p *neoForgeProvider) allVersions() ([]string, error) {
+	var res struct {
+		Versions []string `json:"versions"`
+	}
+	if err := getUpstreamJSON(p.versionsURL(), &res); err != nil {
+		return nil, err
+	}
+	return res.Versions, nil
+}
+
+func (p *neoForgeProvider) ListVersions() ([]string, error) {
+	all, err := p.allVersions()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	versions := make([]string, 0)
+	for _, v := range all {
+		mc := neoForgeMCVersion(v)
+		if mc == "" || seen[mc] {
+			continue
+		}
+		seen[mc] = true
+		versions = append(versions, mc)
+	}
+	sort.Slice(versions, func(i, j int) bool { return CompareVersions(versions[i], versions[j]) > 0 })
+	return versions, nil
+}
+
+// resolveLoader 沒指定時取該 minecraft 版本最新的非 beta 版本
+func (p *neoForgeProvider) resolveLoader(version, loader string) (string, error) {
+	if loader != "" {
+		return loader, nil
+	}
+	all, err := p.allVersions()
+	if err != nil {
+		return "", err
+	}
+	var latest, latestBeta string
+	for _, v := range all {
+		if neoForgeMCVersion(v) != version {
+			continue
+		}
+		if strings.Contains(v, "beta") {
+			if latestBeta == "" || CompareVersions(v, latestBeta) > 0 {
+				latestBeta = v
+			}
+			continue
+		}
+		if latest == "" || CompareVersions(v, latest) > 0 {
+			latest = v
+		}
+	}
+	if latest != "" {
+		return latest, nil
+	}
+	if latestBeta != "" {
+		return latestBeta, nil
+	}
+	return "", fmt.Errorf("%w: no neoforge release for %s", ErrBuildNotFound, version)
+}
+
+func (p *neoForgeProvider) Artifact(version string, opts InstallOptions) (*ArtifactSpec, error) {
+	loader, err := p.resolveLoader(version, opts.Loader)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateVersionString(loader); err != nil {
+		return nil, err
+	}
+	return &ArtifactSpec{
+		Key:    ArtifactKey{ServerType: p.Name(), Version: version, Loader: loader, Name: "installer.jar"},
+		Source: ArtifactSource{URL: fmt.Sprintf("%s/%s/neoforge-%s-installer.jar", p.mavenBase(), loader, loader)},
+		Loader: loader,
+	}, nil
+}
+
+func (p *neoForgeProvider) Install(workDir, version string, opts InstallOptions) (string, error) {
+	return installFromInstaller(p, workDir, version, opts)
+}
+
+func (p *neoForgeProvider) LaunchArgs(workDir string) ([]string, error) {
+	if args := findArgsFile(workDir, "libraries/net/neoforged/neoforge", "libraries/net/neoforged/forge"); args != "" {
+		return []string{"@" + args}, nil
+	}
+	return nil, fmt.Errorf("neoforge launch files not found in %s", workDir)
+}
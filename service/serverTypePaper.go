@@ -0,0 +1,196 @@
+// service/serverTypePaper.go
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var ErrBuildNotFound = errors.New("build not found")
+
+func getUpstreamJSON(url string, out any) error {
+	client := common.NewHTTPClient(30 * time.Second)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "carsupper665/mc-server-backend/"+common.Version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http get %s error: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", url, ErrBuildNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status from %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ---------------- Paper ----------------
+
+type paperProvider struct {
+	project string
+}
+
+func newPaperProvider() *paperProvider {
+	return &paperProvider{project: "paper"}
+}
+
+// apiBase 每次讀取設定，provider 在 LoadEnv 之前就已經註冊
+func (p *paperProvider) apiBase() string { return common.PaperAPIURL + "/v2" }
+
+func (p *paperProvider) Name() string     { return "Paper" }
+func (p *paperProvider) IDPrefix() string { return "mcspv-" }
+
+func (p *paperProvider) ListVersions() ([]string, error) {
+	var res struct {
+		Versions []string `json:"versions"`
+	}
+	if err := getUpstreamJSON(fmt.Sprintf("%s/projects/%s", p.apiBase(), p.project), &res); err != nil {
+		return nil, err
+	}
+	// API 回傳舊到新，反過來給前端
+	versions := make([]string, 0, len(res.Versions))
+	for i := len(res.Versions) - 1; i >= 0; i-- {
+		versions = append(versions, res.Versions[i])
+	}
+	return versions, nil
+}
+
+type paperBuild struct {
+	Build     int    `json:"build"`
+	Channel   string `json:"channel"`
+	Downloads struct {
+		Application struct {
+			Name   string `json:"name"`
+			SHA256 string `json:"sha256"`
+		} `json:"application"`
+	} `json:"downloads"`
+}
+
+// resolveBuild 沒指定 build 時取最新的 default channel build
+func (p *paperProvider) resolveBuild(version, build string) (*paperBuild, error) {
+	var res struct {
+		Builds []paperBuild `json:"builds"`
+	}
+	url := fmt.Sprintf("%s/projects/%s/versions/%s/builds", p.apiBase(), p.project, version)
+	if err := getUpstreamJSON(url, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Builds) == 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrBuildNotFound, p.project, version)
+	}
+
+	if build != "" {
+		n, err := strconv.Atoi(build)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidVersion, build)
+		}
+		for i := range res.Builds {
+			if res.Builds[i].Build == n {
+				return &res.Builds[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s %s build %s", ErrBuildNotFound, p.project, version, build)
+	}
+
+	for i := len(res.Builds) - 1; i >= 0; i-- {
+		if res.Builds[i].Channel == "default" {
+			return &res.Builds[i], nil
+		}
+	}
+	return &res.Builds[len(res.Builds)-1], nil
+}
+
+func (p *paperProvider) Artifact(version string, opts InstallOptions) (*ArtifactSpec, error) {
+	b, err := p.resolveBuild(version, opts.Build)
+	if err != nil {
+		return nil, err
+	}
+	build := strconv.Itoa(b.Build)
+	url := fmt.Sprintf("%s/projects/%s/versions/%s/builds/%d/downloads/%s",
+		p.apiBase(), p.project, version, b.Build, b.Downloads.Application.Name)
+	sum := b.Downloads.Application.SHA256
+	return &ArtifactSpec{
+		Key:    ArtifactKey{ServerType: p.Name(), Version: version, Loader: build, Name: "server.jar"},
+		Source: ArtifactSource{URL: url, HashAlgo: hashAlgoIf(sum, "sha256"), Hash: sum},
+		Loader: build,
+	}, nil
+}
+
+func (p *paperProvider) Install(workDir, version string, opts InstallOptions) (string, error) {
+	return provisionJar(p, workDir, version, opts)
+}
+
+func (p *paperProvider) LaunchArgs(workDir string) ([]string, error) {
+	return jarLaunchArgs(workDir, "server.jar")
+}
+
+// ---------------- Purpur ----------------
+
+type purpurProvider struct{}
+
+func newPurpurProvider() *purpurProvider {
+	return &purpurProvider{}
+}
+
+func (p *purpurProvider) apiBase() string { return common.PurpurAPIURL + "/v2/purpur" }
+
+func (p *purpurProvider) Name() string     { return "Purpur" }
+func (p *purpurProvider) IDPrefix() string { return "mcsuv-" }
+
+func (p *purpurProvider) ListVersions() ([]string, error) {
+	var res struct {
+		Versions []string `json:"versions"`
+	}
+	if err := getUpstreamJSON(p.apiBase(), &res); err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(res.Versions))
+	for i := len(res.Versions) - 1; i >= 0; i-- {
+		versions = append(versions, res.Versions[i])
+	}
+	return versions, nil
+}
+
+// Artifact 沒指定 build 時由 API 解析出 latest 實際的 build 編號，快取才不會拿到舊的
+func (p *purpurProvider) Artifact(version string, opts InstallOptions) (*ArtifactSpec, error) {
+	build := opts.Build
+	if build == "" {
+		build = "latest"
+	} else if _, err := strconv.Atoi(build); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidVersion, build)
+	}
+	var info struct {
+		Build string `json:"build"`
+		MD5   string `json:"md5"`
+	}
+	if err := getUpstreamJSON(fmt.Sprintf("%s/%s/%s", p.apiBase(), version, build), &info); err != nil {
+		return nil, err
+	}
+	if err := ValidateVersionString(info.Build); err != nil {
+		return nil, err
+	}
+	return &ArtifactSpec{
+		Key:    ArtifactKey{ServerType: p.Name(), Version: version, Loader: info.Build, Name: "server.jar"},
+		Source: ArtifactSource{URL: fmt.Sprintf("%s/%s/%s/download", p.apiBase(), version, info.Build), HashAlgo: hashAlgoIf(info.MD5, "md5"), Hash: info.MD5},
+		Loader: info.Build,
+	}, nil
+}
+
+func (p *purpurProvider) Install(workDir, version string, opts InstallOptions) (string, error) {
+	return provisionJar(p, workDir, version, opts)
+}
+
+func (p *purpurProvider) LaunchArgs(workDir string) ([]string, error) {
+	return jarLaunchArgs(workDir, "server.jar")
+}
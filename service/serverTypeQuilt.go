@@ -0,0 +1,120 @@
+// service/serverTypeQuilt.go
+
+package service
+
+import (
+	"fmt"
+	"go-backend/common"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type quiltProvider struct{}
+
+func quiltMetaBase() string { return common.QuiltMetaURL + "/v3" }
+
+func (p *quiltProvider) Name() string     { return "Quilt" }
+func (p *quiltProvider) IDPrefix() string { return "mcsqv-" }
+
+func (p *quiltProvider) ListVersions() ([]string, error) {
+	var gv []GameVersion
+	if err := getUpstreamJSON(quiltMetaBase()+"/versions/game", &gv); err != nil {
+		return nil, err
+	}
+	versions := make([]string, len(gv))
+	for i, v := range gv {
+		versions[i] = v.Version
+	}
+	return versions, nil
+}
+
+// latestQuiltLoader 取最新的非 beta loader
+func latestQuiltLoader() (string, error) {
+	var loaders []struct {
+		Version string `json:"version"`
+	}
+	if err := getUpstreamJSON(quiltMetaBase()+"/versions/loader", &loaders); err != nil {
+		return "", err
+	}
+	for _, l := range loaders {
+		if !strings.Contains(l.Version, "beta") {
+			return l.Version, nil
+		}
+	}
+	if len(loaders) > 0 {
+		return loaders[0].Version, nil
+	}
+	return "", fmt.Errorf("%w: no quilt loader", ErrBuildNotFound)
+}
+
+func latestQuiltInstaller() (string, string, error) {
+	var installers []struct {
+		URL     string `json:"url"`
+		Version string `json:"version"`
+	}
+	if err := getUpstreamJSON(quiltMetaBase()+"/versions/installer", &installers); err != nil {
+		return "", "", err
+	}
+	if len(installers) == 0 {
+		return "", "", fmt.Errorf("%w: no quilt installer", ErrBuildNotFound)
+	}
+	return installers[0].Version, installers[0].URL, nil
+}
+
+// Artifact quilt 快取的是 installer，vanilla server.jar 另外從 vanilla 的快取取得
+func (p *quiltProvider) Artifact(version string, opts InstallOptions) (*ArtifactSpec, error) {
+	loader := opts.Loader
+	if loader == "" {
+		var err error
+		if loader, err = latestQuiltLoader(); err != nil {
+			return nil, err
+		}
+	}
+	if err := ValidateVersionString(loader); err != nil {
+		return nil, err
+	}
+
+	installerVer, url, err := latestQuiltInstaller()
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateVersionString(installerVer); err != nil {
+		return nil, err
+	}
+	return &ArtifactSpec{
+		Key:    ArtifactKey{ServerType: p.Name(), Version: "installer", Loader: installerVer, Name: "quilt-installer.jar"},
+		Source: ArtifactSource{URL: url},
+		Loader: loader,
+	}, nil
+}
+
+func (p *quiltProvider) Install(workDir, version string, opts InstallOptions) (string, error) {
+	spec, err := p.Artifact(version, opts)
+	if err != nil {
+		return "", err
+	}
+	opts.Job.Step("downloading server.jar")
+	if err := DownloadVanillaServer(opts.Job, filepath.Join(workDir, "server.jar"), version); err != nil {
+		return "", fmt.Errorf("failed to provision vanilla server jar: %w", err)
+	}
+
+	installer := filepath.Join(workDir, "quilt-installer.jar")
+	opts.Job.Step("downloading quilt installer")
+	if err := ProvisionArtifact(opts.Job, spec, installer); err != nil {
+		return "", fmt.Errorf("failed to provision quilt installer: %w", err)
+	}
+	defer os.Remove(installer)
+
+	// quilt-server-launch.jar 預設會載入同目錄的 server.jar
+	err = runInstaller(opts.Job, workDir, "-jar", "quilt-installer.jar",
+		"install", "server", version, spec.Loader, "--install-dir=.")
+	if err != nil {
+		return "", err
+	}
+	return spec.Loader, nil
+}
+
+func (p *quiltProvider) LaunchArgs(workDir string) ([]string, error) {
+	return jarLaunchArgs(workDir, "quilt-server-launch.jar")
+}
@@ -0,0 +1,98 @@
+// service/serverTypeVanilla.go
+
+package service
+
+import (
+	"fmt"
+	"go-backend/common"
+)
+
+// ---------------- Vanilla ----------------
+
+type vanillaProvider struct{}
+
+func (p *vanillaProvider) Name() string     { return "Vanilla" }
+func (p *vanillaProvider) IDPrefix() string { return "mcsvv-" }
+
+func (p *vanillaProvider) ListVersions() ([]string, error) {
+	all, err := ListVanillaVersions("")
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, len(all))
+	for i, v := range all {
+		versions[i] = v.ID
+	}
+	return versions, nil
+}
+
+func (p *vanillaProvider) Artifact(version string, opts InstallOptions) (*ArtifactSpec, error) {
+	v, err := LookupVanillaVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	return &ArtifactSpec{
+		Key:    ArtifactKey{ServerType: p.Name(), Version: version, Name: "server.jar"},
+		Source: ArtifactSource{URL: v.ServerURL, HashAlgo: hashAlgoIf(v.ServerSHA1, "sha1"), Hash: v.ServerSHA1},
+	}, nil
+}
+
+func (p *vanillaProvider) Install(workDir, version string, opts InstallOptions) (string, error) {
+	return provisionJar(p, workDir, version, opts)
+}
+
+func (p *vanillaProvider) LaunchArgs(workDir string) ([]string, error) {
+	return jarLaunchArgs(workDir, "server.jar")
+}
+
+// ---------------- Fabric ----------------
+
+type fabricProvider struct{}
+
+func (p *fabricProvider) Name() string     { return "Fabric" }
+func (p *fabricProvider) IDPrefix() string { return "mcsfv-" }
+
+func (p *fabricProvider) ListVersions() ([]string, error) {
+	return GetAllFabricVersions()
+}
+
+func (p *fabricProvider) Artifact(version string, opts InstallOptions) (*ArtifactSpec, error) {
+	loader := opts.Loader
+	if loader == "" {
+		var err error
+		if loader, err = fabricMeta.LatestLoader(version); err != nil {
+			return nil, err
+		}
+	}
+	installer := opts.Installer
+	if installer == "" {
+		var err error
+		if installer, err = fabricMeta.LatestInstaller(); err != nil {
+			return nil, err
+		}
+	}
+	if err := ValidateVersionString(loader); err != nil {
+		return nil, err
+	}
+	if err := ValidateVersionString(installer); err != nil {
+		return nil, err
+	}
+
+	fURL := fmt.Sprintf(
+		"%s/v2/versions/loader/%s/%s/%s/server/jar",
+		common.FabricMetaURL, version, loader, installer,
+	)
+	return &ArtifactSpec{
+		Key:    ArtifactKey{ServerType: p.Name(), Version: version, Loader: loader + "_" + installer, Name: "server.jar"},
+		Source: ArtifactSource{URL: fURL},
+		Loader: loader,
+	}, nil
+}
+
+func (p *fabricProvider) Install(workDir, version string, opts InstallOptions) (string, error) {
+	return provisionJar(p, workDir, version, opts)
+}
+
+func (p *fabricProvider) LaunchArgs(workDir string) ([]string, error) {
+	return jarLaunchArgs(workDir, "server.jar")
+}
@@ -0,0 +1,212 @@
+// service/template.go
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrTemplateNotFound = errors.New("template not found")
+
+// 存成 template 時不複製的路徑，world 另外依 level-name 判斷
+var templateExcludes = map[string]bool{
+	"backup":         true,
+	"upgrade-backup": true,
+	"logs":           true,
+	"crash-reports":  true,
+}
+
+type SaveTemplateRequest struct {
+	Name        string            `json:"name" binding:"required"`
+	Description string            `json:"description"`
+	Properties  map[string]string `json:"properties"` // 建立 server 時覆寫的 server.properties 值
+}
+
+// TemplateInfo 給 API 回傳用，附上覆寫的設定值
+type TemplateInfo struct {
+	model.ServerTemplate
+	Properties map[string]string `json:"properties"`
+}
+
+func templateInfo(t *model.ServerTemplate) TemplateInfo {
+	info := TemplateInfo{ServerTemplate: *t, Properties: map[string]string{}}
+	if t.Properties != "" {
+		_ = json.Unmarshal([]byte(t.Properties), &info.Properties)
+	}
+	return info
+}
+
+// worldDirs server 的 world 資料夾 (含 nether / end)
+func worldDirs(workDir string) map[string]bool {
+	level := levelName(workDir)
+	return map[string]bool{
+		level:                true,
+		level + "_nether":    true,
+		level + "_the_end":   true,
+		level + ".restoring": true,
+	}
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func applyProperties(workDir string, props map[string]string) error {
+	if len(props) == 0 {
+		return nil
+	}
+	if err := UpdateProperties(workDir, props); err != nil {
+		return fmt.Errorf("failed to apply properties: %w", err)
+	}
+	return nil
+}
+
+func GetTemplate(ownerID, id uint) (*model.ServerTemplate, error) {
+	t, err := model.GetTemplate(ownerID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func ListTemplates(ownerID uint) ([]TemplateInfo, error) {
+	list, err := model.ListTemplates(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]TemplateInfo, len(list))
+	for i := range list {
+		infos[i] = templateInfo(&list[i])
+	}
+	return infos, nil
+}
+
+func DeleteTemplate(ownerID, id uint) error {
+	t, err := GetTemplate(ownerID, id)
+	if err != nil {
+		return err
+	}
+	if err := model.DeleteTemplate(ownerID, id); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(t.Path); err != nil {
+		common.SysError("failed to remove template files: " + err.Error())
+	}
+	return nil
+}
+
+// SaveTemplateJob 在背景把 server 的 jar、mods 與設定檔存成 template，world、備份與 log 不會複製
+func SaveTemplateJob(ownerID uint, rec *model.UserMinecraftServer, req SaveTemplateRequest) (*model.Job, error) {
+	info := ServerVersionOf(rec)
+	changes := make(map[string]any, len(req.Properties))
+	for k, v := range req.Properties {
+		changes[k] = v
+	}
+	normalized, err := ValidatePropertyChanges(info.MCVersion, changes)
+	if err != nil {
+		return nil, err
+	}
+	props, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, err
+	}
+	workDir := rec.SystemPath
+
+	return Jobs().Submit("template", rec.ServerID, ownerID, func(jc *JobContext) (any, error) {
+		dir := filepath.Join(common.TemplatePath, strconv.FormatUint(uint64(ownerID), 10),
+			strconv.FormatInt(time.Now().UnixNano(), 36))
+		worlds := worldDirs(workDir)
+
+		jc.Step("copying server files")
+		err := copyTreeFilter(jc, workDir, dir, func(rel string, _ os.FileInfo) bool {
+			return templateExcludes[rel] || worlds[rel]
+		})
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+
+		t := &model.ServerTemplate{
+			OwnerID:        ownerID,
+			Name:           req.Name,
+			Description:    req.Description,
+			ServerType:     info.ServerType,
+			MCVersion:      info.MCVersion,
+			LoaderVersion:  info.LoaderVersion,
+			SourceServerID: rec.ServerID,
+			Properties:     string(props),
+			Path:           dir,
+			Size:           dirSize(dir),
+		}
+		if err := model.CreateTemplate(t); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+		return templateInfo(t), nil
+	})
+}
+
+// copyIntoNewServer 建立新的 server ID 並複製 src，失敗時清掉新的資料夾
+func copyIntoNewServer(jc *JobContext, ownerID, serverType, mcVersion, src string, skip func(string, os.FileInfo) bool, props map[string]string) (string, error) {
+	provider, err := GetServerType(serverType)
+	if err != nil {
+		return "", err
+	}
+	serverID := newServerID(provider, mcVersion, ownerID)
+	sysPath := filepath.Join(common.MinecraftServerPath, serverID)
+
+	jc.Step("copying server files")
+	err = copyTreeFilter(jc, src, sysPath, skip)
+	if err == nil {
+		err = applyProperties(sysPath, props)
+	}
+	if err == nil {
+		err = writeWorkDirFile(sysPath, filepath.Join(sysPath, "eula.txt"), []byte("eula=true\n"), 0644)
+	}
+	if err != nil {
+		if clearErr := ErrorFileClear(sysPath); clearErr != nil {
+			common.SysLog(fmt.Sprintf("warning: %v", clearErr))
+		}
+		return "", err
+	}
+	return serverID, nil
+}
+
+// CreateServerFromTemplate 複製 template 的檔案到新的 server，並套用 template 的設定覆寫
+func CreateServerFromTemplate(jc *JobContext, ownerID string, t *model.ServerTemplate) (string, ServerVersionInfo, error) {
+	info := ServerVersionInfo{ServerType: t.ServerType, MCVersion: t.MCVersion, LoaderVersion: t.LoaderVersion}
+	props := templateInfo(t).Properties
+	serverID, err := copyIntoNewServer(jc, ownerID, t.ServerType, t.MCVersion, t.Path, nil, props)
+	return serverID, info, err
+}
+
+// DuplicateServer 複製整個 server (含 world) 到新的 server ID，port 會在啟動時重新分配
+func DuplicateServer(jc *JobContext, ownerID string, rec *model.UserMinecraftServer) (string, ServerVersionInfo, error) {
+	info := ServerVersionOf(rec)
+	serverID, err := copyIntoNewServer(jc, ownerID, info.ServerType, info.MCVersion, rec.SystemPath,
+		func(rel string, _ os.FileInfo) bool {
+			return filepath.Base(rel) == "session.lock" || strings.HasSuffix(rel, ".restoring")
+		}, nil)
+	return serverID, info, err
+}
@@ -0,0 +1,322 @@
+// service/tickPerf.go
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"go-backend/common"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	tickSourceCommand = "command"
+	tickSourceLag     = "lag-warnings"
+	defaultTPS        = 20.0
+	// 送出指令後這段時間內的 Unknown command 視為不支援
+	tickCommandWindow = 5 * time.Second
+	// 判定不支援後隔一段時間再試一次，例如之後才裝上提供指令的 plugin
+	tickCommandRetry = 30 * time.Minute
+	maxConsoleLine   = 64 << 10
+)
+
+var (
+	formatCodePattern = regexp.MustCompile(`§.|\x1b\[[0-9;]*[a-zA-Z]`)
+	// vanilla / Fabric / Forge: "[12:34:56] [Server thread/INFO]: ..."，Forge 另外帶 logger 名稱
+	threadLinePattern = regexp.MustCompile(`^\[[^\]]+\] \[([^\]]+)/[A-Z]+\](?: \[[^\]]*\])?: (.*)$`)
+	// Paper / Purpur: "[12:34:56 INFO]: ..."
+	paperLinePattern = regexp.MustCompile(`^\[\d{2}:\d{2}:\d{2} [A-Z]+\]: (.*)$`)
+	// 聊天、/me 與 /say，玩家可以控制後面的內容
+	chatPattern = regexp.MustCompile(`^(\[Not Secure\] )?<[^>]*>|^\* |^\[[^\]]*\] `)
+	// 以下都只比對訊息的開頭
+	serverDonePattern = regexp.MustCompile(`^Done \([\d.,]+s\)!`)
+	cantKeepUpPattern = regexp.MustCompile(`^Can't keep up!.*?(\d+)ms or (\d+) ticks behind`)
+	unknownCmdPattern = regexp.MustCompile(`^Unknown (or incomplete )?command`)
+	// Paper / Purpur: "TPS from last 1m, 5m, 15m: 20.0, 20.0, 20.0" (低於 20 以外可能帶 *)
+	paperTPSPattern  = regexp.MustCompile(`^TPS from last 1m, 5m, 15m: \*?([\d.]+)`)
+	paperMSPTHeader  = regexp.MustCompile(`^Server tick times`)
+	paperMSPTPattern = regexp.MustCompile(`^\S*\s*([\d.]+)/[\d.]+/[\d.]+, [\d.]+/[\d.]+/[\d.]+`)
+	// Forge: "Overall: Mean tick time: 1.234 ms. Mean TPS: 20.000"
+	forgeTPSPattern = regexp.MustCompile(`^Overall\s*:\s*Mean tick time:\s*([\d.]+) ms\. Mean TPS:\s*([\d.]+)`)
+	// NeoForge: "Overall: 20.000 TPS (1.234 ms/tick)"
+	neoForgeTPSPattern = regexp.MustCompile(`^Overall\s*:\s*([\d.]+) TPS \(([\d.]+) ms/tick\)`)
+	// 1.20.3 以後的 tick query，Average time per tick 是同一則訊息的第二行
+	targetRatePattern  = regexp.MustCompile(`^Target tick rate: ([\d.]+) per second`)
+	averageTickPattern = regexp.MustCompile(`^Average time per tick: ([\d.]+)ms`)
+)
+
+// tickCommands 依 server 類型查詢 TPS 的指令，其餘類型使用 tick query
+var tickCommands = map[string][]string{
+	"Paper":    {"tps", "mspt"},
+	"Purpur":   {"tps", "mspt"},
+	"Forge":    {"forge tps"},
+	"NeoForge": {"neoforge tps"},
+}
+
+// TickPerf 最近一次量到的 tick 效能，MSPT 為 -1 代表只能由延遲警告估算 TPS
+type TickPerf struct {
+	TPS      float64    `json:"tps"`
+	MSPT     float64    `json:"mspt"`
+	Source   string     `json:"source"`
+	At       time.Time  `json:"at"`
+	LowSince *time.Time `json:"low_since,omitempty"`
+	Lagging  bool       `json:"lagging"` // TPS 持續低於 TPS_LOW_THRESHOLD 超過 TPS_LOW_MINUTES
+}
+
+// tickMonitor 解析 console 輸出：指令回應、Done 與 "Can't keep up!" 警告
+type tickMonitor struct {
+	mu          sync.Mutex
+	sid         string
+	partial     []byte
+	ready       bool      // 已經看到 Done (...)!，之後才送指令
+	unsupported time.Time // 指令不存在 (例如 1.20.3 以前的 vanilla) 的時間
+	continued   bool      // 上一行是 server thread 的訊息，沒有前綴的行是它的下一行
+	sentAt      time.Time
+	answeredAt  time.Time
+	targetTPS   float64
+	msptNext    bool // Paper 的 mspt 數值在標題的下一行
+	lagTicks    int
+	windowStart time.Time
+	last        TickPerf
+	lowSince    time.Time
+	lagging     bool
+}
+
+func newTickMonitor(sid string) *tickMonitor {
+	return &tickMonitor{sid: sid, targetTPS: defaultTPS}
+}
+
+// Write 接在 server 的 stdout 後面，一行一行解析
+func (m *tickMonitor) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.partial = append(m.partial, p...)
+	for {
+		i := bytes.IndexByte(m.partial, '\n')
+		if i < 0 {
+			break
+		}
+		m.parseLine(string(bytes.TrimRight(m.partial[:i], "\r")), time.Now())
+		m.partial = m.partial[i+1:]
+	}
+	if len(m.partial) > maxConsoleLine {
+		m.partial = m.partial[:0]
+	}
+	return len(p), nil
+}
+
+// consoleMessage 取出 server thread 的訊息內容，聊天與其他 thread 的輸出 ok 為 false
+func (m *tickMonitor) consoleMessage(line string) (string, bool) {
+	if g := threadLinePattern.FindStringSubmatch(line); g != nil {
+		m.continued = g[1] == "Server thread" && !chatPattern.MatchString(g[2])
+		return g[2], m.continued
+	}
+	if g := paperLinePattern.FindStringSubmatch(line); g != nil {
+		m.continued = !chatPattern.MatchString(g[1])
+		return g[1], m.continued
+	}
+	// 聊天訊息不能換行，沒有前綴的行只會是前一則訊息的一部分
+	return line, m.continued
+}
+
+func (m *tickMonitor) parseLine(line string, now time.Time) {
+	line, ok := m.consoleMessage(formatCodePattern.ReplaceAllString(line, ""))
+	if !ok {
+		m.msptNext = false
+		return
+	}
+
+	if m.msptNext {
+		m.msptNext = false
+		if g := paperMSPTPattern.FindStringSubmatch(line); g != nil {
+			tps := m.last.TPS
+			if m.last.Source != tickSourceCommand || now.Sub(m.last.At) > tickCommandWindow {
+				tps = tpsFromMSPT(parseFloat(g[1]), m.targetTPS)
+			}
+			m.record(tps, parseFloat(g[1]), tickSourceCommand, now)
+			return
+		}
+	}
+
+	switch {
+	case serverDonePattern.MatchString(line):
+		m.ready = true
+		m.windowStart = now
+		m.lagTicks = 0
+	case cantKeepUpPattern.MatchString(line):
+		g := cantKeepUpPattern.FindStringSubmatch(line)
+		n, _ := strconv.Atoi(g[2])
+		m.lagTicks += n
+	case unknownCmdPattern.MatchString(line):
+		if now.Sub(m.sentAt) < tickCommandWindow && m.answeredAt.Before(m.sentAt) {
+			m.unsupported = now
+			common.SysLog("server " + m.sid + " has no tick command, estimating TPS from lag warnings")
+		}
+	case paperTPSPattern.MatchString(line):
+		g := paperTPSPattern.FindStringSubmatch(line)
+		m.record(parseFloat(g[1]), m.last.MSPT, tickSourceCommand, now)
+	case paperMSPTHeader.MatchString(line):
+		m.msptNext = true
+	case forgeTPSPattern.MatchString(line):
+		g := forgeTPSPattern.FindStringSubmatch(line)
+		m.record(parseFloat(g[2]), parseFloat(g[1]), tickSourceCommand, now)
+	case neoForgeTPSPattern.MatchString(line):
+		g := neoForgeTPSPattern.FindStringSubmatch(line)
+		m.record(parseFloat(g[1]), parseFloat(g[2]), tickSourceCommand, now)
+	case targetRatePattern.MatchString(line):
+		m.targetTPS = parseFloat(targetRatePattern.FindStringSubmatch(line)[1])
+	case averageTickPattern.MatchString(line):
+		mspt := parseFloat(averageTickPattern.FindStringSubmatch(line)[1])
+		m.record(tpsFromMSPT(mspt, m.targetTPS), mspt, tickSourceCommand, now)
+	}
+}
+
+// tpsFromMSPT 一個 tick 花的時間小於目標間隔時 TPS 就是目標值
+func tpsFromMSPT(mspt, target float64) float64 {
+	if target <= 0 {
+		target = defaultTPS
+	}
+	if mspt <= 0 || mspt <= 1000/target {
+		return target
+	}
+	return 1000 / mspt
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// record 更新最新數值，並判斷是否持續低於門檻
+func (m *tickMonitor) record(tps, mspt float64, source string, now time.Time) {
+	if source == tickSourceCommand {
+		m.answeredAt = now
+	}
+	if mspt == 0 {
+		mspt = -1
+	}
+	m.last = TickPerf{TPS: tps, MSPT: mspt, Source: source, At: now}
+
+	if tps >= float64(common.TPSLowThreshold) {
+		if m.lagging {
+			common.SysLog(fmt.Sprintf("server %s TPS recovered (%.1f)", m.sid, tps))
+		}
+		m.lowSince = time.Time{}
+		m.lagging = false
+		return
+	}
+	if m.lowSince.IsZero() {
+		m.lowSince = now
+	}
+	if !m.lagging && now.Sub(m.lowSince) >= time.Duration(common.TPSLowMinutes)*time.Minute {
+		m.lagging = true
+		common.SysLog(fmt.Sprintf("server %s TPS below %d for %d minutes (%.1f)", m.sid, common.TPSLowThreshold, common.TPSLowMinutes, tps))
+	}
+}
+
+// poll 送出查詢指令；沒有指令可用或沒有回應時，以這段期間落後的 tick 數估算 TPS
+func (m *tickMonitor) poll(srv *Server, now time.Time, interval time.Duration) {
+	m.mu.Lock()
+	if !m.ready {
+		m.mu.Unlock()
+		return
+	}
+	if !m.unsupported.IsZero() && now.Sub(m.unsupported) >= tickCommandRetry {
+		m.unsupported = time.Time{}
+	}
+	useCommand := common.TPSCommands && m.unsupported.IsZero()
+	silent := m.answeredAt.IsZero() || now.Sub(m.answeredAt) > 3*interval
+	if !useCommand || (silent && !m.sentAt.IsZero()) {
+		if !m.windowStart.IsZero() {
+			if window := now.Sub(m.windowStart).Seconds(); window >= 1 {
+				tps := defaultTPS - float64(m.lagTicks)/window
+				if tps < 0 {
+					tps = 0
+				}
+				m.record(tps, -1, tickSourceLag, now)
+			}
+		}
+	}
+	m.windowStart = now
+	m.lagTicks = 0
+	if useCommand {
+		m.sentAt = now
+	}
+	m.mu.Unlock()
+
+	if !useCommand {
+		return
+	}
+	commands := []string{"tick query"}
+	if provider, err := ServerTypeForID(srv.ID()); err == nil {
+		if list, ok := tickCommands[provider.Name()]; ok {
+			commands = list
+		}
+	}
+	for _, cmd := range commands {
+		if err := srv.SendCommand(cmd); err != nil {
+			return
+		}
+	}
+}
+
+func (m *tickMonitor) snapshot() (TickPerf, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last.At.IsZero() {
+		return TickPerf{}, false
+	}
+	perf := m.last
+	if !m.lowSince.IsZero() {
+		since := m.lowSince
+		perf.LowSince = &since
+	}
+	perf.Lagging = m.lagging
+	return perf, true
+}
+
+// StartTickMonitor 每 interval 查詢一次執行中 server 的 TPS
+func (s *ServerService) StartTickMonitor(interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+			now := time.Now()
+			for _, srv := range s.mgr.runningServers() {
+				if m := srv.tickMonitor(); m != nil {
+					m.poll(srv, now, interval)
+				}
+			}
+		}
+	}()
+}
+
+// TickPerf 執行中的 server 最近一次的 TPS / MSPT
+func (s *ServerService) TickPerf(sid string) (TickPerf, bool) {
+	srv, ok := s.mgr.get(sid)
+	if !ok || srv.Status() != "running" {
+		return TickPerf{}, false
+	}
+	if m := srv.tickMonitor(); m != nil {
+		return m.snapshot()
+	}
+	return TickPerf{}, false
+}
+
+type LaggingServer struct {
+	ServerID string   `json:"server_id"`
+	Perf     TickPerf `json:"performance"`
+}
+
+// LaggingServers TPS 持續低於門檻的 server
+func (s *ServerService) LaggingServers() []LaggingServer {
+	list := []LaggingServer{}
+	for _, srv := range s.mgr.runningServers() {
+		if perf, ok := s.TickPerf(srv.ID()); ok && perf.Lagging {
+			list = append(list, LaggingServer{ServerID: srv.ID(), Perf: perf})
+		}
+	}
+	return list
+}
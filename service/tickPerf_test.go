@@ -0,0 +1,129 @@
+// service/tickPerf_test.go
+
+package service
+
+import (
+	"go-backend/common"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTickMonitorParseLine(t *testing.T) {
+	common.TPSLowThreshold = 15
+	common.TPSLowMinutes = 5
+
+	tests := []struct {
+		name     string
+		lines    []string
+		wantTPS  float64
+		wantMSPT float64
+		wantOK   bool
+	}{
+		{
+			name:     "vanilla tick query",
+			lines:    []string{"[12:00:00] [Server thread/INFO]: Target tick rate: 20.0 per second.", "Average time per tick: 62.5ms (Target: 50.0ms)"},
+			wantTPS:  16,
+			wantMSPT: 62.5,
+			wantOK:   true,
+		},
+		{
+			name:     "vanilla tick query under target",
+			lines:    []string{"[12:00:00] [Server thread/INFO]: Target tick rate: 20.0 per second.", "Average time per tick: 12.3ms (Target: 50.0ms)"},
+			wantTPS:  20,
+			wantMSPT: 12.3,
+			wantOK:   true,
+		},
+		{
+			name:     "paper tps and mspt",
+			lines:    []string{"[12:00:00 INFO]: §6TPS from last 1m, 5m, 15m: §a*19.5, §a20.0, §a20.0", "[12:00:00 INFO]: Server tick times (avg/min/max) from last 5s, 10s, 1m:", "[12:00:00 INFO]: ◴ 12.1/3.0/40.2, 11.0/2.1/45.0, 10.2/2.0/50.1"},
+			wantTPS:  19.5,
+			wantMSPT: 12.1,
+			wantOK:   true,
+		},
+		{
+			name:     "forge",
+			lines:    []string{"[12:00:00] [Server thread/INFO] [minecraft/TpsCommand]: Overall: Mean tick time: 45.123 ms. Mean TPS: 19.850"},
+			wantTPS:  19.85,
+			wantMSPT: 45.123,
+			wantOK:   true,
+		},
+		{
+			name:     "neoforge",
+			lines:    []string{"[12:00:00] [Server thread/INFO] [minecraft/TpsCommand]: Overall: 18.500 TPS (54.054 ms/tick)"},
+			wantTPS:  18.5,
+			wantMSPT: 54.054,
+			wantOK:   true,
+		},
+		{
+			name:  "chat is ignored",
+			lines: []string{"[12:00:00] [Server thread/INFO]: <Steve> Overall: 1.000 TPS (999.0 ms/tick)"},
+		},
+		{
+			name:  "say is ignored",
+			lines: []string{"[12:00:00 INFO]: [Steve] TPS from last 1m, 5m, 15m: 1.0, 1.0, 1.0"},
+		},
+		{
+			name:  "other threads are ignored",
+			lines: []string{"[12:00:00] [User Authenticator #1/INFO]: Average time per tick: 999.0ms"},
+		},
+		{
+			name:  "continuation of chat is ignored",
+			lines: []string{"[12:00:00] [Server thread/INFO]: <Steve> hi", "Average time per tick: 999.0ms"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTickMonitor("test")
+			now := time.Now()
+			for _, line := range tt.lines {
+				m.parseLine(line, now)
+			}
+			perf, ok := m.snapshot()
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (%+v)", ok, tt.wantOK, perf)
+			}
+			if !ok {
+				return
+			}
+			if math.Abs(perf.TPS-tt.wantTPS) > 0.001 || math.Abs(perf.MSPT-tt.wantMSPT) > 0.001 {
+				t.Fatalf("got TPS %v MSPT %v, want %v %v", perf.TPS, perf.MSPT, tt.wantTPS, tt.wantMSPT)
+			}
+		})
+	}
+}
+
+func TestTickMonitorLagWarnings(t *testing.T) {
+	common.TPSLowThreshold = 15
+	common.TPSLowMinutes = 5
+	common.TPSCommands = false
+
+	m := newTickMonitor("test")
+	start := time.Now()
+	m.parseLine("[12:00:00] [Server thread/INFO]: Done (3.123s)! For help, type \"help\"", start)
+	m.parseLine("[12:00:10] [Server thread/WARN]: Can't keep up! Is the server overloaded? Running 5000ms or 100 ticks behind", start)
+	m.parseLine("[12:00:10] [Server thread/INFO]: <Steve> Can't keep up! Running 5000ms or 900 ticks behind", start)
+
+	m.poll(nil, start.Add(20*time.Second), 20*time.Second)
+	perf, ok := m.snapshot()
+	if !ok {
+		t.Fatal("no perf recorded")
+	}
+	if perf.Source != tickSourceLag || perf.MSPT != -1 || math.Abs(perf.TPS-15) > 0.001 {
+		t.Fatalf("got %+v", perf)
+	}
+	if perf.Lagging {
+		t.Fatal("lagging before TPS_LOW_MINUTES")
+	}
+
+	// 持續低於門檻超過 TPS_LOW_MINUTES 才標記
+	m.record(10, -1, tickSourceLag, start.Add(time.Minute))
+	m.record(10, -1, tickSourceLag, start.Add(7*time.Minute))
+	if perf, _ := m.snapshot(); !perf.Lagging || perf.LowSince == nil {
+		t.Fatalf("expected lagging, got %+v", perf)
+	}
+	m.record(20, -1, tickSourceLag, start.Add(8*time.Minute))
+	if perf, _ := m.snapshot(); perf.Lagging || perf.LowSince != nil {
+		t.Fatalf("expected recovered, got %+v", perf)
+	}
+}
@@ -176,9 +176,9 @@ func CheckForUpdates() error {
 
 // fetchLatestRelease 獲取最新版本資訊
 func fetchLatestRelease(logger *UpdateLogger) (*release, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo)
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", common.GitHubAPIURL, repo)
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := common.NewHTTPClient(30 * time.Second)
 	resp, err := client.Get(url)
 	if err != nil {
 		logger.Error("failed to fetch release info: " + err.Error())
@@ -204,7 +204,7 @@ func fetchLatestRelease(logger *UpdateLogger) (*release, error) {
 func downloadAndApplyUpdate(url, expectedHash string, logger *UpdateLogger) error {
 	logger.Info("Downloading update...")
 
-	client := &http.Client{Timeout: 30 * time.Minute}
+	client := common.NewHTTPClient(30 * time.Minute)
 	resp, err := client.Get(url)
 	if err != nil {
 		logger.Error("failed to download: " + err.Error())
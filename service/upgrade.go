@@ -0,0 +1,275 @@
+// service/upgrade.go
+
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUpgradeWhileRunning = errors.New("Cannot upgrade while server is running")
+	ErrSameVersion         = errors.New("server is already on this version")
+	ErrDowngrade           = errors.New("downgrading a world is not supported")
+)
+
+// 升級時會先搬到 BACKUP_PATH/<server_id>/upgrade 的啟動檔，失敗時搬回來
+var upgradeLaunchFiles = []string{"server.jar", "quilt-server-launch.jar"}
+
+// ServerVersionInfo 目前 server 的類型、minecraft 版本與 loader / build 版本
+type ServerVersionInfo struct {
+	ServerType    string `json:"server_type"`
+	MCVersion     string `json:"mc_version"`
+	LoaderVersion string `json:"loader_version"`
+}
+
+// ServerVersionOf 以資料庫欄位為主，舊資料沒有欄位時退回從 server ID 解析
+func ServerVersionOf(rec *model.UserMinecraftServer) ServerVersionInfo {
+	info := ServerVersionInfo{
+		ServerType:    rec.ServerType,
+		MCVersion:     rec.MCVersion,
+		LoaderVersion: rec.LoaderVersion,
+	}
+	if info.ServerType == "" || info.MCVersion == "" {
+		info.ServerType, info.MCVersion = ParseServerID(rec.ServerID)
+	}
+	return info
+}
+
+// ServerVersionByID 給沒有 owner 資訊的內部流程使用 (例如啟動前檢查 mod)
+func ServerVersionByID(sid string) ServerVersionInfo {
+	rec, err := model.GetServerRecord(sid)
+	if err != nil {
+		serverType, mcVer := ParseServerID(sid)
+		return ServerVersionInfo{ServerType: serverType, MCVersion: mcVer}
+	}
+	return ServerVersionOf(rec)
+}
+
+// BackfillServerVersions 補上舊資料缺少的類型與版本欄位
+func BackfillServerVersions() error {
+	servers, err := model.GetServersMissingVersion()
+	if err != nil {
+		return err
+	}
+	for _, s := range servers {
+		serverType, mcVer := ParseServerID(s.ServerID)
+		if serverType == "" {
+			continue
+		}
+		loader := s.LoaderVersion
+		if loader == "" && serverType == "Fabric" {
+			loader = ReadFabricLoaderVersion(s.SystemPath)
+		}
+		if err := model.UpdateServerVersion(s.ServerID, serverType, mcVer, loader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpgradeRequest 升級目標，Loader / Build 留空則使用最新版
+type UpgradeRequest struct {
+	Version string `json:"version" binding:"required"`
+	Loader  string `json:"loader"`
+	Build   string `json:"build"`
+	Force   bool   `json:"force"` // 有 mod 相容性警告時仍要升級
+}
+
+type UpgradeResult struct {
+	From          ServerVersionInfo `json:"from"`
+	To            ServerVersionInfo `json:"to"`
+	Backup        string            `json:"backup"`
+	WorldVersion  int               `json:"world_version"`
+	TargetVersion int               `json:"target_world_version"`
+	Warnings      []string          `json:"warnings"`
+}
+
+// UpgradeWarningError 有 mod 警告且沒有指定 force 時回傳，不會做任何變更
+type UpgradeWarningError struct {
+	Warnings []string
+}
+
+func (e *UpgradeWarningError) Error() string {
+	return fmt.Sprintf("upgrade has %d warning(s), set force to continue: %s", len(e.Warnings), strings.Join(e.Warnings, "; "))
+}
+
+// vanillaWorldVersion 下載目標版本的 vanilla server.jar，讀 version.json 內的 world_version (DataVersion)
+// 1.14 以前沒有 version.json，回傳 0
+func vanillaWorldVersion(jc *JobContext, version string) (int, error) {
+	if _, err := LookupVanillaVersion(version); err != nil {
+		return 0, nil
+	}
+	tmp, err := os.CreateTemp("", "mc-upgrade-*.jar")
+	if err != nil {
+		return 0, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := DownloadVanillaServer(jc, tmp.Name(), version); err != nil {
+		return 0, err
+	}
+	zr, err := zip.OpenReader(tmp.Name())
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.Name != "version.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return 0, err
+		}
+		defer rc.Close()
+		var v struct {
+			WorldVersion int `json:"world_version"`
+		}
+		if err := json.NewDecoder(rc).Decode(&v); err != nil {
+			return 0, err
+		}
+		return v.WorldVersion, nil
+	}
+	return 0, nil
+}
+
+// upgradeModWarnings 列出宣告的 minecraft 版本範圍不包含目標版本的 mod
+func upgradeModWarnings(workDir, target string) []string {
+	warnings := make([]string, 0)
+	mods, err := ListMods(workDir)
+	if err != nil {
+		return warnings
+	}
+	for _, m := range mods {
+		if !m.Enabled {
+			continue
+		}
+		req, ok := m.Depends["minecraft"]
+		if !ok || matchRequirement(req, target) {
+			continue
+		}
+		name := m.Name
+		if name == "" {
+			name = m.FileName
+		}
+		warnings = append(warnings, fmt.Sprintf("%s requires minecraft %s", name, req))
+	}
+	return warnings
+}
+
+// restoreLaunchFiles 安裝失敗時把備份的啟動檔搬回來
+func restoreLaunchFiles(workDir, backupDir string) {
+	for _, name := range upgradeLaunchFiles {
+		src := filepath.Join(backupDir, name)
+		if _, err := os.Lstat(src); err != nil {
+			continue
+		}
+		if err := moveFile(src, filepath.Join(workDir, name)); err != nil {
+			common.SysError("failed to restore " + name + ": " + err.Error())
+		}
+	}
+}
+
+// CheckUpgrade 不需要下載的檢查，建立 job 前先做一次讓錯誤直接回給 client
+func (sm *ServerManager) CheckUpgrade(sid, workDir string, req UpgradeRequest) error {
+	if sm.IsRunning(sid) {
+		return ErrUpgradeWhileRunning
+	}
+	if err := ValidateVersionString(req.Version); err != nil {
+		return err
+	}
+	from := ServerVersionByID(sid)
+	if _, err := GetServerType(from.ServerType); err != nil {
+		return err
+	}
+	if from.MCVersion == req.Version && (req.Loader == "" || req.Loader == from.LoaderVersion) && req.Build == "" {
+		return ErrSameVersion
+	}
+	if warnings := upgradeModWarnings(workDir, req.Version); len(warnings) > 0 && !req.Force {
+		return &UpgradeWarningError{Warnings: warnings}
+	}
+	return nil
+}
+
+// UpgradeServer 將已停止的 server 升級到新的 minecraft 版本
+// 流程：mod 相容性警告 -> 檢查 world 版本避免降級 -> 備份 world 與啟動檔 -> 安裝新版本
+func (sm *ServerManager) UpgradeServer(jc *JobContext, sid, workDir string, req UpgradeRequest) (*UpgradeResult, error) {
+	if err := sm.CheckUpgrade(sid, workDir, req); err != nil {
+		return nil, err
+	}
+	from := ServerVersionByID(sid)
+	provider, err := GetServerType(from.ServerType)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpgradeResult{From: from, Warnings: upgradeModWarnings(workDir, req.Version)}
+
+	// world 的 DataVersion 只能往上升
+	worldDir := filepath.Join(workDir, levelName(workDir))
+	result.WorldVersion, err = ReadLevelDataVersion(filepath.Join(worldDir, "level.dat"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read level.dat: %w", err)
+	}
+	if result.WorldVersion > 0 {
+		jc.Step("checking world version")
+		result.TargetVersion, err = vanillaWorldVersion(jc, req.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get target world version: %w", err)
+		}
+	}
+	if result.TargetVersion > 0 {
+		if result.TargetVersion < result.WorldVersion {
+			return nil, fmt.Errorf("%w: world %d, target %d", ErrDowngrade, result.WorldVersion, result.TargetVersion)
+		}
+	} else if CompareVersions(req.Version, from.MCVersion) < 0 {
+		// 查不到 DataVersion 時退回比較版本字串
+		return nil, fmt.Errorf("%w: %s -> %s", ErrDowngrade, from.MCVersion, req.Version)
+	}
+
+	// 備份 world，沒有 world (從未啟動過) 就跳過
+	if _, err := os.Stat(worldDir); err == nil {
+		if _, err := sm.BackUp(jc, sid, workDir); err != nil {
+			return nil, fmt.Errorf("failed to backup world: %w", err)
+		}
+	}
+
+	result.Backup = from.MCVersion + "_" + time.Now().Format("20060102_150405")
+	backupDir := filepath.Join(upgradeBackupDir(sid), result.Backup)
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return nil, err
+	}
+	for _, name := range upgradeLaunchFiles {
+		src := filepath.Join(workDir, name)
+		if info, err := os.Lstat(src); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if err := moveFile(src, filepath.Join(backupDir, name)); err != nil {
+			restoreLaunchFiles(workDir, backupDir)
+			return nil, fmt.Errorf("failed to backup %s: %w", name, err)
+		}
+	}
+
+	loader, err := provider.Install(workDir, req.Version, InstallOptions{Loader: req.Loader, Build: req.Build, Job: jc})
+	if err != nil {
+		restoreLaunchFiles(workDir, backupDir)
+		return nil, err
+	}
+
+	result.To = ServerVersionInfo{ServerType: from.ServerType, MCVersion: req.Version, LoaderVersion: loader}
+	if err := model.UpdateServerVersion(sid, result.To.ServerType, result.To.MCVersion, result.To.LoaderVersion); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
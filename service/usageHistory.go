@@ -0,0 +1,388 @@
+// service/usageHistory.go
+
+package service
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/model"
+	"sync"
+	"time"
+)
+
+const (
+	UsageRaw  = "raw"
+	UsageAuto = "auto"
+)
+
+var (
+	ErrUsageRange      = errors.New("invalid time range")
+	ErrUsageResolution = errors.New("resolution must be raw, 1m, 1h or auto")
+)
+
+var usageResolutions = map[string]time.Duration{
+	model.UsageMinute: time.Minute,
+	model.UsageHour:   time.Hour,
+}
+
+// UsageSample 記憶體中的原始樣本，Players / TPS / MSPT 為 -1 代表沒有資料
+type UsageSample struct {
+	At      time.Time `json:"t"`
+	CPU     float64   `json:"cpu"`
+	RSS     uint64    `json:"rss"`
+	Threads int32     `json:"threads"`
+	Players int       `json:"players"`
+	TPS     float64   `json:"tps"`
+	MSPT    float64   `json:"mspt"`
+}
+
+type UsageStat struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// UsagePoint 查詢結果的一個點，原始樣本的 min / avg / max 相同
+type UsagePoint struct {
+	At      time.Time  `json:"t"`
+	Samples int        `json:"samples"`
+	CPU     UsageStat  `json:"cpu"`
+	RSS     UsageStat  `json:"rss"`
+	Threads UsageStat  `json:"threads"`
+	Players *UsageStat `json:"players"` // 沒有人數資料時為 null
+	TPS     *UsageStat `json:"tps"`
+	MSPT    *UsageStat `json:"mspt"`
+}
+
+type statAcc struct {
+	n             int
+	min, max, sum float64
+}
+
+func (a *statAcc) add(v float64) {
+	if a.n == 0 || v < a.min {
+		a.min = v
+	}
+	if a.n == 0 || v > a.max {
+		a.max = v
+	}
+	a.sum += v
+	a.n++
+}
+
+// values 沒有資料時回傳 -1
+func (a *statAcc) values() (float64, float64, float64) {
+	if a.n == 0 {
+		return -1, -1, -1
+	}
+	return a.min, a.sum / float64(a.n), a.max
+}
+
+type usageBucket struct {
+	start                      time.Time
+	samples                    int
+	cpu, rss, threads, players statAcc
+	tps, mspt                  statAcc
+}
+
+func (b *usageBucket) add(s UsageSample) {
+	b.samples++
+	b.cpu.add(s.CPU)
+	b.rss.add(float64(s.RSS))
+	b.threads.add(float64(s.Threads))
+	if s.Players >= 0 {
+		b.players.add(float64(s.Players))
+	}
+	if s.TPS >= 0 {
+		b.tps.add(s.TPS)
+	}
+	if s.MSPT >= 0 {
+		b.mspt.add(s.MSPT)
+	}
+}
+
+func (b *usageBucket) aggregate(sid, resolution string) model.UsageAggregate {
+	a := model.UsageAggregate{ServerID: sid, Resolution: resolution, BucketStart: b.start, Samples: b.samples}
+	a.CPUMin, a.CPUAvg, a.CPUMax = b.cpu.values()
+	a.RSSMin, a.RSSAvg, a.RSSMax = b.rss.values()
+	a.ThreadsMin, a.ThreadsAvg, a.ThreadsMax = b.threads.values()
+	a.PlayersMin, a.PlayersAvg, a.PlayersMax = b.players.values()
+	a.TPSMin, a.TPSAvg, a.TPSMax = b.tps.values()
+	a.MSPTMin, a.MSPTAvg, a.MSPTMax = b.mspt.values()
+	return a
+}
+
+type usageRing struct {
+	buf  []UsageSample
+	next int
+	full bool
+}
+
+func (r *usageRing) add(s UsageSample) {
+	r.buf[r.next] = s
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// between 依時間排序，回傳 [from, to) 內的樣本
+func (r *usageRing) between(from, to time.Time) []UsageSample {
+	ordered := r.buf[:r.next]
+	if r.full {
+		ordered = append(append([]UsageSample{}, r.buf[r.next:]...), r.buf[:r.next]...)
+	}
+	var out []UsageSample
+	for _, s := range ordered {
+		if !s.At.Before(from) && s.At.Before(to) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// usageRecorder 最近的原始樣本放在 ring，同時累積 1 分鐘與 1 小時的統計，時段結束才寫入資料庫
+type usageRecorder struct {
+	mu      sync.Mutex
+	rings   map[string]*usageRing
+	buckets map[string]map[string]*usageBucket // sid -> resolution -> 進行中的時段
+	pruned  time.Time
+}
+
+var usageRec = &usageRecorder{
+	rings:   make(map[string]*usageRing),
+	buckets: make(map[string]map[string]*usageBucket),
+}
+
+// record 回傳這次樣本之前已經結束的時段
+func (r *usageRecorder) record(sid string, s UsageSample) []model.UsageAggregate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ring, ok := r.rings[sid]
+	if !ok {
+		size := common.UsageRingSize
+		if size <= 0 {
+			size = 1
+		}
+		ring = &usageRing{buf: make([]UsageSample, size)}
+		r.rings[sid] = ring
+	}
+	ring.add(s)
+
+	if r.buckets[sid] == nil {
+		r.buckets[sid] = make(map[string]*usageBucket)
+	}
+	var done []model.UsageAggregate
+	for res, d := range usageResolutions {
+		start := s.At.Truncate(d)
+		b := r.buckets[sid][res]
+		if b != nil && !b.start.Equal(start) {
+			done = append(done, b.aggregate(sid, res))
+			b = nil
+		}
+		if b == nil {
+			b = &usageBucket{start: start}
+			r.buckets[sid][res] = b
+		}
+		b.add(s)
+	}
+	return done
+}
+
+// flushEnded 已經停止的 server 不會再有樣本，時段結束後直接寫入
+func (r *usageRecorder) flushEnded(now time.Time) []model.UsageAggregate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var done []model.UsageAggregate
+	for sid, byRes := range r.buckets {
+		for res, b := range byRes {
+			if !b.start.Add(usageResolutions[res]).After(now) {
+				done = append(done, b.aggregate(sid, res))
+				delete(byRes, res)
+			}
+		}
+	}
+	return done
+}
+
+func (r *usageRecorder) prune(now time.Time) {
+	r.mu.Lock()
+	if now.Sub(r.pruned) < time.Hour {
+		r.mu.Unlock()
+		return
+	}
+	r.pruned = now
+	r.mu.Unlock()
+
+	retention := map[string]int{
+		model.UsageMinute: common.UsageMinuteRetentionDays,
+		model.UsageHour:   common.UsageHourRetentionDays,
+	}
+	for res, days := range retention {
+		if err := model.PruneUsageAggregates(res, now.AddDate(0, 0, -days)); err != nil {
+			common.SysError("failed to prune usage history: " + err.Error())
+		}
+	}
+}
+
+func (r *usageRecorder) samples(sid string, from, to time.Time) []UsageSample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ring, ok := r.rings[sid]; ok {
+		return ring.between(from, to)
+	}
+	return nil
+}
+
+// partial 進行中的時段，查詢時一併回傳讓圖表延伸到現在
+func (r *usageRecorder) partial(sid, resolution string) *model.UsageAggregate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b := r.buckets[sid][resolution]; b != nil {
+		a := b.aggregate(sid, resolution)
+		return &a
+	}
+	return nil
+}
+
+// forget server 被刪除後丟掉記憶體中的資料
+func (r *usageRecorder) forget(sid string) {
+	r.mu.Lock()
+	delete(r.rings, sid)
+	delete(r.buckets, sid)
+	r.mu.Unlock()
+}
+
+// StartUsageRecorder 每 interval 從 Monitor 的最新 Snapshot 取樣
+func (s *ServerService) StartUsageRecorder(interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+			now := time.Now()
+			var done []model.UsageAggregate
+			for _, srv := range s.mgr.runningServers() {
+				sample, ok := usageSampleOf(srv, now, interval)
+				if !ok {
+					continue
+				}
+				done = append(done, usageRec.record(srv.ID(), sample)...)
+			}
+			done = append(done, usageRec.flushEnded(now)...)
+			if err := model.CreateUsageAggregates(done); err != nil {
+				common.SysError("failed to save usage history: " + err.Error())
+			}
+			usageRec.prune(now)
+		}
+	}()
+}
+
+// usageSampleOf Snapshot 太舊 (Monitor 已停止) 時不取樣
+func usageSampleOf(srv *Server, now time.Time, interval time.Duration) (UsageSample, bool) {
+	snap, ok := srv.GetLatestSnapshot()
+	if !ok || !snap.Running || now.Sub(snap.At) > 2*interval+5*time.Second {
+		return UsageSample{}, false
+	}
+	sample := UsageSample{At: now, CPU: snap.CPU, RSS: snap.RSS, Threads: snap.Threads, Players: -1, TPS: -1, MSPT: -1}
+	// 人數由閒置檢查的 ping 取得
+	maxAge := 3 * time.Duration(common.IdleCheckInterval) * time.Second
+	if online, at := srv.Players(); !at.IsZero() && now.Sub(at) <= maxAge {
+		sample.Players = online
+	}
+	if m := srv.tickMonitor(); m != nil {
+		maxAge := 3 * time.Duration(common.TPSPollInterval) * time.Second
+		if perf, ok := m.snapshot(); ok && now.Sub(perf.At) <= maxAge {
+			sample.TPS = perf.TPS
+			sample.MSPT = perf.MSPT
+		}
+	}
+	return sample, true
+}
+
+// UsageHistory resolution 為 auto 時依範圍選擇：1 小時內用原始樣本，2 天內用 1 分鐘，其餘用 1 小時
+func UsageHistory(sid string, from, to time.Time, resolution string) ([]UsagePoint, string, error) {
+	if !from.Before(to) {
+		return nil, "", ErrUsageRange
+	}
+	if resolution == "" || resolution == UsageAuto {
+		switch span := to.Sub(from); {
+		case span <= time.Hour:
+			resolution = UsageRaw
+		case span <= 48*time.Hour:
+			resolution = model.UsageMinute
+		default:
+			resolution = model.UsageHour
+		}
+	}
+
+	if resolution == UsageRaw {
+		samples := usageRec.samples(sid, from, to)
+		points := make([]UsagePoint, 0, len(samples))
+		for _, s := range samples {
+			points = append(points, samplePoint(s))
+		}
+		return points, resolution, nil
+	}
+	if _, ok := usageResolutions[resolution]; !ok {
+		return nil, "", ErrUsageResolution
+	}
+
+	list, err := model.ListUsageAggregates(sid, resolution, from, to)
+	if err != nil {
+		return nil, "", err
+	}
+	if p := usageRec.partial(sid, resolution); p != nil && !p.BucketStart.Before(from) && p.BucketStart.Before(to) {
+		list = append(list, *p)
+	}
+	points := make([]UsagePoint, 0, len(list))
+	for _, a := range list {
+		points = append(points, aggregatePoint(a))
+	}
+	return points, resolution, nil
+}
+
+func samplePoint(s UsageSample) UsagePoint {
+	p := UsagePoint{
+		At:      s.At,
+		Samples: 1,
+		CPU:     UsageStat{s.CPU, s.CPU, s.CPU},
+		RSS:     UsageStat{float64(s.RSS), float64(s.RSS), float64(s.RSS)},
+		Threads: UsageStat{float64(s.Threads), float64(s.Threads), float64(s.Threads)},
+	}
+	if s.Players >= 0 {
+		v := float64(s.Players)
+		p.Players = &UsageStat{v, v, v}
+	}
+	if s.TPS >= 0 {
+		p.TPS = &UsageStat{s.TPS, s.TPS, s.TPS}
+	}
+	if s.MSPT >= 0 {
+		p.MSPT = &UsageStat{s.MSPT, s.MSPT, s.MSPT}
+	}
+	return p
+}
+
+func aggregatePoint(a model.UsageAggregate) UsagePoint {
+	p := UsagePoint{
+		At:      a.BucketStart,
+		Samples: a.Samples,
+		CPU:     UsageStat{a.CPUMin, a.CPUAvg, a.CPUMax},
+		RSS:     UsageStat{a.RSSMin, a.RSSAvg, a.RSSMax},
+		Threads: UsageStat{a.ThreadsMin, a.ThreadsAvg, a.ThreadsMax},
+	}
+	if a.PlayersMax >= 0 {
+		p.Players = &UsageStat{a.PlayersMin, a.PlayersAvg, a.PlayersMax}
+	}
+	if a.TPSMax >= 0 {
+		p.TPS = &UsageStat{a.TPSMin, a.TPSAvg, a.TPSMax}
+	}
+	if a.MSPTMax >= 0 {
+		p.MSPT = &UsageStat{a.MSPTMin, a.MSPTAvg, a.MSPTMax}
+	}
+	return p
+}
+
+// ForgetUsageHistory server 被刪除後丟掉記憶體中的樣本
+func ForgetUsageHistory(sid string) {
+	usageRec.forget(sid)
+}
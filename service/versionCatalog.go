@@ -0,0 +1,214 @@
+// service/versionCatalog.go
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrVersionNotFound    = errors.New("minecraft version not found")
+	ErrInvalidVersionType = errors.New("invalid version type")
+)
+
+// 同步時同時抓取 version json 的數量
+const catalogSyncWorkers = 8
+
+var catalogSyncMu sync.Mutex
+
+var releasePattern = regexp.MustCompile(`^\d+\.\d+(\.\d+)?$`)
+
+type versionManifest struct {
+	Versions []struct {
+		ID          string    `json:"id"`
+		Type        string    `json:"type"`
+		URL         string    `json:"url"`
+		ReleaseTime time.Time `json:"releaseTime"`
+		SHA1        string    `json:"sha1"`
+	} `json:"versions"`
+}
+
+type versionMeta struct {
+	Downloads struct {
+		Server *struct {
+			SHA1 string `json:"sha1"`
+			Size int64  `json:"size"`
+			URL  string `json:"url"`
+		} `json:"server"`
+	} `json:"downloads"`
+	JavaVersion struct {
+		MajorVersion int `json:"majorVersion"`
+	} `json:"javaVersion"`
+}
+
+// SyncVersionCatalog 同步 version manifest，只重新抓 sha1 有變動或新的版本，回傳更新數量
+func SyncVersionCatalog() (int, error) {
+	return syncVersionCatalog(false)
+}
+
+// syncVersionCatalog full 為 true 時每個版本都重新抓 (填充 mirror 用)
+func syncVersionCatalog(full bool) (int, error) {
+	catalogSyncMu.Lock()
+	defer catalogSyncMu.Unlock()
+
+	var manifest versionManifest
+	if err := getUpstreamJSON(common.VersionManifestURL, &manifest); err != nil {
+		return 0, err
+	}
+	known := make(map[string]string)
+	if !full {
+		var err error
+		if known, err = model.GetMinecraftVersionHashes(); err != nil {
+			return 0, err
+		}
+	}
+
+	jobs := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		updated  int
+		firstErr error
+	)
+	for w := 0; w < catalogSyncWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entry := manifest.Versions[i]
+				var meta versionMeta
+				err := getUpstreamJSON(entry.URL, &meta)
+				if err == nil {
+					v := &model.MinecraftVersion{
+						ID:           entry.ID,
+						Type:         entry.Type,
+						ReleaseTime:  entry.ReleaseTime,
+						ManifestSHA1: entry.SHA1,
+						JavaMajor:    meta.JavaVersion.MajorVersion,
+						SyncedAt:     time.Now(),
+					}
+					// 很舊的版本沒有 server jar
+					if s := meta.Downloads.Server; s != nil {
+						v.ServerURL, v.ServerSHA1, v.ServerSize = s.URL, s.SHA1, s.Size
+					}
+					err = model.SaveMinecraftVersion(v)
+				}
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("sync %s: %w", entry.ID, err)
+					}
+				} else {
+					updated++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i, entry := range manifest.Versions {
+		if known[entry.ID] == entry.SHA1 {
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return updated, firstErr
+}
+
+// StartVersionCatalogSync 啟動時同步一次，之後每 interval 同步
+func StartVersionCatalogSync(interval time.Duration) {
+	go func() {
+		for {
+			n, err := SyncVersionCatalog()
+			if err != nil {
+				common.SysError("version catalog sync failed: " + err.Error())
+			} else if n > 0 {
+				common.SysLog(fmt.Sprintf("version catalog synced, %d version(s) updated", n))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// bundledVersion 離線時使用內建 JSON，沒有 sha1 與 java 版本，類型用版本號猜
+func bundledVersion(id string) (*model.MinecraftVersion, bool) {
+	url, ok := common.VanillaServerUrl[id]
+	if !ok {
+		return nil, false
+	}
+	t := "snapshot"
+	if releasePattern.MatchString(id) {
+		t = "release"
+	}
+	return &model.MinecraftVersion{ID: id, Type: t, ServerURL: url}, true
+}
+
+// LookupVanillaVersion 先查資料庫，沒有再查內建 JSON
+func LookupVanillaVersion(id string) (*model.MinecraftVersion, error) {
+	v, err := model.GetMinecraftVersion(id)
+	if err == nil && v.ServerURL != "" {
+		return v, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if b, ok := bundledVersion(id); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrVersionNotFound, id)
+}
+
+// ListVanillaVersions kind 為 release / snapshot，空字串代表全部；只回傳有 server jar 的版本
+func ListVanillaVersions(kind string) ([]model.MinecraftVersion, error) {
+	if kind != "" && kind != "release" && kind != "snapshot" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersionType, kind)
+	}
+
+	var types []string
+	if kind != "" {
+		types = []string{kind}
+	}
+	rows, err := model.ListMinecraftVersions(types...)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]model.MinecraftVersion, 0, len(rows))
+	for _, v := range rows {
+		if v.ServerURL != "" {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) > 0 {
+		return versions, nil
+	}
+
+	// 還沒同步成功過
+	for id := range common.VanillaServerUrl {
+		b, _ := bundledVersion(id)
+		if kind == "" || b.Type == kind {
+			versions = append(versions, *b)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return CompareVersions(versions[i].ID, versions[j].ID) > 0 })
+	return versions, nil
+}
+
+// DownloadVanillaServer 經由快取取得 vanilla server.jar，有 sha1 時會驗證
+func DownloadVanillaServer(jc *JobContext, dest, id string) error {
+	spec, err := (&vanillaProvider{}).Artifact(id, InstallOptions{})
+	if err != nil {
+		return err
+	}
+	return ProvisionArtifact(jc, spec, dest)
+}
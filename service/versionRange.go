@@ -0,0 +1,171 @@
+// service/versionRange.go
+
+package service
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions 比較兩個版本字串 (semver 風格，也能處理 1.20.1 / 1.20-pre1 / 0.15.11+build)
+// a < b 回傳 -1，相等回傳 0，a > b 回傳 1
+func CompareVersions(a, b string) int {
+	a = stripBuildMeta(a)
+	b = stripBuildMeta(b)
+
+	aCore, aPre := splitPreRelease(a)
+	bCore, bPre := splitPreRelease(b)
+
+	if c := compareDotted(aCore, bCore); c != 0 {
+		return c
+	}
+
+	// 有 pre-release 的版本小於正式版
+	switch {
+	case aPre == "" && bPre == "":
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return compareDotted(aPre, bPre)
+}
+
+func stripBuildMeta(v string) string {
+	if i := strings.Index(v, "+"); i >= 0 {
+		return v[:i]
+	}
+	return strings.TrimSpace(v)
+}
+
+func splitPreRelease(v string) (string, string) {
+	if i := strings.Index(v, "-"); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
+}
+
+func compareDotted(a, b string) int {
+	ap := strings.Split(a, ".")
+	bp := strings.Split(b, ".")
+	n := len(ap)
+	if len(bp) > n {
+		n = len(bp)
+	}
+	for i := 0; i < n; i++ {
+		var x, y string
+		if i < len(ap) {
+			x = ap[i]
+		}
+		if i < len(bp) {
+			y = bp[i]
+		}
+		if c := comparePart(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func comparePart(x, y string) int {
+	// 缺少的段落視為 0，所以 1.20 == 1.20.0
+	if x == "" {
+		x = "0"
+	}
+	if y == "" {
+		y = "0"
+	}
+	xi, xErr := strconv.Atoi(x)
+	yi, yErr := strconv.Atoi(y)
+	switch {
+	case xErr == nil && yErr == nil:
+		switch {
+		case xi < yi:
+			return -1
+		case xi > yi:
+			return 1
+		}
+		return 0
+	case xErr == nil:
+		return -1 // 數字段小於文字段
+	case yErr == nil:
+		return 1
+	}
+	return strings.Compare(x, y)
+}
+
+// MatchVersionPredicate 檢查 ver 是否符合 fabric.mod.json 的版本條件
+// 支援: "*", ">=1.20", "<1.21", "~1.20.1", "^0.15.0", "1.20.x", "=1.20.1"
+// 以空白分隔的條件視為 AND
+func MatchVersionPredicate(pred, ver string) bool {
+	pred = strings.TrimSpace(pred)
+	if pred == "" || pred == "*" {
+		return true
+	}
+	for _, term := range strings.Fields(pred) {
+		if !matchVersionTerm(term, ver) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchVersionTerm(term, ver string) bool {
+	for _, op := range []string{">=", "<=", ">", "<", "=", "~", "^"} {
+		if !strings.HasPrefix(term, op) {
+			continue
+		}
+		target := strings.TrimPrefix(term, op)
+		c := CompareVersions(ver, target)
+		switch op {
+		case ">=":
+			return c >= 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		case "<":
+			return c < 0
+		case "=":
+			return c == 0
+		case "~":
+			// ~1.20.1 => >=1.20.1 <1.21
+			return c >= 0 && CompareVersions(ver, bumpVersion(target, 1)) < 0
+		case "^":
+			// ^0.15.0 => >=0.15.0 <1.0.0
+			return c >= 0 && CompareVersions(ver, bumpVersion(target, 0)) < 0
+		}
+	}
+
+	if strings.ContainsAny(term, "xX*") {
+		core, _ := splitPreRelease(stripBuildMeta(ver))
+		vp := strings.Split(core, ".")
+		for i, p := range strings.Split(term, ".") {
+			if p == "x" || p == "X" || p == "*" {
+				return true
+			}
+			if i >= len(vp) || comparePart(p, vp[i]) != 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	return CompareVersions(ver, term) == 0
+}
+
+// bumpVersion 把第 idx 段 +1，後面的段落捨棄，用來算 ~ / ^ 的上界
+func bumpVersion(v string, idx int) string {
+	core, _ := splitPreRelease(stripBuildMeta(v))
+	parts := strings.Split(core, ".")
+	if idx >= len(parts) {
+		idx = len(parts) - 1
+	}
+	n, err := strconv.Atoi(parts[idx])
+	if err != nil {
+		return v
+	}
+	parts = append(parts[:idx], strconv.Itoa(n+1))
+	return strings.Join(parts, ".")
+}